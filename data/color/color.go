@@ -0,0 +1,268 @@
+/*
+Generic colour related functionality and definitions
+
+Colours are held as linear RGB with the sRGB primaries and D65 white point, so
+mixing and scaling them behaves like mixing light. They can be made from and
+converted to the colour models operators and fixtures use: HSV, HSI, CIE xyY,
+CMY and colour temperature. Levels for fixtures' emitters come from Levels.
+
+Conversions from models with a wider gamut than sRGB, such as xyY, can give
+colours with components outside 0 to 1; Clamp brings them back into gamut.
+*/
+package color
+
+import (
+  "fmt"
+  "math"
+)
+
+type Colour struct {
+  R float64
+  G float64
+  B float64
+}
+
+var (
+  Black = Colour{0, 0, 0}
+  White = Colour{1, 1, 1}
+)
+
+func RGB(r, g, b float64) Colour {
+  return Colour{r, g, b}
+}
+
+func (c Colour) String() string {
+  return fmt.Sprintf("<Colour r %.3f g %.3f b %.3f>", c.R, c.G, c.B)
+}
+
+func (c Colour) max() float64 {
+  return math.Max(c.R, math.Max(c.G, c.B))
+}
+
+func (c Colour) min() float64 {
+  return math.Min(c.R, math.Min(c.G, c.B))
+}
+
+// Multiply every component by level, e.g. to dim the colour
+func (c Colour) Scale(level float64) Colour {
+  return Colour{c.R * level, c.G * level, c.B * level}
+}
+
+func (c Colour) InGamut() bool {
+  return c.min() >= 0 && c.max() <= 1
+}
+
+/*
+The nearest colour a fixture can make. Negative components are removed by
+adding white, keeping the hue but desaturating the colour, then the colour is
+scaled down so no component is above 1.
+*/
+func (c Colour) Clamp() Colour {
+  if min := c.min(); min < 0 {
+    c = Colour{c.R - min, c.G - min, c.B - min}
+  }
+
+  if max := c.max(); max > 1 {
+    c = c.Scale(1 / max)
+  }
+
+  return c
+}
+
+// Hue in degrees from 0 to 360 of a colour with the given maximum and minimum
+func (c Colour) hue(max, min float64) float64 {
+  if max == min {
+    return 0
+  }
+
+  var h float64
+  switch max {
+  case c.R:
+    h = (c.G - c.B) / (max - min)
+  case c.G:
+    h = 2 + (c.B - c.R) / (max - min)
+  default:
+    h = 4 + (c.R - c.G) / (max - min)
+  }
+
+  h *= 60
+  if h < 0 {
+    h += 360
+  }
+
+  return h
+}
+
+// Hue in degrees, saturation and value from 0 to 1
+func (c Colour) HSV() (h, s, v float64) {
+  c = c.Clamp()
+  max, min := c.max(), c.min()
+
+  if max == 0 {
+    return 0, 0, 0
+  }
+
+  return c.hue(max, min), (max - min) / max, max
+}
+
+func FromHSV(h, s, v float64) Colour {
+  h = math.Mod(h, 360)
+  if h < 0 {
+    h += 360
+  }
+
+  chroma := v * s
+  x := chroma * (1 - math.Abs(math.Mod(h / 60, 2) - 1))
+  m := v - chroma
+
+  var c Colour
+  switch {
+  case h < 60:
+    c = Colour{chroma, x, 0}
+  case h < 120:
+    c = Colour{x, chroma, 0}
+  case h < 180:
+    c = Colour{0, chroma, x}
+  case h < 240:
+    c = Colour{0, x, chroma}
+  case h < 300:
+    c = Colour{x, 0, chroma}
+  default:
+    c = Colour{chroma, 0, x}
+  }
+
+  return Colour{c.R + m, c.G + m, c.B + m}
+}
+
+/*
+Hue in degrees, saturation and intensity from 0 to 1. Intensity is the mean of
+the components, so saturated colours are never brighter than a third.
+*/
+func (c Colour) HSI() (h, s, i float64) {
+  c = c.Clamp()
+  i = (c.R + c.G + c.B) / 3
+
+  if i == 0 {
+    return 0, 0, 0
+  }
+
+  s = 1 - c.min() / i
+
+  // HSI hue is an angle around the colour triangle rather than the hexagon
+  denominator := math.Sqrt((c.R - c.G) * (c.R - c.G) + (c.R - c.B) * (c.G - c.B))
+  if denominator == 0 {
+    return 0, s, i
+  }
+
+  cos := (c.R - c.G + c.R - c.B) / 2 / denominator
+  h = math.Acos(math.Max(-1, math.Min(1, cos))) * 180 / math.Pi
+  if c.B > c.G {
+    h = 360 - h
+  }
+
+  return h, s, i
+}
+
+// Colours with a high intensity and saturation are out of gamut until clamped
+func FromHSI(h, s, i float64) Colour {
+  h = math.Mod(h, 360)
+  if h < 0 {
+    h += 360
+  }
+
+  sector := math.Floor(h / 120)
+  angle := (h - sector * 120) * math.Pi / 180
+
+  low := i * (1 - s)
+  high := i * (1 + s * math.Cos(angle) / math.Cos(math.Pi / 3 - angle))
+  mid := 3 * i - low - high
+
+  switch sector {
+  case 0:
+    return Colour{high, mid, low}
+  case 1:
+    return Colour{low, high, mid}
+  default:
+    return Colour{mid, low, high}
+  }
+}
+
+// CIE 1931 chromaticity and luminance relative to white
+func (c Colour) XYY() (x, y, luminance float64) {
+  X := 0.4124 * c.R + 0.3576 * c.G + 0.1805 * c.B
+  Y := 0.2126 * c.R + 0.7152 * c.G + 0.0722 * c.B
+  Z := 0.0193 * c.R + 0.1192 * c.G + 0.9505 * c.B
+
+  sum := X + Y + Z
+  if sum == 0 {
+    // Black has no chromaticity so give the white point
+    return 0.3127, 0.3290, 0
+  }
+
+  return X / sum, Y / sum, Y
+}
+
+func FromXYY(x, y, luminance float64) Colour {
+  if y == 0 {
+    return Black
+  }
+
+  X := x * luminance / y
+  Z := (1 - x - y) * luminance / y
+  Y := luminance
+
+  return Colour{
+    3.2406 * X - 1.5372 * Y - 0.4986 * Z,
+    -0.9689 * X + 1.8758 * Y + 0.0415 * Z,
+    0.0557 * X - 0.2040 * Y + 1.0570 * Z,
+  }
+}
+
+// Cyan, magenta and yellow filter levels from 0 to 1
+func (c Colour) CMY() (cyan, magenta, yellow float64) {
+  c = c.Clamp()
+  return 1 - c.R, 1 - c.G, 1 - c.B
+}
+
+func FromCMY(cyan, magenta, yellow float64) Colour {
+  return Colour{1 - cyan, 1 - magenta, 1 - yellow}
+}
+
+/*
+The colour of a black body at a temperature in kelvin, between 1667 and 25000,
+at the brightest level in gamut. Temperatures outside that are limited to it.
+*/
+func FromTemperature(kelvin float64) Colour {
+  t := math.Max(1667, math.Min(25000, kelvin))
+
+  // Cubic spline approximation of the Planckian locus by Kim et al.
+  var x float64
+  if t <= 4000 {
+    x = -0.2661239e9 / (t * t * t) - 0.2343589e6 / (t * t) + 0.8776956e3 / t + 0.179910
+  } else {
+    x = -3.0258469e9 / (t * t * t) + 2.1070379e6 / (t * t) + 0.2226347e3 / t + 0.240390
+  }
+
+  var y float64
+  switch {
+  case t <= 2222:
+    y = -1.1063814 * x * x * x - 1.34811020 * x * x + 2.18555832 * x - 0.20219683
+  case t <= 4000:
+    y = -0.9549476 * x * x * x - 1.37418593 * x * x + 2.09137015 * x - 0.16748867
+  default:
+    y = 3.0817580 * x * x * x - 5.87338670 * x * x + 3.75112997 * x - 0.37001483
+  }
+
+  c := FromXYY(x, y, 1).Clamp()
+  return c.Scale(1 / c.max())
+}
+
+/*
+The correlated colour temperature in kelvin, using McCamy's approximation. Only
+meaningful for colours close to white.
+*/
+func (c Colour) Temperature() float64 {
+  x, y, _ := c.XYY()
+  n := (x - 0.3320) / (0.1858 - y)
+  return 449 * n * n * n + 3525 * n * n + 6823.3 * n + 5520.33
+}
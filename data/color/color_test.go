@@ -0,0 +1,93 @@
+package color
+
+import (
+  "math"
+  "testing"
+)
+
+func near(a, b float64) bool {
+  return math.Abs(a - b) < 1e-3
+}
+
+func nearColour(a, b Colour) bool {
+  return near(a.R, b.R) && near(a.G, b.G) && near(a.B, b.B)
+}
+
+func TestRoundTrips(t *testing.T) {
+  colours := []Colour{{1, 0, 0}, {0.2, 0.6, 0.9}, {0.5, 0.5, 0.5}, {0.9, 0.1, 0.7}}
+
+  for _, c := range colours {
+    if h, s, v := c.HSV(); !nearColour(FromHSV(h, s, v), c) {
+      t.Log("HSV of ", c, " converted back as ", FromHSV(h, s, v))
+      t.Fail()
+    }
+
+    if h, s, i := c.HSI(); !nearColour(FromHSI(h, s, i), c) {
+      t.Log("HSI of ", c, " converted back as ", FromHSI(h, s, i))
+      t.Fail()
+    }
+
+    if x, y, l := c.XYY(); !nearColour(FromXYY(x, y, l), c) {
+      t.Log("xyY of ", c, " converted back as ", FromXYY(x, y, l))
+      t.Fail()
+    }
+
+    if cyan, magenta, yellow := c.CMY(); !nearColour(FromCMY(cyan, magenta, yellow), c) {
+      t.Log("CMY of ", c, " converted back as ", FromCMY(cyan, magenta, yellow))
+      t.Fail()
+    }
+  }
+}
+
+func TestGamut(t *testing.T) {
+  // Spectral green is far outside sRGB
+  c := FromXYY(0.1, 0.8, 1)
+
+  if c.InGamut() {
+    t.Log("Spectral green is in gamut: ", c)
+    t.Fail()
+  }
+
+  clamped := c.Clamp()
+  if !clamped.InGamut() || clamped.G != 1 {
+    t.Log("Spectral green clamped to ", clamped)
+    t.Fail()
+  }
+}
+
+func TestTemperature(t *testing.T) {
+  warm := FromTemperature(3200)
+  cool := FromTemperature(6500)
+
+  if warm.R != 1 || warm.B >= cool.B {
+    t.Log("Colour temperatures converted incorrectly: ", warm, cool)
+    t.Fail()
+  }
+
+  if k := cool.Temperature(); math.Abs(k - 6500) > 100 {
+    t.Log("6500K converted back as ", k)
+    t.Fail()
+  }
+}
+
+func TestLevels(t *testing.T) {
+  c := RGB(1, 0.75, 0.25)
+
+  // White takes the common part and amber as much of the rest as it can
+  levels := c.Levels(LayoutRGBAW)
+  expected := []float64{0, 0.125, 0, 0.75, 0.25}
+  for i := range expected {
+    if !near(levels[i], expected[i]) {
+      t.Log("RGBAW levels are ", levels, " expected ", expected)
+      t.Fail()
+      break
+    }
+  }
+
+  for _, layout := range []Layout{LayoutRGB, LayoutRGBW, LayoutRGBA, LayoutRGBAW, LayoutCMY} {
+    if mixed := FromLevels(layout, c.Levels(layout)); !nearColour(mixed, c) {
+      t.Log("Levels for ", layout, " mix to ", mixed)
+      t.Fail()
+    }
+  }
+}
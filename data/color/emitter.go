@@ -0,0 +1,156 @@
+package color
+
+import "math"
+
+// A light source or filter in a colour mixing fixture
+type Emitter int
+
+const (
+  RedEmitter Emitter = iota
+  GreenEmitter
+  BlueEmitter
+  WhiteEmitter
+  AmberEmitter
+  CyanEmitter
+  MagentaEmitter
+  YellowEmitter
+)
+
+/*
+The green an amber emitter gives for each unit of red. Amber is taken out of
+the red and green of a colour in this ratio.
+*/
+const amberGreen = 0.5
+
+func (e Emitter) String() string {
+  switch e {
+  case RedEmitter:
+    return "red"
+  case GreenEmitter:
+    return "green"
+  case BlueEmitter:
+    return "blue"
+  case WhiteEmitter:
+    return "white"
+  case AmberEmitter:
+    return "amber"
+  case CyanEmitter:
+    return "cyan"
+  case MagentaEmitter:
+    return "magenta"
+  case YellowEmitter:
+    return "yellow"
+  default:
+    return "unknown"
+  }
+}
+
+// The emitters of a fixture in the order of its channels
+type Layout []Emitter
+
+var (
+  LayoutRGB = Layout{RedEmitter, GreenEmitter, BlueEmitter}
+  LayoutRGBW = Layout{RedEmitter, GreenEmitter, BlueEmitter, WhiteEmitter}
+  LayoutRGBA = Layout{RedEmitter, GreenEmitter, BlueEmitter, AmberEmitter}
+  LayoutRGBAW = Layout{RedEmitter, GreenEmitter, BlueEmitter, AmberEmitter, WhiteEmitter}
+  LayoutCMY = Layout{CyanEmitter, MagentaEmitter, YellowEmitter}
+)
+
+func (layout Layout) has(e Emitter) bool {
+  for _, emitter := range layout {
+    if emitter == e {
+      return true
+    }
+  }
+  return false
+}
+
+/*
+The level from 0 to 1 of each emitter in layout that makes the colour, in the
+same order. The colour is clamped into gamut first. White and amber emitters
+take as much of the colour as they can so that the same colour has the same
+output whatever emitters a fixture has; the red, green and blue emitters make
+up the rest. Cyan, magenta and yellow are filters subtracting from white.
+*/
+func (c Colour) Levels(layout Layout) []float64 {
+  c = c.Clamp()
+  r, g, b := c.R, c.G, c.B
+  var white, amber float64
+
+  if layout.has(WhiteEmitter) {
+    white = math.Min(r, math.Min(g, b))
+    r, g, b = r - white, g - white, b - white
+  }
+
+  if layout.has(AmberEmitter) {
+    amber = math.Min(r, g / amberGreen)
+    r, g = r - amber, g - amber * amberGreen
+  }
+
+  cyan, magenta, yellow := c.CMY()
+
+  levels := make([]float64, len(layout))
+  for i, emitter := range layout {
+    switch emitter {
+    case RedEmitter:
+      levels[i] = r
+    case GreenEmitter:
+      levels[i] = g
+    case BlueEmitter:
+      levels[i] = b
+    case WhiteEmitter:
+      levels[i] = white
+    case AmberEmitter:
+      levels[i] = amber
+    case CyanEmitter:
+      levels[i] = cyan
+    case MagentaEmitter:
+      levels[i] = magenta
+    case YellowEmitter:
+      levels[i] = yellow
+    }
+  }
+
+  return levels
+}
+
+/*
+The colour made by emitters in layout at the given levels. A layout with
+cyan, magenta or yellow is treated as a subtractive fixture and any additive
+emitters in it are ignored.
+*/
+func FromLevels(layout Layout, levels []float64) Colour {
+  var c Colour
+  filters := map[Emitter] float64{}
+  subtractive := false
+
+  for i, emitter := range layout {
+    if i >= len(levels) {
+      break
+    }
+
+    level := levels[i]
+    switch emitter {
+    case RedEmitter:
+      c.R += level
+    case GreenEmitter:
+      c.G += level
+    case BlueEmitter:
+      c.B += level
+    case WhiteEmitter:
+      c = Colour{c.R + level, c.G + level, c.B + level}
+    case AmberEmitter:
+      c.R += level
+      c.G += level * amberGreen
+    case CyanEmitter, MagentaEmitter, YellowEmitter:
+      filters[emitter] = level
+      subtractive = true
+    }
+  }
+
+  if subtractive {
+    return FromCMY(filters[CyanEmitter], filters[MagentaEmitter], filters[YellowEmitter])
+  }
+
+  return c
+}
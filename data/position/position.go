@@ -0,0 +1,20 @@
+/*
+Generic position related functionality and definitions
+*/
+package position
+
+import "fmt"
+
+/*
+Where a moving light is pointing, in degrees. Tilt is measured from the
+direction the beam points when the head is centred and pan is measured around
+the axis of the yoke.
+*/
+type Position struct {
+  Pan float64
+  Tilt float64
+}
+
+func (p Position) String() string {
+  return fmt.Sprintf("<Position pan %.2f tilt %.2f>", p.Pan, p.Tilt)
+}
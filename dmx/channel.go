@@ -1,24 +1,30 @@
 /*
 Provides a Go Channel that can send or recieve values for a single DMX Channel
-in a universes. Needs rewriting.
+in a universes.
 */
 package dmx
 
-import "fmt"
+import (
+  "fmt"
+  "sync"
+  "golx/dmx/curve"
+  "golx/patch/chanutil"
+)
 
 type DMXChannel struct {
   universe *DMXUniverse
   channelNumber int
+
   output chan DMXValue
+  outputOnce sync.Once
   input chan DMXValue
+  inputOnce sync.Once
 }
 
 func newDMXChannel(universe *DMXUniverse, channelNumber int) *DMXChannel {
   channel := new(DMXChannel)
   channel.universe = universe
   channel.channelNumber = channelNumber
-  channel.input = nil
-  channel.output = make(chan DMXValue)
 
   return channel
 }
@@ -27,15 +33,26 @@ func (channel *DMXChannel) String() string {
   return fmt.Sprintf("[%s.%d]", channel.universe.String(), channel.channelNumber)
 }
 
-func (channel *DMXChannel) Input() chan DMXValue {
-  if channel.input == nil {
-    channel.buildInput()
-  }
+// The channel's position in its universe, counting from 1
+func (channel *DMXChannel) ChannelNumber() int {
+  return channel.channelNumber
+}
+
+func (channel *DMXChannel) Universe() *DMXUniverse {
+  return channel.universe
+}
 
+func (channel *DMXChannel) Input() chan DMXValue {
+  channel.inputOnce.Do(channel.buildInput)
   return channel.input
 }
 
+/*
+Recieves the channel's value when it changes. Values are only sent once per
+refresh period of the universe and are skipped if they are not read in time.
+*/
 func (channel *DMXChannel) Output() chan DMXValue {
+  channel.outputOnce.Do(channel.buildOutput)
   return channel.output
 }
 
@@ -43,22 +60,40 @@ func (channel *DMXChannel) setValue(val DMXValue) {
   channel.universe.setValue(channel.channelNumber, val)
 }
 
+// The value set on the channel, before any curve is applied
 func (channel *DMXChannel) Value() DMXValue {
   return channel.universe.getValue(channel.channelNumber)
 }
 
-func (channel *DMXChannel) buildInput() {
+// Apply a curve to the channel as it is output, or remove it if c is nil
+func (channel *DMXChannel) SetCurve(c curve.Curve) {
+  channel.universe.SetCurve(channel.channelNumber, c)
+}
+
+// Mark the channel as carrying a class of parameter
+func (channel *DMXChannel) SetClass(class ParamClass) {
+  channel.universe.setClass(channel.channelNumber, 1, MSBFirst, class)
+}
+
+func (channel *DMXChannel) Class() ParamClass {
+  return channel.universe.Class(channel.channelNumber)
+}
 
+func (channel *DMXChannel) buildInput() {
   channel.input = make(chan DMXValue)
 
   go func() {
     for val := range channel.input {
-      fmt.Println("DMXChannel got data: ", val)
       channel.setValue(val)
     }
   }()
 }
 
-func (channel *DMXChannel) sendOutput() {
-  channel.output <- channel.Value()
+func (channel *DMXChannel) buildOutput() {
+  channel.output = make(chan DMXValue)
+
+  feed := make(chan DMXValue)
+  chanutil.DeliverWhenPossible(feed, channel.output)
+
+  channel.universe.addFeed(channel.channelNumber, feed)
 }
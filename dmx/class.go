@@ -0,0 +1,38 @@
+/*
+Parameter classes
+
+Universes are told what kind of parameter drives each of their channels so that
+stages such as the grand master only change the channels they should. A class
+is normally set when a parameter is patched to a channel; the patch system
+copies the class from the parameter's Class method to the channel's SetClass.
+*/
+package dmx
+
+type ParamClass int
+
+const (
+  // Not tagged, never scaled or otherwise changed by the universe
+  UnknownClass ParamClass = iota
+  IntensityClass
+  PositionClass
+  ColourClass
+  BeamClass
+  ControlClass
+)
+
+func (class ParamClass) String() string {
+  switch class {
+  case IntensityClass:
+    return "Intensity"
+  case PositionClass:
+    return "Position"
+  case ColourClass:
+    return "Colour"
+  case BeamClass:
+    return "Beam"
+  case ControlClass:
+    return "Control"
+  default:
+    return "Unknown"
+  }
+}
@@ -0,0 +1,125 @@
+/*
+Dimmer curves
+
+Curves map a requested level to the level actually sent to a dimmer or fixture
+so that different loads respond to the same programmed level in the same way.
+Levels are normalised from 0 to 1 so curves work for 8 bit and multi-slot
+channels alike.
+*/
+package curve
+
+import (
+  "errors"
+  "math"
+)
+
+type Curve interface {
+  Apply(level float64) float64
+}
+
+func clamp(level float64) float64 {
+  return math.Max(0, math.Min(1, level))
+}
+
+// Output is the same as the input
+type Linear struct{}
+
+func (Linear) Apply(level float64) float64 {
+  return clamp(level)
+}
+
+// Slow at the bottom of the range, for loads that come up too quickly
+type SquareLaw struct{}
+
+func (SquareLaw) Apply(level float64) float64 {
+  level = clamp(level)
+  return level * level
+}
+
+// Fast at the bottom of the range, for loads that are slow to respond
+type InverseSquare struct{}
+
+func (InverseSquare) Apply(level float64) float64 {
+  return math.Sqrt(clamp(level))
+}
+
+// Slow at both ends of the range and fast in the middle
+type SCurve struct{}
+
+func (SCurve) Apply(level float64) float64 {
+  level = clamp(level)
+  return level * level * (3 - 2 * level)
+}
+
+/*
+Switches fully on at or above Threshold and off below it, for relays and loads
+that can't be dimmed
+*/
+type NonDim struct {
+  Threshold float64
+}
+
+func (c NonDim) Apply(level float64) float64 {
+  if level >= c.Threshold && level > 0 {
+    return 1
+  }
+  return 0
+}
+
+/*
+A user defined curve. The points are output levels for evenly spaced input
+levels from 0 to 1, and levels between points are interpolated linearly.
+*/
+type Table struct {
+  points []float64
+}
+
+func NewTable(points []float64) (*Table, error) {
+  if len(points) < 2 {
+    return nil, errors.New("A curve table needs at least two points")
+  }
+
+  table := new(Table)
+  table.points = make([]float64, len(points))
+
+  for i, p := range points {
+    table.points[i] = clamp(p)
+  }
+
+  return table, nil
+}
+
+func (c *Table) Apply(level float64) float64 {
+  pos := clamp(level) * float64(len(c.points) - 1)
+  i := int(pos)
+
+  if i >= len(c.points) - 1 {
+    return c.points[len(c.points) - 1]
+  }
+
+  frac := pos - float64(i)
+  return c.points[i] + (c.points[i + 1] - c.points[i]) * frac
+}
+
+/*
+Applies a curve between a preheat level, which the output never falls below so
+filaments stay warm, and a top set level it never exceeds
+*/
+type Limit struct {
+  Curve Curve
+  Preheat float64
+  TopSet float64
+}
+
+func NewLimit(c Curve, preheat, topSet float64) *Limit {
+  return &Limit{c, clamp(preheat), clamp(topSet)}
+}
+
+func (c *Limit) Apply(level float64) float64 {
+  inner := clamp(level)
+  if c.Curve != nil {
+    inner = c.Curve.Apply(level)
+  }
+
+  return c.Preheat + inner * (c.TopSet - c.Preheat)
+}
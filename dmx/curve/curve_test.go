@@ -0,0 +1,50 @@
+package curve
+
+import (
+  "math"
+  "testing"
+)
+
+func closeTo(a, b float64) bool {
+  return math.Abs(a - b) < 0.0001
+}
+
+func TestCurves(t *testing.T) {
+  table, _ := NewTable([]float64{0, 0.8, 1})
+
+  cases := []struct {
+    curve Curve
+    in float64
+    out float64
+  }{
+    {Linear{}, 0.3, 0.3},
+    {Linear{}, 1.5, 1},
+    {SquareLaw{}, 0.5, 0.25},
+    {InverseSquare{}, 0.25, 0.5},
+    {SCurve{}, 0.5, 0.5},
+    {SCurve{}, 0.25, 0.15625},
+    {NonDim{0.5}, 0.49, 0},
+    {NonDim{0.5}, 0.5, 1},
+    {NonDim{0}, 0, 0},
+    {table, 0.25, 0.4},
+    {table, 0.75, 0.9},
+    {table, 1, 1},
+    {NewLimit(Linear{}, 0.1, 0.9), 0, 0.1},
+    {NewLimit(Linear{}, 0.1, 0.9), 1, 0.9},
+    {NewLimit(SquareLaw{}, 0, 0.5), 0.5, 0.125},
+  }
+
+  for i, c := range cases {
+    if out := c.curve.Apply(c.in); !closeTo(out, c.out) {
+      t.Log("Case ", i, " gave ", out, " expected ", c.out)
+      t.Fail()
+    }
+  }
+}
+
+func TestTableNeedsTwoPoints(t *testing.T) {
+  if _, err := NewTable([]float64{1}); err == nil {
+    t.Log("Table with one point was allowed")
+    t.Fail()
+  }
+}
@@ -9,7 +9,16 @@ import "fmt"
 type DMXValue uint8
 // A DMX Universe as formated in a NULL START DMX Packet
 type DMXFrame []DMXValue
+/*
+A level from 0 to 1 for a channel that may span several DMX slots. The channel
+decides how many bytes of resolution the level is sent with.
+*/
+type DMXLevel float64
 
 func (val DMXValue) String() string {
   return fmt.Sprintf("<DMX Value %d>", uint8(val))
 }
+
+func (level DMXLevel) String() string {
+  return fmt.Sprintf("<DMX Level %.4f>", float64(level))
+}
@@ -0,0 +1,135 @@
+/*
+Beam attributes with physical values
+
+Zoom, focus, iris, frost and strobe are programmed in the units of the beam,
+such as degrees, percent or Hz, rather than in DMX values. Each fixture's
+profile maps its units to DMX values, so data programmed on one model gives the
+same beam on another.
+
+DMXBeam drives a single valued attribute such as zoom or iris through the map
+of its profile attribute. DMXShutter drives a shutter channel with open,
+closed and strobe ranges, found by the words in their names as the OFL
+importer names them, and uses the map for the strobe rate.
+
+The parameters take levels, so patch them to a dmx.DMXMultiChannel as wide as
+the attribute.
+*/
+package dmxbeam
+
+import (
+  "sync"
+  "golx/fixture"
+  "golx/fixture/converter"
+  "golx/fixture/mixer"
+  "golx/fixture/profile"
+  "golx/dmx"
+  "golx/dmx/dmxfixture"
+)
+
+type DMXBeam struct {
+  fixture fixture.Fixture
+  def profile.AttributeDef
+  converter *converter.Piecewise
+  param *dmxfixture.DMXMultiParam
+  mixer *mixer.LTPMixer
+
+  input chan float64
+  value float64
+  valueLock sync.Mutex
+}
+
+/*
+Build a beam attribute from a profile attribute with a map of physical values.
+The attribute starts at the value of the attribute's default.
+*/
+func NewDMXBeam(fixture fixture.Fixture, def profile.AttributeDef) (*DMXBeam, error) {
+  conv, err := def.Converter()
+
+  if err != nil {
+    return nil, err
+  }
+
+  attr := new(DMXBeam)
+  attr.fixture = fixture
+  attr.def = def
+  attr.converter = conv
+  attr.value = conv.Value(def.Default * float64(dmx.MaxLevel(1)))
+
+  attr.param = dmxfixture.NewDMXMultiParam(attr)
+  attr.param.SetClass(def.Class())
+
+  attr.input = make(chan float64)
+  attr.mixer, _ = mixer.NewLTPMixer(attr.input, attr.value)
+
+  go func() {
+    for value := range attr.input {
+      attr.valueLock.Lock()
+      attr.value = value
+      attr.valueLock.Unlock()
+
+      attr.param.SetValue(attr.Level(value))
+    }
+  }()
+
+  attr.param.SetValue(attr.Level(attr.value))
+
+  return attr, nil
+}
+
+func (attr *DMXBeam) Fixture() fixture.Fixture {
+  return attr.fixture
+}
+
+func (attr *DMXBeam) Name() string {
+  return attr.def.Name
+}
+
+// The unit of the attribute's values, e.g. degrees
+func (attr *DMXBeam) Unit() string {
+  return attr.def.Unit
+}
+
+// The smallest value the fixture can make
+func (attr *DMXBeam) Min() float64 {
+  return attr.converter.Min()
+}
+
+// The largest value the fixture can make
+func (attr *DMXBeam) Max() float64 {
+  return attr.converter.Max()
+}
+
+// The level for a value, limited to what the fixture can make
+func (attr *DMXBeam) Level(value float64) dmx.DMXLevel {
+  return converter.Level(len(attr.def.Channels), attr.converter.DMX(value))
+}
+
+// Set the value in the attribute's unit
+func (attr *DMXBeam) SetValue(value float64) {
+  attr.input <- value
+}
+
+// The value as programmed, which may be beyond what the fixture can make
+func (attr *DMXBeam) Value() float64 {
+  attr.valueLock.Lock()
+  defer attr.valueLock.Unlock()
+  return attr.value
+}
+
+/*
+Get a new input for the attribute. Inputs are mixed latest takes precedence and
+the attribute returns to its starting value when every input has been closed.
+*/
+func (attr *DMXBeam) Input() chan float64 {
+  c := make(chan float64)
+  attr.mixer.AddInput(c)
+  return c
+}
+
+func (attr *DMXBeam) Parameters() map[string] fixture.Parameter {
+  return map[string] fixture.Parameter{attr.def.Name: attr.param}
+}
+
+func (attr *DMXBeam) DMXOut() *dmxfixture.DMXMultiParam {
+  return attr.param
+}
@@ -0,0 +1,142 @@
+package dmxbeam
+
+import (
+  "testing"
+  "golx/dmx"
+  "golx/dmx/dmxtest"
+  "golx/fixture/converter"
+  "golx/fixture/profile"
+)
+
+var zoom = profile.AttributeDef{
+  Name: "Zoom",
+  Type: "beam",
+  Channels: []int{1, 2},
+  Unit: "degrees",
+  Map: []converter.Point{{DMX: 0, Value: 40}, {DMX: 128, Value: 20}, {DMX: 255, Value: 10}},
+}
+
+var shutter = profile.AttributeDef{
+  Name: "Shutter",
+  Type: "beam",
+  Channels: []int{1},
+  Default: 1,
+  Unit: "Hz",
+  Ranges: []profile.Range{
+    {From: 0, To: 9, Name: "ShutterStrobe Closed"},
+    {From: 10, To: 19, Name: "ShutterStrobe Open"},
+    {From: 20, To: 219, Name: "ShutterStrobe Strobe"},
+    {From: 220, To: 255, Name: "ShutterStrobe Open"},
+  },
+  Map: []converter.Point{{DMX: 20, Value: 1}, {DMX: 219, Value: 25}},
+}
+
+func TestBeam(t *testing.T) {
+  attr, err := NewDMXBeam(nil, zoom)
+  if err != nil {
+    t.Log(err.Error())
+    t.FailNow()
+  }
+
+  u, watch := dmxtest.Patched(t, attr.DMXOut(), 2)
+
+  if attr.Value() != 40 || attr.Min() != 10 || attr.Max() != 40 || attr.Unit() != "degrees" {
+    t.Log("Zoom started at ", attr.Value(), " with range ", attr.Min(), " to ", attr.Max())
+    t.Fail()
+  }
+
+  attr.SetValue(20)
+  if dmxtest.WaitFor(watch, func(f dmx.DMXFrame) bool { return f[0] == 128 && f[1] == 0 }) == nil {
+    t.Log("20 degrees gave ", u.GetChannel(1).Value(), " ", u.GetChannel(2).Value())
+    t.Fail()
+  }
+
+  // Half way between 20 and 10 degrees, at fine resolution
+  attr.SetValue(15)
+  if dmxtest.WaitFor(watch, func(f dmx.DMXFrame) bool { return f[0] == 191 && f[1] == 128 }) == nil {
+    t.Log("15 degrees gave ", u.GetChannel(1).Value(), " ", u.GetChannel(2).Value())
+    t.Fail()
+  }
+
+  // Values beyond the fixture are limited but kept as programmed
+  attr.SetValue(5)
+  if dmxtest.WaitFor(watch, func(f dmx.DMXFrame) bool { return f[0] == 255 }) == nil || attr.Value() != 5 {
+    t.Log("5 degrees gave ", u.GetChannel(1).Value(), " and value ", attr.Value())
+    t.Fail()
+  }
+}
+
+func TestBeamNeedsMap(t *testing.T) {
+  def := zoom
+  def.Map = nil
+
+  if _, err := NewDMXBeam(nil, def); err == nil {
+    t.Log("Beam attribute was made without a map")
+    t.Fail()
+  }
+}
+
+func TestShutter(t *testing.T) {
+  attr, err := NewDMXShutter(nil, shutter)
+  if err != nil {
+    t.Log(err.Error())
+    t.FailNow()
+  }
+
+  u, watch := dmxtest.Patched(t, attr.DMXOut(), 1)
+
+  if attr.Value().State != Open {
+    t.Log("Shutter started ", attr.Value().State)
+    t.Fail()
+  }
+
+  attr.SetClosed()
+  if dmxtest.WaitFor(watch, func(f dmx.DMXFrame) bool { return f[0] == 5 }) == nil {
+    t.Log("Closed gave ", u.GetChannel(1).Value())
+    t.Fail()
+  }
+
+  attr.SetStrobe(13)
+  if dmxtest.WaitFor(watch, func(f dmx.DMXFrame) bool { return f[0] == 120 }) == nil {
+    t.Log("Strobe at 13Hz gave ", u.GetChannel(1).Value())
+    t.Fail()
+  }
+
+  attr.SetStrobe(50)
+  if dmxtest.WaitFor(watch, func(f dmx.DMXFrame) bool { return f[0] == 219 }) == nil {
+    t.Log("Strobe at 50Hz gave ", u.GetChannel(1).Value())
+    t.Fail()
+  }
+
+  if min, max := attr.RateRange(); min != 1 || max != 25 {
+    t.Log("Strobe rates were ", min, " to ", max)
+    t.Fail()
+  }
+}
+
+func TestShutterInputs(t *testing.T) {
+  attr, err := NewDMXShutter(nil, shutter)
+  if err != nil {
+    t.Log(err.Error())
+    t.FailNow()
+  }
+
+  _, watch := dmxtest.Patched(t, attr.DMXOut(), 1)
+
+  input := attr.Input()
+  input <- Shutter{State: Strobe, Rate: 5}
+
+  strobing := func(f dmx.DMXFrame) bool { return f[0] >= 20 && f[0] <= 219 }
+  if dmxtest.WaitFor(watch, strobing) == nil || attr.Value().State != Strobe {
+    t.Log("Input didn't set the shutter")
+    t.Fail()
+  }
+
+  close(input)
+
+  open := func(f dmx.DMXFrame) bool { return f[0] >= 10 && f[0] <= 19 || f[0] >= 220 }
+  if dmxtest.WaitFor(watch, open) == nil || attr.Value().State != Open {
+    t.Log("Shutter didn't return to open, was ", attr.Value().State)
+    t.Fail()
+  }
+}
@@ -0,0 +1,208 @@
+package dmxbeam
+
+import (
+  "errors"
+  "sync"
+  "golx/fixture"
+  "golx/fixture/converter"
+  "golx/fixture/mixer"
+  "golx/fixture/profile"
+  "golx/dmx"
+  "golx/dmx/dmxfixture"
+)
+
+type ShutterState int
+
+const (
+  Open ShutterState = iota
+  Closed
+  Strobe
+)
+
+func (state ShutterState) String() string {
+  switch state {
+  case Open:
+    return "Open"
+  case Closed:
+    return "Closed"
+  case Strobe:
+    return "Strobe"
+  }
+  return "Unknown"
+}
+
+// A shutter state, with the rate in Hz when strobing
+type Shutter struct {
+  State ShutterState
+  Rate float64
+}
+
+type DMXShutter struct {
+  fixture fixture.Fixture
+  def profile.AttributeDef
+  ranges map[ShutterState] profile.Range
+
+  // The strobe rate map, or nil if the profile doesn't give one
+  converter *converter.Piecewise
+
+  param *dmxfixture.DMXMultiParam
+  mixer *mixer.LTPMixer
+
+  input chan Shutter
+  value Shutter
+  valueLock sync.Mutex
+}
+
+/*
+Build a shutter from a profile attribute, finding its open, closed and strobe
+ranges by name. A map, if the attribute has one, gives the DMX values of
+strobe rates in Hz. The shutter starts in the state of the range containing the
+attribute's default, or open.
+*/
+func NewDMXShutter(fixture fixture.Fixture, def profile.AttributeDef) (*DMXShutter, error) {
+  attr := new(DMXShutter)
+  attr.fixture = fixture
+  attr.def = def
+  attr.ranges = make(map[ShutterState] profile.Range)
+
+  for _, r := range def.Ranges {
+    w := r.Words()
+
+    switch {
+    case w["open"]:
+      attr.ranges[Open] = r
+    case w["closed"] || w["close"] || w["blackout"]:
+      attr.ranges[Closed] = r
+    case w["strobe"] || w["strobing"]:
+      attr.ranges[Strobe] = r
+    }
+  }
+
+  if len(def.Map) > 0 {
+    conv, err := def.Converter()
+    if err != nil {
+      return nil, err
+    }
+    attr.converter = conv
+  }
+
+  if _, exists := attr.ranges[Strobe]; !exists && attr.converter == nil {
+    return nil, errors.New("Attribute " + def.Name + " has no strobe range or map")
+  }
+
+  coarse := int(def.Default * float64(dmx.MaxLevel(1)) + 0.5)
+  for state, r := range attr.ranges {
+    if coarse >= r.From && coarse <= r.To {
+      attr.value.State = state
+    }
+  }
+
+  if attr.value.State == Strobe && attr.converter != nil {
+    attr.value.Rate = attr.converter.Value(float64(coarse))
+  }
+
+  attr.param = dmxfixture.NewDMXMultiParam(attr)
+  attr.param.SetClass(def.Class())
+
+  attr.input = make(chan Shutter)
+  attr.mixer, _ = mixer.NewLTPMixer(attr.input, attr.value)
+
+  go func() {
+    for shutter := range attr.input {
+      level, err := attr.Level(shutter)
+
+      // States the channel doesn't have are ignored
+      if err != nil {
+        continue
+      }
+
+      attr.valueLock.Lock()
+      attr.value = shutter
+      attr.valueLock.Unlock()
+
+      attr.param.SetValue(level)
+    }
+  }()
+
+  if level, err := attr.Level(attr.value); err == nil {
+    attr.param.SetValue(level)
+  }
+
+  return attr, nil
+}
+
+func (attr *DMXShutter) Fixture() fixture.Fixture {
+  return attr.fixture
+}
+
+func (attr *DMXShutter) Name() string {
+  return attr.def.Name
+}
+
+// The slowest and fastest strobe rates in Hz, or zero if they aren't known
+func (attr *DMXShutter) RateRange() (min, max float64) {
+  if attr.converter == nil {
+    return 0, 0
+  }
+  return attr.converter.Min(), attr.converter.Max()
+}
+
+/*
+The level that makes a shutter state. Strobe rates are converted through the
+attribute's map, or give the middle of the strobe range without one.
+*/
+func (attr *DMXShutter) Level(shutter Shutter) (dmx.DMXLevel, error) {
+  width := len(attr.def.Channels)
+
+  if shutter.State == Strobe && attr.converter != nil {
+    return converter.Level(width, attr.converter.DMX(shutter.Rate)), nil
+  }
+
+  r, exists := attr.ranges[shutter.State]
+  if !exists {
+    return 0, errors.New("Attribute " + attr.def.Name + " has no " + shutter.State.String() + " range")
+  }
+
+  return converter.Level(width, float64(r.From + r.To + 1) / 2), nil
+}
+
+func (attr *DMXShutter) SetValue(shutter Shutter) {
+  attr.input <- shutter
+}
+
+func (attr *DMXShutter) SetOpen() {
+  attr.SetValue(Shutter{State: Open})
+}
+
+func (attr *DMXShutter) SetClosed() {
+  attr.SetValue(Shutter{State: Closed})
+}
+
+// Strobe at a rate in Hz, limited to the rates the fixture can make
+func (attr *DMXShutter) SetStrobe(rate float64) {
+  attr.SetValue(Shutter{State: Strobe, Rate: rate})
+}
+
+func (attr *DMXShutter) Value() Shutter {
+  attr.valueLock.Lock()
+  defer attr.valueLock.Unlock()
+  return attr.value
+}
+
+/*
+Get a new input for the attribute. Inputs are mixed latest takes precedence and
+the shutter returns to its starting state when every input has been closed.
+*/
+func (attr *DMXShutter) Input() chan Shutter {
+  c := make(chan Shutter)
+  attr.mixer.AddInput(c)
+  return c
+}
+
+func (attr *DMXShutter) Parameters() map[string] fixture.Parameter {
+  return map[string] fixture.Parameter{attr.def.Name: attr.param}
+}
+
+func (attr *DMXShutter) DMXOut() *dmxfixture.DMXMultiParam {
+  return attr.param
+}
@@ -0,0 +1,158 @@
+/*
+Colour attribute
+
+Drives the colour mixing emitters of a fixture from a color.Colour. The
+attribute has a parameter for each emitter in the fixture's layout, so the same
+programmed colour gives matching levels on RGB, RGBW, RGBA, RGBAW and CMY
+fixtures. Parameters take levels and can be patched to 8 bit channels or wider
+dmx.DMXMultiChannels.
+
+Fixtures without a dimmer, such as the cells of a pixel bar, are dimmed by
+scaling the emitters with SetIntensity. The programmed colour is kept, so
+taking the intensity back to full restores it exactly.
+*/
+package dmxcolor
+
+import (
+  "math"
+  "sync"
+  "golx/fixture"
+  "golx/fixture/mixer"
+  "golx/dmx"
+  "golx/dmx/dmxfixture"
+  "golx/data/color"
+)
+
+type DMXColour struct {
+  fixture fixture.Fixture
+  layout color.Layout
+  params []*dmxfixture.DMXMultiParam
+  mixer *mixer.LTPMixer
+
+  input chan color.Colour
+  refresh chan bool
+
+  value color.Colour
+  intensity float64
+  valueLock sync.Mutex
+}
+
+// Build a colour attribute for a fixture with the given emitters, starting at white
+func NewDMXColour(fixture fixture.Fixture, layout color.Layout) *DMXColour {
+  attr := new(DMXColour)
+  attr.fixture = fixture
+  attr.layout = append(color.Layout{}, layout...)
+  attr.value = color.White
+  attr.intensity = 1
+
+  for range layout {
+    param := dmxfixture.NewDMXMultiParam(attr)
+    param.SetClass(dmx.ColourClass)
+    attr.params = append(attr.params, param)
+  }
+
+  attr.input = make(chan color.Colour)
+  attr.refresh = make(chan bool)
+  attr.mixer, _ = mixer.NewLTPMixer(attr.input, color.White)
+
+  go func() {
+    attr.output()
+
+    for {
+      select {
+      case val, ok := <-attr.input:
+        if !ok {
+          return
+        }
+        attr.valueLock.Lock()
+        attr.value = val
+        attr.valueLock.Unlock()
+        attr.output()
+      case _ = <-attr.refresh:
+        attr.output()
+      }
+    }
+  }()
+
+  return attr
+}
+
+// Set the emitters from the colour scaled by the intensity
+func (attr *DMXColour) output() {
+  attr.valueLock.Lock()
+  val := attr.value.Clamp().Scale(attr.intensity)
+  attr.valueLock.Unlock()
+
+  for i, level := range val.Levels(attr.layout) {
+    attr.params[i].SetValue(dmx.DMXLevel(level))
+  }
+}
+
+func (attr *DMXColour) Fixture() fixture.Fixture {
+  return attr.fixture
+}
+
+func (attr *DMXColour) Layout() color.Layout {
+  return append(color.Layout{}, attr.layout...)
+}
+
+func (attr *DMXColour) SetValue(val color.Colour) {
+  attr.input <- val
+}
+
+/*
+The colour as programmed, which may be outside the fixture's gamut. The
+emitters are set to the nearest colour in gamut.
+*/
+func (attr *DMXColour) Value() color.Colour {
+  attr.valueLock.Lock()
+  defer attr.valueLock.Unlock()
+  return attr.value
+}
+
+/*
+Scale the emitters by an intensity from 0 to 1 without changing the programmed
+colour
+*/
+func (attr *DMXColour) SetIntensity(level float64) {
+  attr.valueLock.Lock()
+  attr.intensity = math.Max(0, math.Min(1, level))
+  attr.valueLock.Unlock()
+
+  attr.refresh <- true
+}
+
+func (attr *DMXColour) Intensity() float64 {
+  attr.valueLock.Lock()
+  defer attr.valueLock.Unlock()
+  return attr.intensity
+}
+
+/*
+Get a new input for the attribute. Inputs are mixed latest takes precedence and
+the attribute returns to white when every input has been closed.
+*/
+func (attr *DMXColour) Input() chan color.Colour {
+  c := make(chan color.Colour)
+  attr.mixer.AddInput(c)
+  return c
+}
+
+// Parameters by emitter name, e.g. red or amber
+func (attr *DMXColour) Parameters() map[string] fixture.Parameter {
+  params := make(map[string] fixture.Parameter)
+  for i, emitter := range attr.layout {
+    params[emitter.String()] = attr.params[i]
+  }
+  return params
+}
+
+// The parameter for an emitter, or nil if the fixture doesn't have it
+func (attr *DMXColour) DMXOut(emitter color.Emitter) *dmxfixture.DMXMultiParam {
+  for i, e := range attr.layout {
+    if e == emitter {
+      return attr.params[i]
+    }
+  }
+  return nil
+}
@@ -0,0 +1,72 @@
+package dmxcolor
+
+import (
+  "testing"
+  "golx/dmx/dmxtest"
+  "golx/data/color"
+)
+
+func TestEmitterLevels(t *testing.T) {
+  attr := NewDMXColour(nil, color.LayoutRGBAW)
+
+  amber := attr.DMXOut(color.AmberEmitter).Output()
+  red := attr.DMXOut(color.RedEmitter).Output()
+
+  // Starts at white, all from the white emitter
+  if !dmxtest.WaitForLevel(attr.DMXOut(color.WhiteEmitter).Output(), 1) {
+    t.Log("White emitter did not start at full")
+    t.Fail()
+  }
+
+  input := attr.Input()
+  input <- color.RGB(1, 0.5, 0)
+
+  if !dmxtest.WaitForLevel(amber, 1) || !dmxtest.WaitForLevel(red, 0) {
+    t.Log("Amber was not taken from the colour")
+    t.Fail()
+  }
+
+  if attr.Value() != color.RGB(1, 0.5, 0) {
+    t.Log("Value is ", attr.Value())
+    t.Fail()
+  }
+
+  // Releasing the input returns to white
+  close(input)
+
+  if !dmxtest.WaitForLevel(amber, 0) {
+    t.Log("Colour did not return to white when released")
+    t.Fail()
+  }
+
+  if attr.DMXOut(color.CyanEmitter) != nil || len(attr.Parameters()) != 5 {
+    t.Log("Parameters don't match the layout: ", attr.Parameters())
+    t.Fail()
+  }
+}
+
+func TestIntensity(t *testing.T) {
+  attr := NewDMXColour(nil, color.LayoutRGB)
+  red := attr.DMXOut(color.RedEmitter).Output()
+  green := attr.DMXOut(color.GreenEmitter).Output()
+
+  attr.SetValue(color.RGB(1, 0.5, 0))
+  attr.SetIntensity(0.5)
+
+  if !dmxtest.WaitForLevel(red, 0.5) || !dmxtest.WaitForLevel(green, 0.25) {
+    t.Log("Emitters were not scaled by the intensity")
+    t.Fail()
+  }
+
+  if attr.Value() != color.RGB(1, 0.5, 0) || attr.Intensity() != 0.5 {
+    t.Log("Scaling changed the colour to ", attr.Value())
+    t.Fail()
+  }
+
+  attr.SetIntensity(1)
+
+  if !dmxtest.WaitForLevel(red, 1) || !dmxtest.WaitForLevel(green, 0.5) {
+    t.Log("Colour was not restored at full intensity")
+    t.Fail()
+  }
+}
@@ -32,6 +32,11 @@ func (dimmer *DMXDimmer) Output() chan dmx.DMXValue {
   return dimmer.attr.DMXOut().Output()
 }
 
+// Dimmers are always intensity, so the grand master scales them
+func (dimmer *DMXDimmer) Class() dmx.ParamClass {
+  return dimmer.attr.DMXOut().Class()
+}
+
 func (dimmer *DMXDimmer) Value() intensity.Intensity {
   return dimmer.Value()
 }
@@ -0,0 +1,106 @@
+package dmxfixture
+
+import (
+  "sync"
+  "golx/fixture"
+  "golx/dmx"
+  "golx/dmx/curve"
+  "golx/patch/chanutil"
+)
+
+/*
+A parameter that takes a normalised level rather than a single DMX value. Patch
+it to a dmx.DMXMultiChannel to output with 16 or more bits of resolution, or to
+a channel one slot wide for 8 bits.
+*/
+type DMXMultiParam struct {
+  value dmx.DMXLevel
+  attr fixture.Attribute
+  curve curve.Curve
+  class dmx.ParamClass
+  lock sync.Mutex
+  output chan dmx.DMXLevel
+  publicOutput chan dmx.DMXLevel
+}
+
+func NewDMXMultiParam(attr fixture.Attribute) *DMXMultiParam {
+  param := new(DMXMultiParam)
+
+  param.value = dmx.DMXLevel(0)
+  param.attr = attr
+
+  param.publicOutput = make(chan dmx.DMXLevel)
+  param.output = make(chan dmx.DMXLevel)
+  chanutil.DeliverWhenPossible(param.output, param.publicOutput)
+
+  return param
+}
+
+/*
+The Attribute that created and updates this parameter
+*/
+func (param *DMXMultiParam) Attribute() fixture.Attribute {
+  return param.attr
+}
+
+/*
+The last level the parameter was set to. Intended to be used for display only.
+*/
+func (param *DMXMultiParam) Value() dmx.DMXLevel {
+  param.lock.Lock()
+  defer param.lock.Unlock()
+  return param.value
+}
+
+/*
+Set the parameter to a new level between 0 and 1. This should only be set by
+the attribute that created the parameter.
+*/
+func (param *DMXMultiParam) SetValue(val dmx.DMXLevel) {
+  param.lock.Lock()
+  param.value = val
+  param.lock.Unlock()
+
+  param.output <- val
+}
+
+/*
+Set the curve the parameter's output should be given. Like the class, the
+curve is copied to the DMX channel the parameter is patched to, so set it
+before patching. The universe applies it after its master, so a preheat or a
+switched curve is not scaled by a grand master.
+*/
+func (param *DMXMultiParam) SetCurve(c curve.Curve) {
+  param.lock.Lock()
+  param.curve = c
+  param.lock.Unlock()
+}
+
+func (param *DMXMultiParam) Curve() curve.Curve {
+  param.lock.Lock()
+  defer param.lock.Unlock()
+  return param.curve
+}
+
+/*
+Set the kind of parameter this is. The class is copied to the DMX channel the
+parameter is patched to, so set it before patching.
+*/
+func (param *DMXMultiParam) SetClass(class dmx.ParamClass) {
+  param.lock.Lock()
+  param.class = class
+  param.lock.Unlock()
+}
+
+func (param *DMXMultiParam) Class() dmx.ParamClass {
+  param.lock.Lock()
+  defer param.lock.Unlock()
+  return param.class
+}
+
+/*
+Get the channel that this parameter outputs on
+*/
+func (param *DMXMultiParam) Output() chan dmx.DMXLevel {
+  return param.publicOutput
+}
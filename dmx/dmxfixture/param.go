@@ -9,8 +9,10 @@ package dmxfixture
 
 import (
   "fmt"
+  "sync"
   "golx/fixture"
   "golx/dmx"
+  "golx/dmx/curve"
   "golx/patch/chanutil"
 )
 
@@ -21,6 +23,9 @@ const (
 type DMXParam struct {
   value dmx.DMXValue
   attr fixture.Attribute
+  curve curve.Curve
+  class dmx.ParamClass
+  lock sync.Mutex
   output chan dmx.DMXValue
   publicOutput chan dmx.DMXValue
 }
@@ -53,6 +58,8 @@ The last value the paramaeter was set to. Intended to be used for display only.
 Patch to the output for all other uses.
 */
 func (param *DMXParam) Value() dmx.DMXValue {
+  param.lock.Lock()
+  defer param.lock.Unlock()
   return param.value
 }
 
@@ -62,11 +69,47 @@ created the parameter.
 */
 func (param *DMXParam) SetValue(val dmx.DMXValue) {
   fmt.Println("DMXParam got data")
+  param.lock.Lock()
   param.value = val
+  param.lock.Unlock()
 
   param.output <- val
 }
 
+/*
+Set the curve the parameter's output should be given. Like the class, the
+curve is copied to the DMX channel the parameter is patched to, so set it
+before patching. The universe applies it after its master, so a preheat or a
+switched curve is not scaled by a grand master.
+*/
+func (param *DMXParam) SetCurve(c curve.Curve) {
+  param.lock.Lock()
+  param.curve = c
+  param.lock.Unlock()
+}
+
+func (param *DMXParam) Curve() curve.Curve {
+  param.lock.Lock()
+  defer param.lock.Unlock()
+  return param.curve
+}
+
+/*
+Set the kind of parameter this is. The class is copied to the DMX channel the
+parameter is patched to, so set it before patching.
+*/
+func (param *DMXParam) SetClass(class dmx.ParamClass) {
+  param.lock.Lock()
+  param.class = class
+  param.lock.Unlock()
+}
+
+func (param *DMXParam) Class() dmx.ParamClass {
+  param.lock.Lock()
+  defer param.lock.Unlock()
+  return param.class
+}
+
 /*
 Get the channel that this parameter outputs on
 */
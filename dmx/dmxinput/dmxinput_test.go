@@ -0,0 +1,199 @@
+package dmxinput
+
+import (
+  "testing"
+  "time"
+  "golx/dmx"
+  "golx/dmx/dmxintensity"
+  "golx/data/intensity"
+  "golx/patch"
+)
+
+func frame(values ...dmx.DMXValue) dmx.DMXFrame {
+  return dmx.DMXFrame(values)
+}
+
+func TestTriggersFireOnTransitions(t *testing.T) {
+  m := NewMonitor()
+  defer m.Stop()
+
+  threshold := NewThreshold(1, 128)
+  edge := NewRisingEdge(2)
+  band := NewBand(3, 100, 150)
+
+  fired := make(chan string, 10)
+  threshold.OnFire(func(e *Event) { fired <- "threshold" })
+  threshold.OnRelease(func(e *Event) { fired <- "threshold released" })
+  edge.OnFire(func(e *Event) { fired <- "edge" })
+  band.OnFire(func(e *Event) { fired <- "band" })
+
+  m.AddTrigger(threshold)
+  m.AddTrigger(edge)
+  m.AddTrigger(band)
+
+  // The first frame only sets the state
+  m.Input() <- frame(200, 0, 0)
+  m.Input() <- frame(100, 255, 120)
+  m.Input() <- frame(100, 0, 200)
+  m.Input() <- frame(255, 10, 140)
+
+  expected := []string{"threshold released", "edge", "band", "threshold", "edge", "band"}
+
+  for _, name := range expected {
+    select {
+    case got := <-fired:
+      if got != name {
+        t.Log("Got ", got, " expected ", name)
+        t.Fail()
+      }
+    case _ = <-time.After(time.Second):
+      t.Log("Trigger did not fire: ", name)
+      t.FailNow()
+    }
+  }
+
+  select {
+  case got := <-fired:
+    t.Log("Unexpected trigger: ", got)
+    t.Fail()
+  default:
+  }
+}
+
+func TestActionsCanUseMonitor(t *testing.T) {
+  m := NewMonitor()
+  defer m.Stop()
+
+  threshold := NewThreshold(1, 128)
+  values := make(chan dmx.DMXValue, 1)
+  threshold.OnFire(func(e *Event) {
+    m.AddTrigger(NewRisingEdge(2))
+    values <- m.Value(2)
+  })
+  m.AddTrigger(threshold)
+
+  m.Input() <- frame(0, 0)
+  m.Input() <- frame(200, 50)
+
+  select {
+  case val := <-values:
+    if val != 50 {
+      t.Log("Action read ", val, " expected 50")
+      t.Fail()
+    }
+  case _ = <-time.After(time.Second):
+    t.Log("Action could not call the monitor")
+    t.FailNow()
+  }
+}
+
+func TestTriggerOutput(t *testing.T) {
+  m := NewMonitor()
+  defer m.Stop()
+
+  trigger := NewRisingEdge(1)
+  m.AddTrigger(trigger)
+
+  m.Input() <- frame(0)
+  m.Input() <- frame(50)
+
+  select {
+  case level := <-trigger.Output():
+    if level != 1 {
+      t.Log("Active trigger output ", level)
+      t.Fail()
+    }
+  case _ = <-time.After(time.Second):
+    t.Log("Trigger did not output its state")
+    t.Fail()
+  }
+}
+
+func TestQuietStateIsOutput(t *testing.T) {
+  m := NewMonitor()
+  defer m.Stop()
+
+  m.Input() <- frame(200)
+
+  threshold := NewThreshold(1, 128)
+  threshold.OnFire(func(e *Event) {
+    t.Log("Matching the current state called an action")
+    t.Fail()
+  })
+  m.AddTrigger(threshold)
+
+  select {
+  case level := <-threshold.Output():
+    if level != 1 {
+      t.Log("Trigger added over its threshold output ", level)
+      t.Fail()
+    }
+  case _ = <-time.After(time.Second):
+    t.Log("Trigger added over its threshold did not output its state")
+    t.Fail()
+  }
+}
+
+func TestRisingEdgeNeedsARise(t *testing.T) {
+  m := NewMonitor()
+  defer m.Stop()
+
+  m.Input() <- frame(200)
+
+  edge := NewRisingEdge(1)
+  fired := make(chan bool, 10)
+  edge.OnFire(func(e *Event) { fired <- true })
+  edge.OnRelease(func(e *Event) { fired <- false })
+  m.AddTrigger(edge)
+
+  // Falling to a level above zero releases and the next rise fires again
+  m.Input() <- frame(150)
+  m.Input() <- frame(100)
+  m.Input() <- frame(180)
+
+  select {
+  case active := <-fired:
+    if !active {
+      t.Log("Rising edge was active before the channel rose")
+      t.Fail()
+    }
+  case _ = <-time.After(time.Second):
+    t.Log("Rising edge did not fire")
+    t.FailNow()
+  }
+
+  select {
+  case active := <-fired:
+    t.Log("Unexpected call, active ", active)
+    t.Fail()
+  case _ = <-time.After(50 * time.Millisecond):
+  }
+}
+
+func TestLevelTakesOverAttribute(t *testing.T) {
+  m := NewMonitor()
+  defer m.Stop()
+
+  attr := dmxintensity.NewDMXIntensity(nil)
+  watch := attr.Watch()
+
+  if err := patch.Patch(m.Level(4), attr); err != nil {
+    t.Log("Error patching level: ", err.Error())
+    t.FailNow()
+  }
+
+  m.Input() <- frame(0, 0, 0, 51)
+
+  timeout := time.After(time.Second)
+  for {
+    select {
+    case val := <-watch:
+      if val == intensity.Intensity(0.2) {
+        return
+      }
+    case _ = <-timeout:
+      t.Log("Incoming level did not reach the attribute")
+      t.FailNow()
+    }
+  }
+}
@@ -0,0 +1,239 @@
+/*
+DMX input monitoring
+
+A Monitor reacts to the levels recieved from another console or panel. Patch
+anything that outputs DMX frames, such as an ArtnetUniverse, to the monitor's
+Input, then add triggers to fire actions on incoming levels or patch a Level to
+an attribute to let the desk take over a fixture.
+
+The first frame recieved only sets the state of the triggers, so a panel that
+is already at full when GoLX starts doesn't fire everything at once.
+*/
+package dmxinput
+
+import (
+  "golx/dmx"
+  "golx/data/intensity"
+  "golx/patch/chanutil"
+)
+
+type Monitor struct {
+  input chan dmx.DMXFrame
+
+  triggers chan triggerRequest
+  levels chan levelRequest
+  reads chan readRequest
+
+  // Actions for the triggers that changed, queued to be called in order
+  fired chan *firing
+
+  stop chan bool
+}
+
+type triggerRequest struct {
+  trigger *Trigger
+  add bool
+}
+
+type levelRequest struct {
+  channel int
+  reply chan *Level
+}
+
+type readRequest struct {
+  channel int
+  reply chan dmx.DMXValue
+}
+
+/*
+The level of an incoming channel as an intensity, for patching to an
+attribute's input
+*/
+type Level struct {
+  channel int
+  output chan intensity.Intensity
+  publicOutput chan intensity.Intensity
+}
+
+func NewMonitor() *Monitor {
+  m := new(Monitor)
+  m.input = make(chan dmx.DMXFrame)
+  m.triggers = make(chan triggerRequest)
+  m.levels = make(chan levelRequest)
+  m.reads = make(chan readRequest)
+  m.fired = make(chan *firing)
+  m.stop = make(chan bool)
+
+  calls := make(chan *firing)
+  go queueFirings(m.fired, calls)
+  go callActions(calls)
+
+  go m.run()
+
+  return m
+}
+
+func (m *Monitor) String() string {
+  return "DMXInputMonitor"
+}
+
+func (m *Monitor) Input() chan dmx.DMXFrame {
+  return m.input
+}
+
+// Start checking a trigger against incoming frames
+func (m *Monitor) AddTrigger(trigger *Trigger) {
+  m.triggers <- triggerRequest{trigger, true}
+}
+
+func (m *Monitor) RemoveTrigger(trigger *Trigger) {
+  m.triggers <- triggerRequest{trigger, false}
+}
+
+/*
+Get the level of an incoming channel. The level is sent each time the channel
+changes and skipped if it isn't read before the next change.
+*/
+func (m *Monitor) Level(channel int) *Level {
+  req := levelRequest{channel, make(chan *Level)}
+  m.levels <- req
+  return <-req.reply
+}
+
+// The last value recieved for a channel
+func (m *Monitor) Value(channel int) dmx.DMXValue {
+  req := readRequest{channel, make(chan dmx.DMXValue)}
+  m.reads <- req
+  return <-req.reply
+}
+
+func (m *Monitor) Stop() {
+  m.stop <- true
+}
+
+func (level *Level) Channel() int {
+  return level.channel
+}
+
+func (level *Level) Output() chan intensity.Intensity {
+  return level.publicOutput
+}
+
+func toIntensity(val dmx.DMXValue) intensity.Intensity {
+  return intensity.Intensity(float64(val) / 255)
+}
+
+func (m *Monitor) run() {
+  current := make(dmx.DMXFrame, dmx.UniverseSize)
+  recieved := false
+
+  triggers := make(map[int] map[*Trigger] bool)
+  levels := make(map[int] *Level)
+
+  for {
+    select {
+    case frame := <-m.input:
+      for i, val := range frame {
+        if i >= dmx.UniverseSize {
+          break
+        }
+
+        channel := i + 1
+
+        if recieved && val == current[i] {
+          continue
+        }
+
+        current[i] = val
+
+        for trigger := range triggers[channel] {
+          if f := trigger.update(val, !recieved); f != nil {
+            m.fired <- f
+          }
+        }
+
+        if level, exists := levels[channel]; exists {
+          level.output <- toIntensity(val)
+        }
+      }
+
+      recieved = true
+    case req := <-m.triggers:
+      channel := req.trigger.channel
+
+      if req.add {
+        if triggers[channel] == nil {
+          triggers[channel] = make(map[*Trigger] bool)
+        }
+        triggers[channel][req.trigger] = true
+
+        // Match the current state without firing
+        if recieved && channel >= 1 && channel <= dmx.UniverseSize {
+          req.trigger.update(current[channel - 1], true)
+        }
+      } else {
+        delete(triggers[channel], req.trigger)
+      }
+    case req := <-m.levels:
+      level, exists := levels[req.channel]
+
+      if !exists {
+        level = &Level{req.channel, make(chan intensity.Intensity), make(chan intensity.Intensity)}
+        chanutil.DeliverWhenPossible(level.output, level.publicOutput)
+        levels[req.channel] = level
+
+        if recieved && req.channel >= 1 && req.channel <= dmx.UniverseSize {
+          level.output <- toIntensity(current[req.channel - 1])
+        }
+      }
+
+      req.reply <- level
+    case req := <-m.reads:
+      if req.channel < 1 || req.channel > dmx.UniverseSize {
+        req.reply <- 0
+      } else {
+        req.reply <- current[req.channel - 1]
+      }
+    case _ = <-m.stop:
+      close(m.fired)
+      return
+    }
+  }
+}
+
+/*
+Pass firings from in to out in order, always accepting them promptly so the
+monitor never waits for actions. Out is closed once in is closed and emptied.
+*/
+func queueFirings(in, out chan *firing) {
+  var queue []*firing
+
+  for in != nil || len(queue) > 0 {
+    var send chan *firing
+    var next *firing
+    if len(queue) > 0 {
+      send, next = out, queue[0]
+    }
+
+    select {
+    case f, ok := <-in:
+      if !ok {
+        in = nil
+        continue
+      }
+      queue = append(queue, f)
+    case send <- next:
+      queue = queue[1:]
+    }
+  }
+
+  close(out)
+}
+
+func callActions(calls chan *firing) {
+  for f := range calls {
+    for _, action := range f.actions {
+      action(f.event)
+    }
+  }
+}
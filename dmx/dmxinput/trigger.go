@@ -0,0 +1,183 @@
+/*
+Triggers from incoming DMX levels
+
+A Trigger watches one channel of the frames recieved by a Monitor. It is active
+while its condition holds and fires its actions when it becomes active, e.g.
+when a house light fader is pushed past a threshold or a panel button is
+pressed. Release actions are called when it stops being active.
+
+A trigger's Output sends full while it is active and zero when it is released
+so it can be patched to an attribute's input like any other source. The output
+always follows the state, including when the state is matched without calling
+actions, so a trigger added while its channel is over a threshold outputs full.
+*/
+package dmxinput
+
+import (
+  "fmt"
+  "sync"
+  "golx/dmx"
+  "golx/data/intensity"
+  "golx/patch/chanutil"
+)
+
+// What caused an action to be called
+type Event struct {
+  Trigger *Trigger
+  Channel int
+  Value dmx.DMXValue
+  Active bool
+}
+
+// Something GoLX does in response to an incoming level
+type Action func(event *Event)
+
+type Trigger struct {
+  lock sync.Mutex
+
+  name string
+  channel int
+  test func(last, val dmx.DMXValue, active bool) bool
+
+  // Only used by the monitor's goroutine
+  active bool
+  last dmx.DMXValue
+  seen bool
+
+  fire []Action
+  release []Action
+
+  output chan intensity.Intensity
+  publicOutput chan intensity.Intensity
+}
+
+/*
+Build a trigger whose test is given the channel's previous and new values and
+whether the trigger is active, and returns whether it should be active
+*/
+func newTrigger(name string, channel int, test func(last, val dmx.DMXValue, active bool) bool) *Trigger {
+  trigger := new(Trigger)
+  trigger.name = name
+  trigger.channel = channel
+  trigger.test = test
+
+  trigger.output = make(chan intensity.Intensity)
+  trigger.publicOutput = make(chan intensity.Intensity)
+  chanutil.DeliverWhenPossible(trigger.output, trigger.publicOutput)
+
+  return trigger
+}
+
+// Active while the channel is at or above level, firing as it crosses upwards
+func NewThreshold(channel int, level dmx.DMXValue) *Trigger {
+  return newTrigger(fmt.Sprintf("Threshold %d", level), channel, func(last, val dmx.DMXValue, active bool) bool {
+    return val >= level
+  })
+}
+
+/*
+Fires as the channel rises and is active until it next falls, whatever the
+levels. Unlike a threshold, a channel that is already up when the trigger is
+added doesn't make it active, and a fall that doesn't reach zero followed by a
+rise fires again.
+*/
+func NewRisingEdge(channel int) *Trigger {
+  return newTrigger("Rising edge", channel, func(last, val dmx.DMXValue, active bool) bool {
+    if val == last {
+      return active
+    }
+    return val > last
+  })
+}
+
+/*
+Active while the channel is between low and high inclusive, firing as it
+enters the band from either side. A fader can select between several cues
+with a band for each.
+*/
+func NewBand(channel int, low, high dmx.DMXValue) *Trigger {
+  return newTrigger(fmt.Sprintf("Band %d-%d", low, high), channel, func(last, val dmx.DMXValue, active bool) bool {
+    return val >= low && val <= high
+  })
+}
+
+func (trigger *Trigger) String() string {
+  return fmt.Sprintf("[%s on %d]", trigger.name, trigger.channel)
+}
+
+func (trigger *Trigger) Channel() int {
+  return trigger.channel
+}
+
+/*
+Call action each time the trigger becomes active. Actions are called one at a
+time, in the order their triggers changed, from a goroutine separate from the
+one reading frames, so they can call back into the Monitor. Long running
+actions hold up the ones after them, so they should start their own goroutine.
+*/
+func (trigger *Trigger) OnFire(action Action) {
+  trigger.lock.Lock()
+  defer trigger.lock.Unlock()
+  trigger.fire = append(trigger.fire, action)
+}
+
+// Call action each time the trigger stops being active
+func (trigger *Trigger) OnRelease(action Action) {
+  trigger.lock.Lock()
+  defer trigger.lock.Unlock()
+  trigger.release = append(trigger.release, action)
+}
+
+// Full while the trigger is active and zero otherwise
+func (trigger *Trigger) Output() chan intensity.Intensity {
+  return trigger.publicOutput
+}
+
+// Actions to call for a change of a trigger's state
+type firing struct {
+  actions []Action
+  event *Event
+}
+
+/*
+Check a new value for the trigger's channel, sending the new state to the
+output if it changes and returning the actions to call for it. When quiet is set
+the state and output are updated without returning any actions.
+*/
+func (trigger *Trigger) update(val dmx.DMXValue, quiet bool) *firing {
+  // The first value has nothing to be compared with
+  if !trigger.seen {
+    trigger.last = val
+    trigger.seen = true
+  }
+
+  active := trigger.test(trigger.last, val, trigger.active)
+  trigger.last = val
+
+  if active == trigger.active {
+    return nil
+  }
+
+  trigger.active = active
+
+  level := intensity.Intensity(0)
+  if active {
+    level = 1
+  }
+
+  trigger.output <- level
+
+  if quiet {
+    return nil
+  }
+
+  trigger.lock.Lock()
+  actions := trigger.release
+  if active {
+    actions = trigger.fire
+  }
+  actions = append([]Action{}, actions...)
+  trigger.lock.Unlock()
+
+  return &firing{actions, &Event{trigger, trigger.channel, val, active}}
+}
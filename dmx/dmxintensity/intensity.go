@@ -7,16 +7,21 @@ import (
   "golx/dmx"
   "golx/dmx/dmxfixture"
   "golx/data/intensity"
+  "golx/patch/chanutil"
 )
 
 type DMXIntensity struct {
   fixture fixture.Fixture
   param *dmxfixture.DMXParam
+  fineParam *dmxfixture.DMXMultiParam
   mixer *mixer.LTPMixer
 
   input chan intensity.Intensity
   value intensity.Intensity
 
+  changes chan intensity.Intensity
+  watchers *chanutil.Broadcaster
+
   stop chan bool
 }
 
@@ -26,11 +31,17 @@ func NewDMXIntensity(fixture fixture.Fixture) *DMXIntensity {
   attr := new(DMXIntensity)
   attr.fixture = fixture
   attr.param = dmxfixture.NewDMXParam(attr)
+  attr.fineParam = dmxfixture.NewDMXMultiParam(attr)
+  attr.param.SetClass(dmx.IntensityClass)
+  attr.fineParam.SetClass(dmx.IntensityClass)
 
   attr.input = make(chan intensity.Intensity)
   attr.mixer, _ = mixer.NewLTPMixer(attr.input, intensity.Intensity(0))
   attr.value = 0
 
+  attr.changes = make(chan intensity.Intensity)
+  attr.watchers, _ = chanutil.NewBroadcaster(attr.changes)
+
   go func() {
     for {
       fmt.Println("Waiting for input in intensity")
@@ -38,7 +49,10 @@ func NewDMXIntensity(fixture fixture.Fixture) *DMXIntensity {
       case val := <-attr.input:
         fmt.Println("Got input in intensity")
         attr.value = val
-        go attr.param.SetValue(dmx.DMXValue(float64(val) * float64(255)))
+        // Parameters never block, and setting them in order keeps the last level
+        attr.param.SetValue(dmx.DMXValue(float64(val) * float64(255)))
+        attr.fineParam.SetValue(dmx.DMXLevel(val))
+        attr.changes <- val
         fmt.Println("Done blocking in intensity")
       case _ = <-attr.stop:
         return
@@ -71,6 +85,14 @@ func (attr *DMXIntensity) Input() chan intensity.Intensity {
   return c
 }
 
+/*
+Get a channel that recieves the value of the attribute every time it changes.
+Intermediate values are skipped if the channel is not read promptly.
+*/
+func (attr *DMXIntensity) Watch() chan intensity.Intensity {
+  return attr.watchers.Subscribe().(chan intensity.Intensity)
+}
+
 func (attr *DMXIntensity) Parameters() map[string] fixture.Parameter {
   return map[string] fixture.Parameter{"intensity": attr.param}
 }
@@ -78,3 +100,11 @@ func (attr *DMXIntensity) Parameters() map[string] fixture.Parameter {
 func (attr *DMXIntensity) DMXOut() *dmxfixture.DMXParam {
   return attr.param
 }
+
+/*
+The intensity as a level for patching to a dmx.DMXMultiChannel, used to drive
+16 bit dimmers smoothly
+*/
+func (attr *DMXIntensity) FineOut() *dmxfixture.DMXMultiParam {
+  return attr.fineParam
+}
@@ -0,0 +1,31 @@
+package dmxintensity
+
+import (
+  "testing"
+  "time"
+  "golx/dmx"
+  "golx/dmx/dmxtest"
+  "golx/data/intensity"
+)
+
+func TestFineLevelsInOrder(t *testing.T) {
+  attr := NewDMXIntensity(nil)
+  u, watch := dmxtest.Patched(t, attr.FineOut(), 2)
+
+  for i := 1; i <= 100; i++ {
+    attr.SetValue(intensity.Intensity(float64(i) / 100))
+  }
+
+  if dmxtest.WaitFor(watch, func(f dmx.DMXFrame) bool { return f[0] == 255 && f[1] == 255 }) == nil {
+    t.Log("Intensity did not reach full")
+    t.FailNow()
+  }
+
+  // No earlier level arrives after the last one
+  time.Sleep(50 * time.Millisecond)
+
+  if u.GetChannel(1).Value() != 255 || u.GetChannel(2).Value() != 255 {
+    t.Log("Fine output left at ", u.GetChannel(1).Value(), " ", u.GetChannel(2).Value())
+    t.Fail()
+  }
+}
@@ -0,0 +1,232 @@
+package dmxintensity
+
+import (
+  "math"
+  "sync"
+  "golx/fixture"
+  "golx/fixture/mixer"
+  "golx/dmx"
+  "golx/dmx/dmxcolor"
+  "golx/data/intensity"
+  "golx/patch/chanutil"
+)
+
+/*
+The intensity of a fixture without a dimmer channel, made by scaling the
+emitters of its colour attributes. Inputs are mixed like those of a
+DMXIntensity and the level is multiplied by a master, as intensity channels
+are by their universe. The master can follow that of a universe with
+FollowMaster, or be set by adding the attribute to a dmxmaster.GrandMaster,
+but not both: adding it to a grand master stops it following a universe.
+
+The colours programmed in the colour attributes are never changed, so
+releasing the intensity, or taking it back to full, leaves them as they were.
+*/
+type VirtualIntensity struct {
+  fixture fixture.Fixture
+  colours []*dmxcolor.DMXColour
+  mixer *mixer.LTPMixer
+
+  input chan intensity.Intensity
+  masters chan masterLevel
+
+  changes chan intensity.Intensity
+  watchers *chanutil.Broadcaster
+
+  lock sync.Mutex
+  value intensity.Intensity
+  master float64
+
+  // Stops following the master of a universe, if one is followed
+  unfollow func()
+
+  // Count of universes followed and the one being followed, or 0 for none
+  follows uint64
+  following uint64
+}
+
+/*
+A master level, either set directly or from the universe followed when follow
+is set. Levels from a universe that is no longer followed are ignored.
+*/
+type masterLevel struct {
+  follow uint64
+  level float64
+}
+
+// Build an intensity that scales colours, starting at zero
+func NewVirtualIntensity(fixture fixture.Fixture, colours ...*dmxcolor.DMXColour) *VirtualIntensity {
+  attr := new(VirtualIntensity)
+  attr.fixture = fixture
+  attr.colours = append([]*dmxcolor.DMXColour{}, colours...)
+  attr.master = 1
+
+  attr.input = make(chan intensity.Intensity)
+  attr.masters = make(chan masterLevel)
+  attr.mixer, _ = mixer.NewLTPMixer(attr.input, intensity.Intensity(0))
+
+  attr.changes = make(chan intensity.Intensity)
+  attr.watchers, _ = chanutil.NewBroadcaster(attr.changes)
+
+  attr.output()
+
+  go func() {
+    for {
+      select {
+      case val, ok := <-attr.input:
+        if !ok {
+          return
+        }
+        attr.lock.Lock()
+        attr.value = val
+        attr.lock.Unlock()
+        attr.output()
+        attr.changes <- val
+      case m := <-attr.masters:
+        attr.lock.Lock()
+        current := m.follow == 0 || m.follow == attr.following
+        if current {
+          attr.master = m.level
+        }
+        attr.lock.Unlock()
+
+        if current {
+          attr.output()
+        }
+      }
+    }
+  }()
+
+  return attr
+}
+
+// Scale the colours by the intensity and the master
+func (attr *VirtualIntensity) output() {
+  level := attr.Level()
+  for _, c := range attr.colours {
+    c.SetIntensity(level)
+  }
+}
+
+func (attr *VirtualIntensity) Fixture() fixture.Fixture {
+  return attr.fixture
+}
+
+// The colour attributes scaled by the intensity
+func (attr *VirtualIntensity) Colours() []*dmxcolor.DMXColour {
+  return append([]*dmxcolor.DMXColour{}, attr.colours...)
+}
+
+func (attr *VirtualIntensity) SetValue(val intensity.Intensity) {
+  attr.input <- val
+}
+
+// The intensity as programmed, before the master is applied
+func (attr *VirtualIntensity) Value() intensity.Intensity {
+  attr.lock.Lock()
+  defer attr.lock.Unlock()
+  return attr.value
+}
+
+// The level the colours are scaled by, including the master
+func (attr *VirtualIntensity) Level() float64 {
+  attr.lock.Lock()
+  defer attr.lock.Unlock()
+  return math.Max(0, math.Min(1, float64(attr.value))) * attr.master
+}
+
+/*
+Set the master level from 0 to 1 the intensity is multiplied by. This is how a
+dmxmaster.GrandMaster scales the attribute.
+*/
+func (attr *VirtualIntensity) SetMaster(level float64) {
+  attr.setMaster(0, level)
+}
+
+func (attr *VirtualIntensity) setMaster(follow uint64, level float64) {
+  attr.masters <- masterLevel{follow, math.Max(0, math.Min(1, level))}
+}
+
+/*
+Take the master from u, so the intensity is scaled along with the intensity
+channels of the universe, including by any grand master the universe has been
+added to. Any universe followed before is no longer followed. Don't add the
+attribute to a grand master as well, since that stops it following.
+*/
+func (attr *VirtualIntensity) FollowMaster(u *dmx.DMXUniverse) {
+  watch := u.WatchMaster()
+  stop := make(chan bool)
+
+  attr.lock.Lock()
+  attr.stopFollowing()
+  attr.follows++
+  follow := attr.follows
+  attr.following = follow
+  attr.unfollow = func() {
+    close(stop)
+    u.UnwatchMaster(watch)
+  }
+  attr.lock.Unlock()
+
+  go func() {
+    // Changes from now on are waiting in watch, so none are missed
+    attr.setMaster(follow, u.Master())
+
+    for {
+      select {
+      case level, ok := <-watch:
+        if !ok {
+          return
+        }
+        attr.setMaster(follow, level)
+      case _ = <-stop:
+        return
+      }
+    }
+  }()
+}
+
+/*
+Stop following the master of a universe, keeping the master at its current
+level until it is set. A dmxmaster.GrandMaster calls this when the attribute is
+added to it.
+*/
+func (attr *VirtualIntensity) Unfollow() {
+  attr.lock.Lock()
+  defer attr.lock.Unlock()
+  attr.stopFollowing()
+}
+
+// Must be called with the lock held
+func (attr *VirtualIntensity) stopFollowing() {
+  if attr.unfollow != nil {
+    attr.unfollow()
+    attr.unfollow = nil
+  }
+  attr.following = 0
+}
+
+/*
+Get a new input for the attribute. Inputs are mixed latest takes precedence and
+the intensity returns to zero when every input has been closed.
+*/
+func (attr *VirtualIntensity) Input() chan intensity.Intensity {
+  c := make(chan intensity.Intensity)
+  attr.mixer.AddInput(c)
+  return c
+}
+
+/*
+Get a channel that recieves the value of the attribute every time it changes.
+This is the value as programmed, so changes to the master are not sent; use
+Level for the level including the master. Intermediate values are skipped if
+the channel is not read promptly.
+*/
+func (attr *VirtualIntensity) Watch() chan intensity.Intensity {
+  return attr.watchers.Subscribe().(chan intensity.Intensity)
+}
+
+// The intensity has no parameters of its own, it scales those of its colours
+func (attr *VirtualIntensity) Parameters() map[string] fixture.Parameter {
+  return map[string] fixture.Parameter{}
+}
@@ -0,0 +1,59 @@
+package dmxintensity
+
+import (
+  "testing"
+  "golx/dmx/dmxcolor"
+  "golx/dmx/dmxtest"
+  "golx/data/color"
+)
+
+func TestVirtualIntensity(t *testing.T) {
+  colour := dmxcolor.NewDMXColour(nil, color.LayoutRGB)
+  red := colour.DMXOut(color.RedEmitter).Output()
+  green := colour.DMXOut(color.GreenEmitter).Output()
+
+  attr := NewVirtualIntensity(nil, colour)
+  colour.SetValue(color.RGB(1, 0.5, 0))
+
+  // Starts at zero like an intensity channel
+  if !dmxtest.WaitForLevel(red, 0) {
+    t.Log("Virtual intensity did not start at zero")
+    t.Fail()
+  }
+
+  input := attr.Input()
+  input <- 0.5
+
+  if !dmxtest.WaitForLevel(red, 0.5) || !dmxtest.WaitForLevel(green, 0.25) {
+    t.Log("Colour was not scaled by the intensity")
+    t.Fail()
+  }
+
+  attr.SetMaster(0.5)
+
+  if !dmxtest.WaitForLevel(red, 0.25) || attr.Level() != 0.25 || attr.Value() != 0.5 {
+    t.Log("Master was not applied, level is ", attr.Level())
+    t.Fail()
+  }
+
+  // Releasing the intensity blacks out the colour without changing it
+  close(input)
+
+  if !dmxtest.WaitForLevel(red, 0) {
+    t.Log("Colour was not blacked out when the intensity was released")
+    t.Fail()
+  }
+
+  if colour.Value() != color.RGB(1, 0.5, 0) {
+    t.Log("Releasing the intensity changed the colour to ", colour.Value())
+    t.Fail()
+  }
+
+  attr.SetMaster(1)
+  attr.SetValue(1)
+
+  if !dmxtest.WaitForLevel(red, 1) || !dmxtest.WaitForLevel(green, 0.5) {
+    t.Log("Colour was not restored at full")
+    t.Fail()
+  }
+}
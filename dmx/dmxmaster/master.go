@@ -0,0 +1,318 @@
+/*
+Grand master and blackout
+
+A GrandMaster scales every intensity channel in the universes added to it
+without touching pan, tilt, colour or any other class of channel. Channels are
+tagged as intensity when an intensity parameter is patched to them. Fixtures
+without intensity channels are dimmed by virtual intensities, which are added
+with AddScaled so the grand master scales them too.
+
+The fader level is set through Input in the same way as an attribute and
+defaults to full when nothing is patched to it. Blackout fades the output to
+zero over a given time and fades back up when it is released. The level sent to
+the universes is the fader level multiplied by the blackout fade.
+*/
+package dmxmaster
+
+import (
+  "math"
+  "time"
+  "golx/dmx"
+  "golx/fixture/mixer"
+  "golx/data/intensity"
+)
+
+const (
+  // Fade time used by BlackoutInput until SetBlackoutTime is called
+  DefaultBlackoutTime time.Duration = 0
+)
+
+// Something scaled by the grand master other than a universe, e.g. a virtual intensity
+type Scaled interface {
+  SetMaster(level float64)
+}
+
+/*
+A Scaled that can take its level from elsewhere instead, e.g. a virtual
+intensity following the master of a universe. It stops following when it is
+added to a grand master, so only one of them sets its level.
+*/
+type Follower interface {
+  Scaled
+  Unfollow()
+}
+
+type GrandMaster struct {
+  mixer *mixer.LTPMixer
+  input chan intensity.Intensity
+  blackoutInput chan bool
+
+  fades chan fadeRequest
+  universes chan universeRequest
+  scaled chan scaledRequest
+  blackoutTimes chan time.Duration
+  reads chan chan status
+
+  stop chan bool
+}
+
+type fadeRequest struct {
+  blackout bool
+  fadeTime time.Duration
+}
+
+type universeRequest struct {
+  universe *dmx.DMXUniverse
+  add bool
+}
+
+type scaledRequest struct {
+  scaled Scaled
+  add bool
+}
+
+type status struct {
+  value intensity.Intensity
+  level float64
+  blackout bool
+}
+
+func NewGrandMaster() *GrandMaster {
+  gm := new(GrandMaster)
+
+  gm.input = make(chan intensity.Intensity)
+  gm.mixer, _ = mixer.NewLTPMixer(gm.input, intensity.Intensity(1))
+  gm.blackoutInput = make(chan bool)
+
+  gm.fades = make(chan fadeRequest)
+  gm.universes = make(chan universeRequest)
+  gm.scaled = make(chan scaledRequest)
+  gm.blackoutTimes = make(chan time.Duration)
+  gm.reads = make(chan chan status)
+
+  gm.stop = make(chan bool)
+
+  go gm.run()
+
+  return gm
+}
+
+func (gm *GrandMaster) String() string {
+  return "GrandMaster"
+}
+
+/*
+Scale the intensity channels of a universe. The universe is brought to the
+current level straight away.
+*/
+func (gm *GrandMaster) AddUniverse(u *dmx.DMXUniverse) {
+  gm.universes <- universeRequest{u, true}
+}
+
+// Stop scaling a universe, returning its intensity channels to full
+func (gm *GrandMaster) RemoveUniverse(u *dmx.DMXUniverse) {
+  gm.universes <- universeRequest{u, false}
+}
+
+/*
+Scale s with the universes. It is brought to the current level straight away
+and, if it is a Follower, stops following anything else.
+*/
+func (gm *GrandMaster) AddScaled(s Scaled) {
+  if f, ok := s.(Follower); ok {
+    f.Unfollow()
+  }
+  gm.scaled <- scaledRequest{s, true}
+}
+
+// Stop scaling s, returning it to full
+func (gm *GrandMaster) RemoveScaled(s Scaled) {
+  gm.scaled <- scaledRequest{s, false}
+}
+
+/*
+Get a new input for the fader level. Inputs are mixed latest takes precedence
+and the level returns to full when every input has been closed.
+*/
+func (gm *GrandMaster) Input() chan intensity.Intensity {
+  c := make(chan intensity.Intensity)
+  gm.mixer.AddInput(c)
+  return c
+}
+
+func (gm *GrandMaster) SetValue(val intensity.Intensity) {
+  gm.input <- val
+}
+
+// The fader level, ignoring any blackout
+func (gm *GrandMaster) Value() intensity.Intensity {
+  return gm.read().value
+}
+
+// The level currently sent to the universes, including any blackout fade
+func (gm *GrandMaster) Level() float64 {
+  return gm.read().level
+}
+
+// True from the start of a blackout until it is released
+func (gm *GrandMaster) IsBlackout() bool {
+  return gm.read().blackout
+}
+
+/*
+Fade the intensity channels to zero over fadeTime. Blackout during a release
+fades down from the level already reached.
+*/
+func (gm *GrandMaster) Blackout(fadeTime time.Duration) {
+  gm.fades <- fadeRequest{true, fadeTime}
+}
+
+// Release a blackout, fading back up to the fader level over fadeTime
+func (gm *GrandMaster) Release(fadeTime time.Duration) {
+  gm.fades <- fadeRequest{false, fadeTime}
+}
+
+/*
+A channel for a blackout button. Sending true starts a blackout and false
+releases it, both using the time set with SetBlackoutTime.
+*/
+func (gm *GrandMaster) BlackoutInput() chan bool {
+  return gm.blackoutInput
+}
+
+// Set the fade time used by BlackoutInput
+func (gm *GrandMaster) SetBlackoutTime(fadeTime time.Duration) {
+  gm.blackoutTimes <- fadeTime
+}
+
+// Stop the grand master and return every universe and scaled attribute to full
+func (gm *GrandMaster) Stop() {
+  gm.mixer.Stop()
+  gm.stop <- true
+}
+
+func (gm *GrandMaster) read() status {
+  reply := make(chan status)
+  gm.reads <- reply
+  return <-reply
+}
+
+func (gm *GrandMaster) run() {
+  value := intensity.Intensity(1)
+  blackout := false
+  blackoutTime := DefaultBlackoutTime
+
+  universes := make(map[*dmx.DMXUniverse] bool)
+  scaled := make(map[Scaled] bool)
+
+  // Blackout multiplier, 1 when not blacked out
+  dbo := 1.0
+
+  // Fade in progress, only set while dbo is moving
+  var fadeFrom, fadeTo float64
+  var fadeStart time.Time
+  var fadeTime time.Duration
+  var ticker *time.Ticker
+  var tick <-chan time.Time = nil
+
+  level := func() float64 {
+    return math.Max(0, math.Min(1, float64(value))) * dbo
+  }
+
+  // Last level sent to the universes
+  sent := level()
+
+  update := func() {
+    if l := level(); l != sent {
+      sent = l
+      for u := range universes {
+        u.SetMaster(l)
+      }
+      for s := range scaled {
+        s.SetMaster(l)
+      }
+    }
+  }
+
+  stopFade := func() {
+    if ticker != nil {
+      ticker.Stop()
+      ticker, tick = nil, nil
+    }
+  }
+
+  startFade := func(req fadeRequest) {
+    blackout = req.blackout
+    target := 1.0
+    if blackout {
+      target = 0
+    }
+
+    stopFade()
+
+    if req.fadeTime <= 0 || dbo == target {
+      dbo = target
+      return
+    }
+
+    fadeFrom, fadeTo = dbo, target
+    fadeStart = time.Now()
+
+    // Scale the time by the distance left so a reversed fade keeps its rate
+    fadeTime = time.Duration(float64(req.fadeTime) * math.Abs(target - dbo))
+
+    ticker = time.NewTicker(dmx.DefaultRefreshPeriod)
+    tick = ticker.C
+  }
+
+  for {
+    select {
+    case val := <-gm.input:
+      value = val
+    case req := <-gm.fades:
+      startFade(req)
+    case on := <-gm.blackoutInput:
+      startFade(fadeRequest{on, blackoutTime})
+    case t := <-gm.blackoutTimes:
+      blackoutTime = t
+    case req := <-gm.universes:
+      if req.add {
+        universes[req.universe] = true
+        req.universe.SetMaster(sent)
+      } else {
+        delete(universes, req.universe)
+        req.universe.SetMaster(1)
+      }
+    case req := <-gm.scaled:
+      if req.add {
+        scaled[req.scaled] = true
+        req.scaled.SetMaster(sent)
+      } else {
+        delete(scaled, req.scaled)
+        req.scaled.SetMaster(1)
+      }
+    case reply := <-gm.reads:
+      reply <- status{value, level(), blackout}
+    case now := <-tick:
+      progress := float64(now.Sub(fadeStart)) / float64(fadeTime)
+
+      if progress >= 1 {
+        dbo = fadeTo
+        stopFade()
+      } else {
+        dbo = fadeFrom + (fadeTo - fadeFrom) * progress
+      }
+    case _ = <-gm.stop:
+      stopFade()
+      for u := range universes {
+        u.SetMaster(1)
+      }
+      for s := range scaled {
+        s.SetMaster(1)
+      }
+      return
+    }
+
+    update()
+  }
+}
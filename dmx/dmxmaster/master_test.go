@@ -0,0 +1,350 @@
+package dmxmaster
+
+import (
+  "testing"
+  "time"
+  "golx/dmx"
+  "golx/dmx/curve"
+  "golx/dmx/dmxcolor"
+  "golx/dmx/dmxintensity"
+  "golx/dmx/dmxtest"
+  "golx/data/color"
+  "golx/patch"
+)
+
+func TestMasterScalesOnlyIntensity(t *testing.T) {
+  u := dmx.NewDMXUniverse()
+  watch := u.Watch()
+
+  gm := NewGrandMaster()
+  defer gm.Stop()
+  gm.AddUniverse(u)
+
+  u.GetChannel(1).SetClass(dmx.IntensityClass)
+  u.GetChannel(2).SetClass(dmx.PositionClass)
+  u.GetChannel(1).Input() <- 200
+  u.GetChannel(2).Input() <- 200
+
+  gm.Input() <- 0.5
+
+  frame := dmxtest.WaitFor(watch, func(f dmx.DMXFrame) bool { return f[0] == 100 })
+
+  if frame == nil || frame[1] != 200 {
+    t.Log("Grand master scaled the wrong channels: ", frame)
+    t.Fail()
+  }
+
+  // Channels keep their own values
+  if u.GetChannel(1).Value() != 200 {
+    t.Log("Channel value was changed by the master")
+    t.Fail()
+  }
+}
+
+func TestMasterScalesFineChannels(t *testing.T) {
+  u := dmx.NewDMXUniverse()
+  watch := u.Watch()
+
+  gm := NewGrandMaster()
+  defer gm.Stop()
+  gm.AddUniverse(u)
+
+  channel, _ := u.GetMultiChannel(1, 2, dmx.MSBFirst)
+  channel.SetClass(dmx.IntensityClass)
+  channel.SetValue(1)
+
+  gm.SetValue(0.5)
+
+  // Half of 0xffff, with the fine slot scaled along with the coarse one
+  frame := dmxtest.WaitFor(watch, func(f dmx.DMXFrame) bool { return f[0] == 0x80 && f[1] == 0x00 })
+
+  if frame == nil {
+    t.Log("16 bit channel was not scaled as a whole")
+    t.Fail()
+  }
+}
+
+func TestPatchTagsIntensity(t *testing.T) {
+  u := dmx.NewDMXUniverse()
+
+  attr := dmxintensity.NewDMXIntensity(nil)
+
+  if err := patch.Patch(attr.DMXOut(), u.GetChannel(5)); err != nil {
+    t.Log("Error patching: ", err.Error())
+    t.FailNow()
+  }
+
+  if u.GetChannel(5).Class() != dmx.IntensityClass {
+    t.Log("Patching an intensity parameter did not tag the channel: ", u.GetChannel(5).Class())
+    t.Fail()
+  }
+
+  if u.GetChannel(6).Class() != dmx.UnknownClass {
+    t.Log("Unpatched channel was tagged")
+    t.Fail()
+  }
+}
+
+func TestPatchKeepsAddressCurve(t *testing.T) {
+  u := dmx.NewDMXUniverse()
+  watch := u.Watch()
+
+  // A curve assigned to the address, patched with a parameter that has none
+  u.GetChannel(1).SetCurve(curve.SquareLaw{})
+  u.GetChannel(1).SetClass(dmx.PositionClass)
+
+  attr := dmxintensity.NewDMXIntensity(nil)
+  if err := patch.Patch(attr.DMXOut(), u.GetChannel(1)); err != nil {
+    t.Log("Error patching: ", err.Error())
+    t.FailNow()
+  }
+
+  attr.SetValue(128.0 / 255)
+
+  if dmxtest.WaitFor(watch, func(f dmx.DMXFrame) bool { return f[0] == 64 }) == nil {
+    t.Log("Patching removed the address's curve")
+    t.Fail()
+  }
+
+  // Unpatching clears the class the patch copied and keeps the curve
+  if err := patch.Unpatch(attr.DMXOut(), u.GetChannel(1)); err != nil {
+    t.Log("Error unpatching: ", err.Error())
+    t.FailNow()
+  }
+
+  if u.GetChannel(1).Class() != dmx.UnknownClass {
+    t.Log("Unpatching left the class as ", u.GetChannel(1).Class())
+    t.Fail()
+  }
+
+  u.GetChannel(1).Input() <- 0
+  u.GetChannel(1).Input() <- 128
+
+  if dmxtest.WaitFor(watch, func(f dmx.DMXFrame) bool { return f[0] == 64 }) == nil {
+    t.Log("Unpatching removed the address's curve")
+    t.Fail()
+  }
+}
+
+func TestParameterCurvesFollowMaster(t *testing.T) {
+  u := dmx.NewDMXUniverse()
+  watch := u.Watch()
+
+  gm := NewGrandMaster()
+  defer gm.Stop()
+  gm.AddUniverse(u)
+
+  attr := dmxintensity.NewDMXIntensity(nil)
+  preheat := curve.NewLimit(curve.Linear{}, 0.2, 1)
+  attr.DMXOut().SetCurve(preheat)
+  attr.FineOut().SetCurve(preheat)
+
+  fine, err := u.GetMultiChannel(3, 2, dmx.MSBFirst)
+  if err != nil {
+    t.Log(err.Error())
+    t.FailNow()
+  }
+
+  if err := patch.Patch(attr.DMXOut(), u.GetChannel(1)); err != nil {
+    t.Log("Error patching: ", err.Error())
+    t.FailNow()
+  }
+
+  if err := patch.Patch(attr.FineOut(), fine); err != nil {
+    t.Log("Error patching: ", err.Error())
+    t.FailNow()
+  }
+
+  attr.SetValue(1)
+
+  if dmxtest.WaitFor(watch, func(f dmx.DMXFrame) bool { return f[0] == 255 && f[2] == 255 }) == nil {
+    t.Log("Parameters did not reach full")
+    t.FailNow()
+  }
+
+  // The curve is applied after the master so the preheat is kept in a blackout
+  gm.Input() <- 0
+
+  frame := dmxtest.WaitFor(watch, func(f dmx.DMXFrame) bool { return f[0] == 51 })
+
+  if frame == nil || frame[2] != 51 || frame[3] != 51 {
+    t.Log("Preheat was scaled by the master: ", frame)
+    t.Fail()
+  }
+
+  if attr.DMXOut().Value() != 255 {
+    t.Log("Parameter value was changed by its curve")
+    t.Fail()
+  }
+}
+
+func TestBlackoutFade(t *testing.T) {
+  u := dmx.NewDMXUniverse()
+  watch := u.Watch()
+
+  gm := NewGrandMaster()
+  defer gm.Stop()
+  gm.AddUniverse(u)
+
+  u.GetChannel(1).SetClass(dmx.IntensityClass)
+  u.GetChannel(1).Input() <- 255
+
+  dmxtest.WaitFor(watch, func(f dmx.DMXFrame) bool { return f[0] == 255 })
+
+  gm.Blackout(200 * time.Millisecond)
+
+  if !gm.IsBlackout() {
+    t.Log("Grand master did not report the blackout")
+    t.Fail()
+  }
+
+  // Part way through the fade the channel is neither full nor out
+  between := dmxtest.WaitFor(watch, func(f dmx.DMXFrame) bool { return f[0] > 0 && f[0] < 255 })
+  out := dmxtest.WaitFor(watch, func(f dmx.DMXFrame) bool { return f[0] == 0 })
+
+  if between == nil || out == nil {
+    t.Log("Blackout did not fade out")
+    t.FailNow()
+  }
+
+  // The fader level is unaffected by blackout
+  if gm.Value() != 1 || gm.Level() != 0 {
+    t.Log("Wrong levels during blackout: ", gm.Value(), gm.Level())
+    t.Fail()
+  }
+
+  gm.BlackoutInput() <- false
+
+  if dmxtest.WaitFor(watch, func(f dmx.DMXFrame) bool { return f[0] == 255 }) == nil {
+    t.Log("Releasing the blackout did not restore the level")
+    t.Fail()
+  }
+}
+
+func TestMasterScalesVirtualIntensity(t *testing.T) {
+  u := dmx.NewDMXUniverse()
+  watch := u.Watch()
+
+  colour := dmxcolor.NewDMXColour(nil, color.LayoutRGB)
+  red, _ := u.GetMultiChannel(1, 1, dmx.MSBFirst)
+  if err := patch.Patch(colour.DMXOut(color.RedEmitter), red); err != nil {
+    t.Log("Error patching: ", err.Error())
+    t.FailNow()
+  }
+
+  attr := dmxintensity.NewVirtualIntensity(nil, colour)
+  attr.SetValue(1)
+
+  gm := NewGrandMaster()
+  defer gm.Stop()
+  gm.SetValue(0.5)
+  gm.AddScaled(attr)
+
+  if dmxtest.WaitFor(watch, func(f dmx.DMXFrame) bool { return f[0] == 128 }) == nil {
+    t.Log("Grand master did not scale the virtual intensity")
+    t.Fail()
+  }
+
+  gm.Blackout(0)
+
+  if dmxtest.WaitFor(watch, func(f dmx.DMXFrame) bool { return f[0] == 0 }) == nil {
+    t.Log("Blackout did not take out the virtual intensity")
+    t.Fail()
+  }
+
+  // Removing it returns it to full
+  gm.RemoveScaled(attr)
+
+  if dmxtest.WaitFor(watch, func(f dmx.DMXFrame) bool { return f[0] == 255 }) == nil {
+    t.Log("Virtual intensity was not returned to full")
+    t.Fail()
+  }
+
+  if colour.Value() != color.White || attr.Value() != 1 {
+    t.Log("Grand master changed the programmed values")
+    t.Fail()
+  }
+}
+
+func TestAddingFollowerStopsFollowing(t *testing.T) {
+  u := dmx.NewDMXUniverse()
+  watch := u.Watch()
+
+  colour := dmxcolor.NewDMXColour(nil, color.LayoutRGB)
+  red, _ := u.GetMultiChannel(1, 1, dmx.MSBFirst)
+  if err := patch.Patch(colour.DMXOut(color.RedEmitter), red); err != nil {
+    t.Log("Error patching: ", err.Error())
+    t.FailNow()
+  }
+
+  attr := dmxintensity.NewVirtualIntensity(nil, colour)
+  attr.SetValue(1)
+  attr.FollowMaster(u)
+
+  universeGM := NewGrandMaster()
+  defer universeGM.Stop()
+  universeGM.SetValue(0.5)
+  universeGM.AddUniverse(u)
+  dmxtest.WaitFor(watch, func(f dmx.DMXFrame) bool { return f[0] == 128 })
+
+  gm := NewGrandMaster()
+  defer gm.Stop()
+  gm.AddScaled(attr)
+
+  if dmxtest.WaitFor(watch, func(f dmx.DMXFrame) bool { return f[0] == 255 }) == nil {
+    t.Log("Grand master did not take over the virtual intensity")
+    t.FailNow()
+  }
+
+  // The universe's master no longer reaches it
+  masters := u.WatchMaster()
+  defer u.UnwatchMaster(masters)
+  universeGM.SetValue(0.2)
+  for level := range masters {
+    if level == 0.2 {
+      break
+    }
+  }
+  gm.SetValue(0.8)
+
+  dmxtest.WaitFor(watch, func(f dmx.DMXFrame) bool { return f[0] == 204 })
+  time.Sleep(50 * time.Millisecond)
+
+  if attr.Level() != 0.8 {
+    t.Log("Virtual intensity still follows its universe: ", attr.Level())
+    t.Fail()
+  }
+}
+
+func TestMasterScalesVirtualIntensityThroughUniverse(t *testing.T) {
+  u := dmx.NewDMXUniverse()
+  watch := u.Watch()
+
+  colour := dmxcolor.NewDMXColour(nil, color.LayoutRGB)
+  red, _ := u.GetMultiChannel(1, 1, dmx.MSBFirst)
+  if err := patch.Patch(colour.DMXOut(color.RedEmitter), red); err != nil {
+    t.Log("Error patching: ", err.Error())
+    t.FailNow()
+  }
+
+  attr := dmxintensity.NewVirtualIntensity(nil, colour)
+  attr.SetValue(1)
+  attr.FollowMaster(u)
+
+  gm := NewGrandMaster()
+  defer gm.Stop()
+  gm.SetValue(0.5)
+  gm.AddUniverse(u)
+
+  if dmxtest.WaitFor(watch, func(f dmx.DMXFrame) bool { return f[0] == 128 }) == nil {
+    t.Log("Grand master of the universe did not scale the virtual intensity")
+    t.Fail()
+  }
+
+  gm.RemoveUniverse(u)
+
+  if dmxtest.WaitFor(watch, func(f dmx.DMXFrame) bool { return f[0] == 255 }) == nil {
+    t.Log("Virtual intensity was not returned to full with its universe")
+    t.Fail()
+  }
+}
@@ -0,0 +1,109 @@
+package dmxpixel
+
+import (
+  "sync"
+  "golx/fixture"
+  "golx/dmx/dmxcolor"
+  "golx/data/color"
+)
+
+/*
+A colour attribute that programs a group of colour attributes as one. Each
+input of the group is an input of every member, so a member can still be
+programmed on its own: whichever input was taken most recently takes
+precedence in each member, as with any other attribute. SetValue takes an input
+the first time it is called and keeps it until the group is released.
+*/
+type GroupColour struct {
+  fixture fixture.Fixture
+  members []*dmxcolor.DMXColour
+
+  input chan color.Colour
+  inputLock sync.Mutex
+
+  value color.Colour
+  valueLock sync.Mutex
+}
+
+func NewGroupColour(fixture fixture.Fixture, members []*dmxcolor.DMXColour) *GroupColour {
+  attr := new(GroupColour)
+  attr.fixture = fixture
+  attr.members = append([]*dmxcolor.DMXColour{}, members...)
+  attr.value = color.White
+  return attr
+}
+
+func (attr *GroupColour) Fixture() fixture.Fixture {
+  return attr.fixture
+}
+
+func (attr *GroupColour) Members() []*dmxcolor.DMXColour {
+  return append([]*dmxcolor.DMXColour{}, attr.members...)
+}
+
+func (attr *GroupColour) SetValue(val color.Colour) {
+  attr.inputLock.Lock()
+  defer attr.inputLock.Unlock()
+
+  if attr.input == nil {
+    attr.input = attr.Input()
+  }
+  attr.input <- val
+}
+
+/*
+Close the input taken by SetValue so the members follow their other inputs, or
+their defaults if they have none. A later SetValue takes a new input.
+*/
+func (attr *GroupColour) Release() {
+  attr.inputLock.Lock()
+  defer attr.inputLock.Unlock()
+
+  if attr.input != nil {
+    close(attr.input)
+    attr.input = nil
+  }
+}
+
+// The colour last set through the group
+func (attr *GroupColour) Value() color.Colour {
+  attr.valueLock.Lock()
+  defer attr.valueLock.Unlock()
+  return attr.value
+}
+
+/*
+Get a new input for every member. Closing it closes the input of each member,
+which then follows its other inputs.
+*/
+func (attr *GroupColour) Input() chan color.Colour {
+  c := make(chan color.Colour)
+
+  inputs := make([]chan color.Colour, len(attr.members))
+  for i, member := range attr.members {
+    inputs[i] = member.Input()
+  }
+
+  go func() {
+    for val := range c {
+      attr.valueLock.Lock()
+      attr.value = val
+      attr.valueLock.Unlock()
+
+      for _, input := range inputs {
+        input <- val
+      }
+    }
+
+    for _, input := range inputs {
+      close(input)
+    }
+  }()
+
+  return c
+}
+
+// The group has no parameters of its own, only those of its members
+func (attr *GroupColour) Parameters() map[string] fixture.Parameter {
+  return map[string] fixture.Parameter{}
+}
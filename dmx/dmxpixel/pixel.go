@@ -0,0 +1,214 @@
+/*
+LED battens and pixel bars
+
+A DMXPixelBar is a parent fixture with a cell for each group of emitters. Each
+cell is a fixture of its own with a colour attribute, so it can be selected
+and programmed on its own, while the bar's colour attribute programs every
+cell as one. Cells are kept in order along the bar for effects and pixel
+mapping.
+
+The bar's master intensity either drives a master dimmer channel on the
+fixture or, for fixtures without one, is a dmxintensity.VirtualIntensity that
+dims every cell by scaling its emitters. Either way the colours programmed in
+the cells are kept. A virtual master follows the master of the universe the
+bar is patched to, so it is scaled by a grand master along with the intensity
+channels of that universe. Adding the virtual master to a grand master directly
+stops it following the universe instead.
+*/
+package dmxpixel
+
+import (
+  "errors"
+  "fmt"
+  "golx/fixture"
+  "golx/dmx"
+  "golx/dmx/dmxcolor"
+  "golx/dmx/dmxintensity"
+  "golx/data/color"
+  "golx/data/intensity"
+  "golx/patch"
+)
+
+type MasterMode int
+
+const (
+  // The fixture has a master dimmer channel before its cells
+  ChannelMaster MasterMode = iota
+
+  // The fixture has no master channel, so the master scales every cell
+  VirtualMaster
+)
+
+// The master intensity of a bar, either a DMXIntensity or a VirtualIntensity
+type Master interface {
+  fixture.Attribute
+  SetValue(val intensity.Intensity)
+  Value() intensity.Intensity
+  Input() chan intensity.Intensity
+  Watch() chan intensity.Intensity
+}
+
+type DMXPixelBar struct {
+  mode MasterMode
+  layout color.Layout
+  master Master
+  colour *GroupColour
+  cells []*DMXCell
+}
+
+// A cell of a pixel bar
+type DMXCell struct {
+  bar *DMXPixelBar
+  index int
+  colour *dmxcolor.DMXColour
+}
+
+// Build a bar of count cells, each with the emitters in layout
+func NewDMXPixelBar(count int, layout color.Layout, mode MasterMode) *DMXPixelBar {
+  bar := new(DMXPixelBar)
+  bar.mode = mode
+  bar.layout = append(color.Layout{}, layout...)
+
+  colours := make([]*dmxcolor.DMXColour, count)
+  for i := range colours {
+    cell := &DMXCell{bar: bar, index: i}
+    cell.colour = dmxcolor.NewDMXColour(cell, layout)
+    colours[i] = cell.colour
+    bar.cells = append(bar.cells, cell)
+  }
+
+  bar.colour = NewGroupColour(bar, colours)
+
+  if mode == VirtualMaster {
+    bar.master = dmxintensity.NewVirtualIntensity(bar, colours...)
+  } else {
+    bar.master = dmxintensity.NewDMXIntensity(bar)
+  }
+
+  return bar
+}
+
+func (bar *DMXPixelBar) String() string {
+  return fmt.Sprintf("Pixel bar of %d cells", len(bar.cells))
+}
+
+func (bar *DMXPixelBar) Attributes() map[string] fixture.Attribute {
+  return map[string] fixture.Attribute{"intensity": bar.master, "colour": bar.colour}
+}
+
+// The cells in order along the bar
+func (bar *DMXPixelBar) Children() []fixture.Fixture {
+  children := make([]fixture.Fixture, len(bar.cells))
+  for i, cell := range bar.cells {
+    children[i] = cell
+  }
+  return children
+}
+
+// The cells in order along the bar
+func (bar *DMXPixelBar) Cells() []*DMXCell {
+  return append([]*DMXCell{}, bar.cells...)
+}
+
+// The cell at index, counting from 0, or nil if there isn't one
+func (bar *DMXPixelBar) Cell(index int) *DMXCell {
+  if index < 0 || index >= len(bar.cells) {
+    return nil
+  }
+  return bar.cells[index]
+}
+
+func (bar *DMXPixelBar) MasterMode() MasterMode {
+  return bar.mode
+}
+
+func (bar *DMXPixelBar) Master() Master {
+  return bar.master
+}
+
+// The colour of every cell
+func (bar *DMXPixelBar) Colour() *GroupColour {
+  return bar.colour
+}
+
+/*
+Set the colour of each cell in order, e.g. from a row of an image for pixel
+mapping. Cells beyond the colours given are left as they are.
+*/
+func (bar *DMXPixelBar) SetColours(colours []color.Colour) {
+  for i, c := range colours {
+    if i >= len(bar.cells) {
+      break
+    }
+    bar.cells[i].colour.SetValue(c)
+  }
+}
+
+// The number of channels used, including any master channel
+func (bar *DMXPixelBar) Footprint() int {
+  footprint := len(bar.cells) * len(bar.layout)
+  if bar.mode == ChannelMaster {
+    footprint++
+  }
+  return footprint
+}
+
+/*
+Patch the bar to u starting at address. The master channel, if the bar has
+one, comes first and is followed by the emitters of each cell in order. A
+virtual master follows the master of u.
+*/
+func (bar *DMXPixelBar) Patch(u *dmx.DMXUniverse, address int) error {
+  if address < 1 || address + bar.Footprint() - 1 > dmx.UniverseSize {
+    return fmt.Errorf("%s does not fit at address %d", bar.String(), address)
+  }
+
+  if master, ok := bar.master.(*dmxintensity.DMXIntensity); ok {
+    if err := patch.Patch(master.DMXOut(), u.GetChannel(address)); err != nil {
+      return errors.New("Master: " + err.Error())
+    }
+    address++
+  }
+
+  for _, cell := range bar.cells {
+    for _, emitter := range bar.layout {
+      channel, err := u.GetMultiChannel(address, 1, dmx.MSBFirst)
+      if err != nil {
+        return err
+      }
+
+      if err := patch.Patch(cell.colour.DMXOut(emitter), channel); err != nil {
+        return fmt.Errorf("Cell %d %s: %s", cell.index + 1, emitter.String(), err.Error())
+      }
+      address++
+    }
+  }
+
+  if master, ok := bar.master.(*dmxintensity.VirtualIntensity); ok {
+    master.FollowMaster(u)
+  }
+
+  return nil
+}
+
+func (cell *DMXCell) String() string {
+  return fmt.Sprintf("Cell %d", cell.index + 1)
+}
+
+func (cell *DMXCell) Attributes() map[string] fixture.Attribute {
+  return map[string] fixture.Attribute{"colour": cell.colour}
+}
+
+// The bar the cell is part of
+func (cell *DMXCell) Parent() *DMXPixelBar {
+  return cell.bar
+}
+
+// The position of the cell along the bar, counting from 0
+func (cell *DMXCell) Index() int {
+  return cell.index
+}
+
+func (cell *DMXCell) Colour() *dmxcolor.DMXColour {
+  return cell.colour
+}
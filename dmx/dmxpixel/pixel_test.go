@@ -0,0 +1,202 @@
+package dmxpixel
+
+import (
+  "testing"
+  "golx/dmx"
+  "golx/dmx/dmxtest"
+  "golx/fixture"
+  "golx/data/color"
+  "golx/data/intensity"
+)
+
+// True if channels from first onwards have the values
+func outputs(first int, values ...dmx.DMXValue) func(dmx.DMXFrame) bool {
+  return func(f dmx.DMXFrame) bool {
+    return equal(f[first - 1:first - 1 + len(values)], values)
+  }
+}
+
+// The values of channels from first to last
+func values(u *dmx.DMXUniverse, first, last int) []dmx.DMXValue {
+  result := make([]dmx.DMXValue, 0, last - first + 1)
+  for i := first; i <= last; i++ {
+    result = append(result, u.GetChannel(i).Value())
+  }
+  return result
+}
+
+func equal(a, b []dmx.DMXValue) bool {
+  if len(a) != len(b) {
+    return false
+  }
+  for i := range a {
+    if a[i] != b[i] {
+      return false
+    }
+  }
+  return true
+}
+
+func TestCells(t *testing.T) {
+  bar := NewDMXPixelBar(4, color.LayoutRGB, ChannelMaster)
+
+  children := fixture.Children(bar)
+  if len(children) != 4 || children[2] != bar.Cell(2) || bar.Cell(4) != nil {
+    t.Log("Children are ", children)
+    t.FailNow()
+  }
+
+  cells := fixture.Cells(bar)
+  for i, cell := range cells {
+    if cell.(*DMXCell).Index() != i || cell.(*DMXCell).Parent() != bar {
+      t.Log("Cell ", i, " is out of order")
+      t.Fail()
+    }
+  }
+
+  // A fixture without children is its own only cell
+  if only := fixture.Cells(bar.Cell(0)); len(only) != 1 || only[0] != bar.Cell(0) {
+    t.Log("Cells of a cell are ", only)
+    t.Fail()
+  }
+
+  if bar.Footprint() != 13 || len(bar.Attributes()) != 2 {
+    t.Log("Footprint is ", bar.Footprint())
+    t.Fail()
+  }
+}
+
+func TestGroupAndCellColours(t *testing.T) {
+  u := dmx.NewDMXUniverse()
+  watch := u.Watch()
+  bar := NewDMXPixelBar(2, color.LayoutRGB, ChannelMaster)
+
+  if err := bar.Patch(u, 1); err != nil {
+    t.Log(err.Error())
+    t.FailNow()
+  }
+
+  bar.Master().SetValue(intensity.Intensity(1))
+  bar.Colour().SetValue(color.RGB(1, 0, 0))
+
+  if dmxtest.WaitFor(watch, outputs(1, 255, 255, 0, 0, 255, 0, 0)) == nil {
+    t.Log("Group colour output as ", values(u, 1, 7))
+    t.Fail()
+  }
+
+  // A cell programmed on its own takes precedence until it is released
+  cell := bar.Cell(1).Colour().Input()
+  cell <- color.RGB(0, 0, 1)
+
+  if dmxtest.WaitFor(watch, outputs(2, 255, 0, 0, 0, 0, 255)) == nil {
+    t.Log("Cell colour output as ", values(u, 2, 7))
+    t.Fail()
+  }
+
+  // Once released the cell follows the group again
+  close(cell)
+  bar.Colour().SetValue(color.RGB(0, 1, 0))
+
+  if dmxtest.WaitFor(watch, outputs(2, 0, 255, 0, 0, 255, 0)) == nil {
+    t.Log("Released cell output as ", values(u, 2, 7))
+    t.Fail()
+  }
+
+  // Pixel mapping sets each cell in order
+  bar.SetColours([]color.Colour{color.RGB(1, 0, 0), color.RGB(0, 0, 1), color.White})
+
+  if dmxtest.WaitFor(watch, outputs(2, 255, 0, 0, 0, 0, 255)) == nil {
+    t.Log("Mapped colours output as ", values(u, 2, 7))
+    t.Fail()
+  }
+}
+
+func TestReleaseGroupColour(t *testing.T) {
+  u := dmx.NewDMXUniverse()
+  watch := u.Watch()
+  bar := NewDMXPixelBar(2, color.LayoutRGB, ChannelMaster)
+
+  if err := bar.Patch(u, 1); err != nil {
+    t.Log(err.Error())
+    t.FailNow()
+  }
+
+  bar.Master().SetValue(intensity.Intensity(1))
+  dmxtest.WaitFor(watch, outputs(2, 255, 255, 255, 255, 255, 255))
+
+  bar.Colour().SetValue(color.RGB(1, 0, 0))
+  if dmxtest.WaitFor(watch, outputs(2, 255, 0, 0, 255, 0, 0)) == nil {
+    t.Log("Group colour output as ", values(u, 2, 7))
+    t.FailNow()
+  }
+
+  // Released cells go back to their defaults
+  bar.Colour().Release()
+  if dmxtest.WaitFor(watch, outputs(2, 255, 255, 255, 255, 255, 255)) == nil {
+    t.Log("Released group output as ", values(u, 2, 7))
+    t.Fail()
+  }
+
+  bar.Colour().SetValue(color.RGB(0, 0, 1))
+  if dmxtest.WaitFor(watch, outputs(2, 0, 0, 255, 0, 0, 255)) == nil {
+    t.Log("Group colour after release output as ", values(u, 2, 7))
+    t.Fail()
+  }
+}
+
+func TestVirtualMaster(t *testing.T) {
+  u := dmx.NewDMXUniverse()
+  watch := u.Watch()
+  bar := NewDMXPixelBar(2, color.LayoutRGB, VirtualMaster)
+
+  if err := bar.Patch(u, 10); err != nil {
+    t.Log(err.Error())
+    t.FailNow()
+  }
+
+  bar.Colour().SetValue(color.RGB(1, 0.5, 0))
+
+  // The master starts at zero
+  if dmxtest.WaitFor(watch, outputs(10, 0, 0, 0, 0, 0, 0)) == nil {
+    t.Log("Cells output as ", values(u, 10, 15), " before the master was raised")
+    t.Fail()
+  }
+
+  bar.Master().SetValue(intensity.Intensity(0.5))
+
+  if dmxtest.WaitFor(watch, outputs(10, 128, 64, 0, 128, 64, 0)) == nil {
+    t.Log("Cells output as ", values(u, 10, 15), " at half master")
+    t.Fail()
+  }
+
+  // The master doesn't change the programmed colours
+  if bar.Cell(0).Colour().Value() != color.RGB(1, 0.5, 0) || bar.Colour().Value() != color.RGB(1, 0.5, 0) {
+    t.Log("Colour changed to ", bar.Cell(0).Colour().Value())
+    t.Fail()
+  }
+
+  bar.Master().SetValue(intensity.Intensity(1))
+
+  if dmxtest.WaitFor(watch, outputs(10, 255, 128, 0)) == nil {
+    t.Log("Cells output as ", values(u, 10, 12), " at full")
+    t.Fail()
+  }
+
+  // The virtual master follows the universe it is patched to
+  u.SetMaster(0.5)
+
+  if dmxtest.WaitFor(watch, outputs(10, 128, 64, 0)) == nil {
+    t.Log("Cells output as ", values(u, 10, 12), " at half universe master")
+    t.Fail()
+  }
+
+  if bar.Master().Value() != intensity.Intensity(1) {
+    t.Log("Universe master changed the programmed intensity to ", bar.Master().Value())
+    t.Fail()
+  }
+
+  if err := bar.Patch(u, 508); err == nil {
+    t.Log("Bar was patched past the end of the universe")
+    t.Fail()
+  }
+}
@@ -0,0 +1,250 @@
+/*
+Position attribute
+
+Drives the pan and tilt of a moving light from a position.Position in degrees,
+with 0, 0 the centre of both axes. Each fixture maps positions through its own
+pan and tilt ranges, so the same position points different models the same
+way.
+
+Positions are programmed as if the fixture were standing on the floor. A hung
+fixture, or one mounted on its side, is corrected with SetInvert and SetSwap
+so that programmed positions, and the soft limits that keep beams off the
+audience or scenery, still mean the same thing.
+
+The pan and tilt parameters take levels, so patch them to two slot
+dmx.DMXMultiChannels for coarse and fine output.
+*/
+package dmxposition
+
+import (
+  "errors"
+  "math"
+  "sync"
+  "golx/fixture"
+  "golx/fixture/mixer"
+  "golx/dmx"
+  "golx/dmx/dmxfixture"
+  "golx/data/position"
+)
+
+const (
+  DefaultPanRange = 540
+  DefaultTiltRange = 270
+)
+
+type DMXPosition struct {
+  fixture fixture.Fixture
+  pan *dmxfixture.DMXMultiParam
+  tilt *dmxfixture.DMXMultiParam
+  mixer *mixer.LTPMixer
+
+  input chan position.Position
+  refresh chan bool
+
+  lock sync.Mutex
+  value position.Position
+  panRange float64
+  tiltRange float64
+  invertPan bool
+  invertTilt bool
+  swap bool
+  limited bool
+  min position.Position
+  max position.Position
+}
+
+func NewDMXPosition(fixture fixture.Fixture) *DMXPosition {
+  attr := new(DMXPosition)
+  attr.fixture = fixture
+  attr.panRange = DefaultPanRange
+  attr.tiltRange = DefaultTiltRange
+
+  attr.pan = dmxfixture.NewDMXMultiParam(attr)
+  attr.tilt = dmxfixture.NewDMXMultiParam(attr)
+  attr.pan.SetClass(dmx.PositionClass)
+  attr.tilt.SetClass(dmx.PositionClass)
+
+  attr.input = make(chan position.Position)
+  attr.refresh = make(chan bool)
+  attr.mixer, _ = mixer.NewLTPMixer(attr.input, position.Position{})
+
+  go func() {
+    attr.output()
+
+    for {
+      select {
+      case val, ok := <-attr.input:
+        if !ok {
+          return
+        }
+        attr.lock.Lock()
+        attr.value = val
+        attr.lock.Unlock()
+        attr.output()
+      case _ = <-attr.refresh:
+        attr.output()
+      }
+    }
+  }()
+
+  return attr
+}
+
+// Set the parameters from the current value and settings
+func (attr *DMXPosition) output() {
+  pan, tilt := attr.Levels()
+  attr.pan.SetValue(pan)
+  attr.tilt.SetValue(tilt)
+}
+
+func level(degrees, span float64) dmx.DMXLevel {
+  return dmx.DMXLevel(math.Max(0, math.Min(1, 0.5 + degrees / span)))
+}
+
+// The pan and tilt levels sent to the fixture for the current position
+func (attr *DMXPosition) Levels() (pan, tilt dmx.DMXLevel) {
+  attr.lock.Lock()
+  defer attr.lock.Unlock()
+
+  p := attr.value
+
+  if attr.limited {
+    p.Pan = math.Max(attr.min.Pan, math.Min(attr.max.Pan, p.Pan))
+    p.Tilt = math.Max(attr.min.Tilt, math.Min(attr.max.Tilt, p.Tilt))
+  }
+
+  if attr.invertPan {
+    p.Pan = -p.Pan
+  }
+  if attr.invertTilt {
+    p.Tilt = -p.Tilt
+  }
+  if attr.swap {
+    p.Pan, p.Tilt = p.Tilt, p.Pan
+  }
+
+  return level(p.Pan, attr.panRange), level(p.Tilt, attr.tiltRange)
+}
+
+func (attr *DMXPosition) Fixture() fixture.Fixture {
+  return attr.fixture
+}
+
+func (attr *DMXPosition) SetValue(val position.Position) {
+  attr.input <- val
+}
+
+// The position as programmed, before limits, inversion and swapping
+func (attr *DMXPosition) Value() position.Position {
+  attr.lock.Lock()
+  defer attr.lock.Unlock()
+  return attr.value
+}
+
+/*
+Get a new input for the attribute. Inputs are mixed latest takes precedence and
+the attribute returns to the centre when every input has been closed.
+*/
+func (attr *DMXPosition) Input() chan position.Position {
+  c := make(chan position.Position)
+  attr.mixer.AddInput(c)
+  return c
+}
+
+/*
+The position for pan and tilt levels from 0 to 1 across the fixture's ranges,
+for programming with normalised values
+*/
+func (attr *DMXPosition) Normalised(pan, tilt float64) position.Position {
+  attr.lock.Lock()
+  defer attr.lock.Unlock()
+  return position.Position{Pan: (pan - 0.5) * attr.panRange, Tilt: (tilt - 0.5) * attr.tiltRange}
+}
+
+// Set the position from normalised pan and tilt levels
+func (attr *DMXPosition) SetNormalised(pan, tilt float64) {
+  attr.SetValue(attr.Normalised(pan, tilt))
+}
+
+func (attr *DMXPosition) update(change func()) {
+  attr.lock.Lock()
+  change()
+  attr.lock.Unlock()
+
+  attr.refresh <- true
+}
+
+/*
+Set how far the fixture can pan and tilt from one end to the other, in
+degrees. Both ranges must be more than zero.
+*/
+func (attr *DMXPosition) SetRange(pan, tilt float64) error {
+  if !(pan > 0 && tilt > 0) || math.IsInf(pan, 0) || math.IsInf(tilt, 0) {
+    return errors.New("Pan and tilt ranges must be more than zero")
+  }
+
+  attr.update(func() {
+    attr.panRange = pan
+    attr.tiltRange = tilt
+  })
+
+  return nil
+}
+
+func (attr *DMXPosition) Range() (pan, tilt float64) {
+  attr.lock.Lock()
+  defer attr.lock.Unlock()
+  return attr.panRange, attr.tiltRange
+}
+
+// Reverse the direction of pan or tilt, e.g. for a hung fixture
+func (attr *DMXPosition) SetInvert(pan, tilt bool) {
+  attr.update(func() {
+    attr.invertPan = pan
+    attr.invertTilt = tilt
+  })
+}
+
+// Drive the pan channels with tilt and the tilt channels with pan
+func (attr *DMXPosition) SetSwap(swap bool) {
+  attr.update(func() {
+    attr.swap = swap
+  })
+}
+
+/*
+Keep programmed positions between min and max. Limits are applied before
+inversion and swapping, so they are in the same terms as the positions. Min
+can't be more than max for either pan or tilt.
+*/
+func (attr *DMXPosition) SetLimits(min, max position.Position) error {
+  if !(min.Pan <= max.Pan && min.Tilt <= max.Tilt) {
+    return errors.New("Minimum pan and tilt must not be more than the maximum")
+  }
+
+  attr.update(func() {
+    attr.limited = true
+    attr.min = min
+    attr.max = max
+  })
+
+  return nil
+}
+
+func (attr *DMXPosition) ClearLimits() {
+  attr.update(func() {
+    attr.limited = false
+  })
+}
+
+func (attr *DMXPosition) Parameters() map[string] fixture.Parameter {
+  return map[string] fixture.Parameter{"pan": attr.pan, "tilt": attr.tilt}
+}
+
+func (attr *DMXPosition) PanOut() *dmxfixture.DMXMultiParam {
+  return attr.pan
+}
+
+func (attr *DMXPosition) TiltOut() *dmxfixture.DMXMultiParam {
+  return attr.tilt
+}
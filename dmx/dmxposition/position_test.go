@@ -0,0 +1,153 @@
+package dmxposition
+
+import (
+  "math"
+  "testing"
+  "golx/dmx"
+  "golx/dmx/dmxtest"
+  "golx/data/position"
+  "golx/patch"
+)
+
+func near(a dmx.DMXLevel, b float64) bool {
+  return math.Abs(float64(a) - b) < 1e-9
+}
+
+// Patch pan to channels 1 and 2 and tilt to 3 and 4 of a new universe
+func patched(t *testing.T, attr *DMXPosition) (*dmx.DMXUniverse, chan dmx.DMXFrame) {
+  u := dmx.NewDMXUniverse()
+  watch := u.Watch()
+
+  pan, _ := u.GetMultiChannel(1, 2, dmx.MSBFirst)
+  tilt, _ := u.GetMultiChannel(3, 2, dmx.MSBFirst)
+
+  if err := patch.Patch(attr.PanOut(), pan); err != nil {
+    t.Log(err.Error())
+    t.FailNow()
+  }
+
+  if err := patch.Patch(attr.TiltOut(), tilt); err != nil {
+    t.Log(err.Error())
+    t.FailNow()
+  }
+
+  return u, watch
+}
+
+// True if the frame has the pan and tilt levels at 16 bit resolution
+func outputs(pan, tilt float64) func(dmx.DMXFrame) bool {
+  slots := func(level float64) (dmx.DMXValue, dmx.DMXValue) {
+    raw := uint16(math.Floor(level * 65535 + 0.5))
+    return dmx.DMXValue(raw >> 8), dmx.DMXValue(raw)
+  }
+
+  panCoarse, panFine := slots(pan)
+  tiltCoarse, tiltFine := slots(tilt)
+
+  return func(f dmx.DMXFrame) bool {
+    return f[0] == panCoarse && f[1] == panFine && f[2] == tiltCoarse && f[3] == tiltFine
+  }
+}
+
+func TestPositionLevels(t *testing.T) {
+  attr := NewDMXPosition(nil)
+
+  if pan, tilt := attr.Levels(); !near(pan, 0.5) || !near(tilt, 0.5) {
+    t.Log("Position did not start centred: ", pan, tilt)
+    t.Fail()
+  }
+
+  _, watch := patched(t, attr)
+
+  input := attr.Input()
+  input <- position.Position{Pan: 90, Tilt: -27}
+
+  if dmxtest.WaitFor(watch, outputs(0.5 + 90.0 / 540, 0.4)) == nil {
+    t.Log("Position mapped incorrectly: ")
+    t.Log(attr.Levels())
+    t.Fail()
+  }
+
+  attr.SetInvert(true, false)
+  if dmxtest.WaitFor(watch, outputs(0.5 - 90.0 / 540, 0.4)) == nil {
+    t.Log("Pan was not inverted: ")
+    t.Log(attr.Levels())
+    t.Fail()
+  }
+
+  attr.SetSwap(true)
+  if dmxtest.WaitFor(watch, outputs(0.5 - 27.0 / 540, 0.5 - 90.0 / 270)) == nil {
+    t.Log("Pan and tilt were not swapped: ")
+    t.Log(attr.Levels())
+    t.Fail()
+  }
+
+  attr.SetInvert(false, false)
+  attr.SetSwap(false)
+  if attr.SetLimits(position.Position{Pan: 45, Tilt: -10}, position.Position{Pan: -45, Tilt: 10}) == nil {
+    t.Log("Reversed limits were accepted")
+    t.Fail()
+  }
+
+  attr.SetLimits(position.Position{Pan: -45, Tilt: -10}, position.Position{Pan: 45, Tilt: 10})
+  if dmxtest.WaitFor(watch, outputs(0.5 + 45.0 / 540, 0.5 - 10.0 / 270)) == nil {
+    t.Log("Position was not limited: ")
+    t.Log(attr.Levels())
+    t.Fail()
+  }
+
+  // The programmed value is kept
+  if attr.Value() != (position.Position{Pan: 90, Tilt: -27}) {
+    t.Log("Value changed by the settings: ", attr.Value())
+    t.Fail()
+  }
+
+  close(input)
+  if dmxtest.WaitFor(watch, outputs(0.5, 0.5)) == nil || attr.Value() != (position.Position{}) {
+    t.Log("Position did not return to the centre when released")
+    t.Fail()
+  }
+}
+
+func TestPositionFineOutput(t *testing.T) {
+  attr := NewDMXPosition(nil)
+  _, watch := patched(t, attr)
+
+  attr.SetValue(attr.Normalised(0.25, 0.5))
+
+  // A quarter of 65535 rounds to 16384, coarse 64 and fine 0
+  frame := dmxtest.WaitFor(watch, func(f dmx.DMXFrame) bool { return f[0] == 16384 >> 8 })
+  if frame == nil || frame[1] != 0 {
+    t.Log("Pan output as ", frame)
+    t.Fail()
+  }
+}
+
+func TestPositionRange(t *testing.T) {
+  attr := NewDMXPosition(nil)
+  _, watch := patched(t, attr)
+
+  if err := attr.SetRange(360, 180); err != nil {
+    t.Log(err.Error())
+    t.FailNow()
+  }
+
+  attr.SetValue(position.Position{Pan: 90, Tilt: 45})
+  if dmxtest.WaitFor(watch, outputs(0.75, 0.75)) == nil {
+    t.Log("Range was not used: ")
+    t.Log(attr.Levels())
+    t.Fail()
+  }
+
+  for _, r := range [][2]float64{{0, 180}, {360, -1}, {math.NaN(), 180}, {math.Inf(1), 180}} {
+    if err := attr.SetRange(r[0], r[1]); err == nil {
+      t.Log("Range ", r, " was allowed")
+      t.Fail()
+    }
+  }
+
+  if pan, tilt := attr.Range(); pan != 360 || tilt != 180 {
+    t.Log("Rejected range was kept: ", pan, tilt)
+    t.Fail()
+  }
+}
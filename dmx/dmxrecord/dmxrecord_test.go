@@ -0,0 +1,213 @@
+package dmxrecord
+
+import (
+  "bytes"
+  "testing"
+  "time"
+  "golx/dmx"
+)
+
+func waitFor(watch chan dmx.DMXFrame, timeout time.Duration, done func(dmx.DMXFrame) bool) dmx.DMXFrame {
+  deadline := time.After(timeout)
+
+  for {
+    select {
+    case frame := <-watch:
+      if done(frame) {
+        return frame
+      }
+    case _ = <-deadline:
+      return nil
+    }
+  }
+}
+
+func testRecording() *Recording {
+  rec := new(Recording)
+  rec.duration = 400 * time.Millisecond
+  rec.deltas = []delta{
+    {0, 1, []int{1, 2}, []dmx.DMXValue{10, 20}},
+    {200 * time.Millisecond, 1, []int{1}, []dmx.DMXValue{200}},
+    {200 * time.Millisecond, 2, []int{512}, []dmx.DMXValue{7}},
+  }
+  return rec
+}
+
+func TestSaveAndRead(t *testing.T) {
+  rec := testRecording()
+
+  buf := new(bytes.Buffer)
+  if err := rec.Save(buf); err != nil {
+    t.Log("Error saving: ", err.Error())
+    t.FailNow()
+  }
+
+  read, err := ReadRecording(buf)
+
+  if err != nil {
+    t.Log("Error reading: ", err.Error())
+    t.FailNow()
+  }
+
+  if read.Duration() != rec.Duration() || len(read.Universes()) != 2 {
+    t.Log("Recording read back incorrectly")
+    t.FailNow()
+  }
+
+  frame := read.FrameAt(1, 300 * time.Millisecond)
+  if frame[0] != 200 || frame[1] != 20 || read.FrameAt(2, time.Second)[511] != 7 {
+    t.Log("Deltas read back incorrectly: ", frame[:2])
+    t.Fail()
+  }
+
+  if _, err := ReadRecording(bytes.NewBufferString("GLXR\x01\x05")); err == nil {
+    t.Log("Truncated recording was read without an error")
+    t.Fail()
+  }
+}
+
+func TestRecord(t *testing.T) {
+  u := dmx.NewDMXUniverse()
+  watch := u.Watch()
+
+  // The levels are recorded before the master, which a player's universe applies again
+  u.GetChannel(1).SetClass(dmx.IntensityClass)
+  u.SetMaster(0.5)
+  u.GetChannel(1).Input() <- 50
+  waitFor(watch, time.Second, func(f dmx.DMXFrame) bool { return f[0] == 25 })
+
+  r := Record(map[int] *dmx.DMXUniverse{3: u})
+
+  time.Sleep(50 * time.Millisecond)
+  u.GetChannel(2).Input() <- 60
+  waitFor(watch, time.Second, func(f dmx.DMXFrame) bool { return f[1] == 60 })
+  time.Sleep(50 * time.Millisecond)
+
+  rec := r.Stop()
+
+  // The starting state and one change
+  if len(rec.deltas) != 2 || rec.deltas[0].at != 0 || rec.deltas[0].universe != 3 {
+    t.Log("Recorded deltas are incorrect: ", rec.deltas)
+    t.FailNow()
+  }
+
+  if rec.deltas[0].values[0] != 50 {
+    t.Log("Recorded the output rather than the level: ", rec.deltas[0])
+    t.Fail()
+  }
+
+  if rec.deltas[1].at < 50 * time.Millisecond || rec.deltas[1].channels[0] != 2 {
+    t.Log("Change was recorded incorrectly: ", rec.deltas[1])
+    t.Fail()
+  }
+
+  if rec.Duration() < 100 * time.Millisecond {
+    t.Log("Recording is too short: ", rec.Duration())
+    t.Fail()
+  }
+}
+
+func TestRecordChannelsSetAtZero(t *testing.T) {
+  u := dmx.NewDMXUniverse()
+  watch := u.Watch()
+
+  u.GetChannel(4).Input() <- 50
+  waitFor(watch, time.Second, func(f dmx.DMXFrame) bool { return f[3] == 50 })
+  u.GetChannel(4).Input() <- 0
+  waitFor(watch, time.Second, func(f dmx.DMXFrame) bool { return f[3] == 0 })
+
+  r := Record(map[int] *dmx.DMXUniverse{1: u})
+  time.Sleep(50 * time.Millisecond)
+  rec := r.Stop()
+
+  if channels := rec.Channels(1); len(channels) != 1 || channels[0] != 4 {
+    t.Log("Channel set at zero was not recorded: ", channels)
+    t.FailNow()
+  }
+
+  live := dmx.NewDMXUniverse()
+  liveWatch := live.Watch()
+  live.GetChannel(4).Input() <- 77
+  waitFor(liveWatch, time.Second, func(f dmx.DMXFrame) bool { return f[3] == 77 })
+
+  p := NewPlayer(rec, map[int] *dmx.DMXUniverse{1: live}, 1)
+  defer p.Close()
+  p.Play()
+
+  if waitFor(liveWatch, time.Second, func(f dmx.DMXFrame) bool { return f[3] == 0 }) == nil {
+    t.Log("Playback did not hold the channel at zero")
+    t.Fail()
+  }
+}
+
+func TestPlaybackLoopsAndReleases(t *testing.T) {
+  u := dmx.NewDMXUniverse()
+  watch := u.Watch()
+
+  u.GetChannel(3).Input() <- 99
+
+  p := NewPlayer(testRecording(), map[int] *dmx.DMXUniverse{1: u}, 0)
+  defer p.Close()
+
+  p.Play()
+
+  if waitFor(watch, time.Second, func(f dmx.DMXFrame) bool { return f[0] == 10 && f[1] == 20 }) == nil {
+    t.Log("Playback did not start")
+    t.FailNow()
+  }
+
+  // Channels that aren't in the recording are left alone
+  if u.GetChannel(3).Value() != 99 || u.Snapshot().Value(3) != 99 {
+    t.Log("Playback took over channel 3")
+    t.Fail()
+  }
+
+  if waitFor(watch, time.Second, func(f dmx.DMXFrame) bool { return f[0] == 200 }) == nil {
+    t.Log("Playback did not reach the second delta")
+    t.FailNow()
+  }
+
+  // Back to the start after the loop point
+  if waitFor(watch, time.Second, func(f dmx.DMXFrame) bool { return f[0] == 10 }) == nil {
+    t.Log("Playback did not loop")
+    t.FailNow()
+  }
+
+  // Live control overrides playback at the same priority
+  u.GetChannel(2).Input() <- 5
+  if waitFor(watch, time.Second, func(f dmx.DMXFrame) bool { return f[1] == 5 }) == nil {
+    t.Log("Live control did not override playback")
+    t.Fail()
+  }
+
+  p.Stop()
+
+  if waitFor(watch, time.Second, func(f dmx.DMXFrame) bool { return f[0] == 0 && f[2] == 99 }) == nil {
+    t.Log("Stopping did not release the universe")
+    t.Fail()
+  }
+
+  if p.IsPlaying() {
+    t.Log("Player still reports playing")
+    t.Fail()
+  }
+}
+
+func TestCrossfadeAtLoopPoint(t *testing.T) {
+  u := dmx.NewDMXUniverse()
+  watch := u.Watch()
+
+  p := NewPlayer(testRecording(), map[int] *dmx.DMXUniverse{1: u}, 0)
+  defer p.Close()
+
+  p.SetCrossfade(150 * time.Millisecond)
+  p.PlayAt(time.Now().Add(20 * time.Millisecond))
+
+  waitFor(watch, time.Second, func(f dmx.DMXFrame) bool { return f[0] == 200 })
+
+  // Fading from 200 back to 10 passes through levels in between
+  if waitFor(watch, time.Second, func(f dmx.DMXFrame) bool { return f[0] > 20 && f[0] < 190 }) == nil {
+    t.Log("Loop point was not crossfaded")
+    t.Fail()
+  }
+}
@@ -0,0 +1,292 @@
+package dmxrecord
+
+import (
+  "math"
+  "time"
+  "golx/dmx"
+)
+
+/*
+Plays a recording back into universes by number. Playback is sent through a
+dmx.DMXSource on each universe at the player's priority, so live control at a
+higher priority, or a later change at the same priority, overrides it. Only
+the channels set in the recording are taken by the source, so the rest of each
+universe is left to other sources. Stopping releases the sources and the
+universes return to the live values.
+
+Players loop by default. With a crossfade set, the end of the recording fades
+into its start over the crossfade time so the loop point isn't visible.
+*/
+type Player struct {
+  commands chan playerCommand
+  loops chan bool
+  crossfades chan time.Duration
+  schedules chan schedule
+  reads chan chan playerStatus
+  quit chan bool
+}
+
+type playerCommand int
+
+const (
+  playCommand playerCommand = iota
+  stopCommand
+)
+
+type schedule struct {
+  command playerCommand
+  at time.Time
+}
+
+type playerStatus struct {
+  playing bool
+  position time.Duration
+}
+
+func NewPlayer(rec *Recording, universes map[int] *dmx.DMXUniverse, priority int) *Player {
+  p := new(Player)
+  p.commands = make(chan playerCommand)
+  p.loops = make(chan bool)
+  p.crossfades = make(chan time.Duration)
+  p.schedules = make(chan schedule)
+  p.reads = make(chan chan playerStatus)
+  p.quit = make(chan bool)
+
+  go p.run(rec, universes, priority)
+
+  return p
+}
+
+// Start playing from the beginning
+func (p *Player) Play() {
+  p.commands <- playCommand
+}
+
+// Stop playing and release the universes
+func (p *Player) Stop() {
+  p.commands <- stopCommand
+}
+
+// Start playing at a time, replacing any earlier start time
+func (p *Player) PlayAt(at time.Time) {
+  p.schedules <- schedule{playCommand, at}
+}
+
+// Stop playing at a time, replacing any earlier stop time
+func (p *Player) StopAt(at time.Time) {
+  p.schedules <- schedule{stopCommand, at}
+}
+
+/*
+Loop back to the start at the end of the recording. When not looping the last
+state of the recording is held until the player is stopped.
+*/
+func (p *Player) SetLoop(loop bool) {
+  p.loops <- loop
+}
+
+// Fade the end of the recording into its start over the given time
+func (p *Player) SetCrossfade(fade time.Duration) {
+  p.crossfades <- fade
+}
+
+func (p *Player) IsPlaying() bool {
+  return p.read().playing
+}
+
+// How far through the recording playback is
+func (p *Player) Position() time.Duration {
+  return p.read().position
+}
+
+// Stop playing and end the player
+func (p *Player) Close() {
+  p.quit <- true
+}
+
+func (p *Player) read() playerStatus {
+  reply := make(chan playerStatus)
+  p.reads <- reply
+  return <-reply
+}
+
+func (p *Player) run(rec *Recording, universes map[int] *dmx.DMXUniverse, priority int) {
+  loop := true
+  crossfade := time.Duration(0)
+
+  var sources map[int] *dmx.DMXSource
+  var started time.Time
+  var position time.Duration
+
+  // Playback state, the next delta to apply and the last frames sent
+  var states, sent map[int] dmx.DMXFrame
+  next := 0
+
+  // The start of the recording, faded to at the loop point
+  first := make(map[int] dmx.DMXFrame)
+  for number := range universes {
+    first[number] = rec.FrameAt(number, 0)
+  }
+
+  // Runs of consecutive channels set in the recording, sent in single steps
+  runs := make(map[int] []channelRun)
+  for number := range universes {
+    runs[number] = findRuns(rec.Channels(number))
+  }
+
+  var ticker *time.Ticker
+  var tick <-chan time.Time = nil
+  var playTimer, stopTimer <-chan time.Time = nil, nil
+
+  rewind := func() {
+    states = make(map[int] dmx.DMXFrame)
+    for number := range universes {
+      states[number] = make(dmx.DMXFrame, dmx.UniverseSize)
+    }
+    next = 0
+  }
+
+  stop := func() {
+    if ticker == nil {
+      return
+    }
+
+    ticker.Stop()
+    ticker, tick = nil, nil
+
+    for _, src := range sources {
+      src.Release()
+    }
+    sources = nil
+  }
+
+  // Send the state of the recording at now to the universes
+  update := func(now time.Time) {
+    position = now.Sub(started)
+
+    if position >= rec.duration && rec.duration > 0 && loop {
+      loops := position / rec.duration
+      started = started.Add(loops * rec.duration)
+      position -= loops * rec.duration
+      rewind()
+    }
+
+    for next < len(rec.deltas) && rec.deltas[next].at <= position {
+      d := rec.deltas[next]
+      if state, exists := states[d.universe]; exists {
+        d.applyTo(state)
+      }
+      next++
+    }
+
+    // Proportion of the way through the crossfade into the start
+    fade := 0.0
+    length := crossfade
+    if length > rec.duration {
+      length = rec.duration
+    }
+    if loop && length > 0 && position > rec.duration - length {
+      fade = math.Min(1, float64(position - (rec.duration - length)) / float64(length))
+    }
+
+    for number, state := range states {
+      frame := make(dmx.DMXFrame, dmx.UniverseSize)
+
+      for i := range frame {
+        level := float64(state[i]) * (1 - fade) + float64(first[number][i]) * fade
+        frame[i] = dmx.DMXValue(math.Floor(level + 0.5))
+      }
+
+      for _, r := range runs[number] {
+        if sent[number] == nil || !equal(frame[r.start - 1:r.end], sent[number][r.start - 1:r.end]) {
+          sources[number].SetValues(r.start, frame[r.start - 1:r.end])
+        }
+      }
+      sent[number] = frame
+    }
+  }
+
+  play := func() {
+    stop()
+
+    sources = make(map[int] *dmx.DMXSource)
+    for number, u := range universes {
+      sources[number] = u.NewSource(priority)
+    }
+
+    sent = make(map[int] dmx.DMXFrame)
+    started = time.Now()
+    rewind()
+    update(started)
+
+    ticker = time.NewTicker(dmx.DefaultRefreshPeriod)
+    tick = ticker.C
+  }
+
+  for {
+    select {
+    case command := <-p.commands:
+      if command == playCommand {
+        play()
+      } else {
+        stop()
+      }
+    case s := <-p.schedules:
+      if s.command == playCommand {
+        playTimer = time.After(s.at.Sub(time.Now()))
+      } else {
+        stopTimer = time.After(s.at.Sub(time.Now()))
+      }
+    case _ = <-playTimer:
+      playTimer = nil
+      play()
+    case _ = <-stopTimer:
+      stopTimer = nil
+      stop()
+    case loop = <-p.loops:
+    case crossfade = <-p.crossfades:
+    case now := <-tick:
+      update(now)
+    case reply := <-p.reads:
+      reply <- playerStatus{ticker != nil, position}
+    case _ = <-p.quit:
+      stop()
+      return
+    }
+  }
+}
+
+// Consecutive channels from start to end inclusive
+type channelRun struct {
+  start int
+  end int
+}
+
+// Group ordered channels into runs of consecutive channels
+func findRuns(channels []int) []channelRun {
+  runs := make([]channelRun, 0)
+
+  for _, channel := range channels {
+    if n := len(runs); n > 0 && runs[n - 1].end == channel - 1 {
+      runs[n - 1].end = channel
+    } else {
+      runs = append(runs, channelRun{channel, channel})
+    }
+  }
+
+  return runs
+}
+
+func equal(a, b dmx.DMXFrame) bool {
+  if len(a) != len(b) {
+    return false
+  }
+
+  for i := range a {
+    if a[i] != b[i] {
+      return false
+    }
+  }
+
+  return true
+}
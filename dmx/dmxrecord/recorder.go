@@ -0,0 +1,140 @@
+package dmxrecord
+
+import (
+  "time"
+  "golx/dmx"
+)
+
+/*
+Records several universes by number until it is stopped. The merged levels of
+each universe's channels and sources are recorded, before the master, curves
+and parks, since a player sends them back through a source and the universe
+applies those stages again. Played back into a universe with the same master,
+curves and parks, the recording reproduces exactly what was sent. Frames are
+recorded as they are built so the timing is accurate to the universe's refresh
+period.
+*/
+type Recorder struct {
+  frames chan recordedFrame
+  stop chan chan *Recording
+  quit chan bool
+}
+
+type recordedFrame struct {
+  universe int
+  frame dmx.DMXFrame
+  at time.Time
+}
+
+// Start recording the universes straight away
+func Record(universes map[int] *dmx.DMXUniverse) *Recorder {
+  r := new(Recorder)
+  r.frames = make(chan recordedFrame)
+  r.stop = make(chan chan *Recording)
+  r.quit = make(chan bool)
+
+  start := time.Now()
+  rec := new(Recording)
+  last := make(map[int] dmx.DMXFrame)
+  watches := make(map[int] chan dmx.DMXFrame)
+
+  for number, u := range universes {
+    // Watch before taking the snapshot so no change can be missed
+    watches[number] = u.WatchLevels()
+
+    last[number] = make(dmx.DMXFrame, dmx.UniverseSize)
+    rec.start(number, last[number], u.Snapshot())
+
+    go r.forward(number, watches[number])
+  }
+
+  go func() {
+    for {
+      select {
+      case f := <-r.frames:
+        rec.add(f.universe, f.at.Sub(start), last[f.universe], f.frame)
+      case reply := <-r.stop:
+        close(r.quit)
+
+        for number, u := range universes {
+          u.UnwatchLevels(watches[number])
+        }
+
+        rec.duration = time.Since(start)
+        reply <- rec
+        return
+      }
+    }
+  }()
+
+  return r
+}
+
+// Stop recording and get the recording
+func (r *Recorder) Stop() *Recording {
+  reply := make(chan *Recording)
+  r.stop <- reply
+  return <-reply
+}
+
+func (r *Recorder) forward(universe int, watch chan dmx.DMXFrame) {
+  for {
+    select {
+    case frame, ok := <-watch:
+      if !ok {
+        return
+      }
+
+      select {
+      case r.frames <- recordedFrame{universe, frame, time.Now()}:
+      case _ = <-r.quit:
+        return
+      }
+    case _ = <-r.quit:
+      return
+    }
+  }
+}
+
+// Add the channels that differ between last and frame, updating last
+func (rec *Recording) add(universe int, at time.Duration, last, frame dmx.DMXFrame) {
+  d := delta{at, universe, nil, nil}
+
+  for i := 0; i < len(frame) && i < dmx.UniverseSize; i++ {
+    if frame[i] != last[i] {
+      d.channels = append(d.channels, i + 1)
+      d.values = append(d.values, frame[i])
+      last[i] = frame[i]
+    }
+  }
+
+  if len(d.channels) > 0 {
+    rec.deltas = append(rec.deltas, d)
+  }
+}
+
+/*
+Add the starting state of a universe, updating last. Every channel that has
+been set is included, even at zero, so a player takes it over from live control.
+*/
+func (rec *Recording) start(universe int, last dmx.DMXFrame, snap *dmx.UniverseSnapshot) {
+  d := delta{0, universe, nil, nil}
+  frame := snap.Levels()
+
+  set := make(map[int] bool)
+  for _, channel := range snap.Set() {
+    set[channel] = true
+  }
+
+  for i, val := range frame {
+    if val != 0 || set[i + 1] {
+      d.channels = append(d.channels, i + 1)
+      d.values = append(d.values, val)
+      last[i] = val
+    }
+  }
+
+  if len(d.channels) > 0 {
+    rec.deltas = append(rec.deltas, d)
+  }
+}
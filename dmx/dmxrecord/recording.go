@@ -0,0 +1,245 @@
+/*
+Recordings of DMX output
+
+A Recording holds the levels of several universes over time as a list of
+deltas. Each delta has the time it happened and only the channels of one
+universe that changed, so a show that mostly sits in looks takes very little
+space.
+
+Recordings are saved in a compact binary format:
+
+  "GLXR" version(1)
+  duration(uvarint, microseconds) count(uvarint)
+  count deltas of:
+    time since the previous delta(uvarint, microseconds)
+    universe(uvarint) changes(uvarint)
+    changes pairs of: channels since the previous change(uvarint) value(byte)
+*/
+package dmxrecord
+
+import (
+  "bufio"
+  "encoding/binary"
+  "errors"
+  "io"
+  "os"
+  "sort"
+  "time"
+  "golx/dmx"
+)
+
+const (
+  formatVersion byte = 1
+)
+
+var magic = []byte("GLXR")
+
+// Channels of one universe that changed at a point in a recording
+type delta struct {
+  at time.Duration
+  universe int
+  channels []int
+  values []dmx.DMXValue
+}
+
+type Recording struct {
+  duration time.Duration
+  deltas []delta
+}
+
+func (rec *Recording) Duration() time.Duration {
+  return rec.duration
+}
+
+// The universe numbers that appear in the recording, in order
+func (rec *Recording) Universes() []int {
+  seen := make(map[int] bool)
+  for _, d := range rec.deltas {
+    seen[d.universe] = true
+  }
+
+  numbers := make([]int, 0, len(seen))
+  for number := range seen {
+    numbers = append(numbers, number)
+  }
+  sort.Ints(numbers)
+
+  return numbers
+}
+
+/*
+The channels of a universe that are set at some point in the recording, in
+order. Channels that had been set when recording started are included even at
+zero, while channels that were never set are left out.
+*/
+func (rec *Recording) Channels(universe int) []int {
+  seen := make(map[int] bool)
+  for _, d := range rec.deltas {
+    if d.universe == universe {
+      for _, channel := range d.channels {
+        seen[channel] = true
+      }
+    }
+  }
+
+  channels := make([]int, 0, len(seen))
+  for channel := range seen {
+    channels = append(channels, channel)
+  }
+  sort.Ints(channels)
+
+  return channels
+}
+
+// The state of a universe at a time in the recording
+func (rec *Recording) FrameAt(universe int, at time.Duration) dmx.DMXFrame {
+  frame := make(dmx.DMXFrame, dmx.UniverseSize)
+
+  for _, d := range rec.deltas {
+    if d.at > at {
+      break
+    }
+
+    if d.universe == universe {
+      d.applyTo(frame)
+    }
+  }
+
+  return frame
+}
+
+func (d delta) applyTo(frame dmx.DMXFrame) {
+  for i, channel := range d.channels {
+    frame[channel - 1] = d.values[i]
+  }
+}
+
+// Write the recording in the binary format read by ReadRecording
+func (rec *Recording) Save(w io.Writer) error {
+  buf := bufio.NewWriter(w)
+  scratch := make([]byte, binary.MaxVarintLen64)
+
+  writeUvarint := func(x uint64) {
+    n := binary.PutUvarint(scratch, x)
+    buf.Write(scratch[:n])
+  }
+
+  buf.Write(magic)
+  buf.WriteByte(formatVersion)
+  writeUvarint(uint64(rec.duration / time.Microsecond))
+  writeUvarint(uint64(len(rec.deltas)))
+
+  last := time.Duration(0)
+  for _, d := range rec.deltas {
+    writeUvarint(uint64((d.at - last) / time.Microsecond))
+    last = d.at
+
+    writeUvarint(uint64(d.universe))
+    writeUvarint(uint64(len(d.channels)))
+
+    previous := 0
+    for i, channel := range d.channels {
+      writeUvarint(uint64(channel - previous))
+      buf.WriteByte(byte(d.values[i]))
+      previous = channel
+    }
+  }
+
+  return buf.Flush()
+}
+
+func (rec *Recording) SaveFile(path string) error {
+  f, err := os.Create(path)
+
+  if err != nil {
+    return err
+  }
+
+  if err := rec.Save(f); err != nil {
+    f.Close()
+    return err
+  }
+
+  return f.Close()
+}
+
+func ReadRecording(r io.Reader) (*Recording, error) {
+  buf := bufio.NewReader(r)
+
+  header := make([]byte, len(magic) + 1)
+  if _, err := io.ReadFull(buf, header); err != nil {
+    return nil, errors.New("Recording is too short")
+  }
+
+  if string(header[:len(magic)]) != string(magic) {
+    return nil, errors.New("Not a GoLX recording")
+  }
+
+  if header[len(magic)] != formatVersion {
+    return nil, errors.New("Unsupported recording version")
+  }
+
+  var err error
+  readUvarint := func() uint64 {
+    if err != nil {
+      return 0
+    }
+    var x uint64
+    x, err = binary.ReadUvarint(buf)
+    return x
+  }
+
+  rec := new(Recording)
+  rec.duration = time.Duration(readUvarint()) * time.Microsecond
+  count := readUvarint()
+
+  if err != nil {
+    return nil, errors.New("Recording header is truncated")
+  }
+
+  at := time.Duration(0)
+  for i := uint64(0); i < count; i++ {
+    at += time.Duration(readUvarint()) * time.Microsecond
+    universe := int(readUvarint())
+    changes := readUvarint()
+
+    if err != nil || changes > uint64(dmx.UniverseSize) {
+      return nil, errors.New("Recording is truncated or corrupt")
+    }
+
+    d := delta{at, universe, make([]int, changes), make([]dmx.DMXValue, changes)}
+
+    channel := 0
+    for j := range d.channels {
+      channel += int(readUvarint())
+
+      var val byte
+      if err == nil {
+        val, err = buf.ReadByte()
+      }
+
+      if err != nil || channel < 1 || channel > dmx.UniverseSize {
+        return nil, errors.New("Recording is truncated or corrupt")
+      }
+
+      d.channels[j] = channel
+      d.values[j] = dmx.DMXValue(val)
+    }
+
+    rec.deltas = append(rec.deltas, d)
+  }
+
+  return rec, nil
+}
+
+func LoadFile(path string) (*Recording, error) {
+  f, err := os.Open(path)
+
+  if err != nil {
+    return nil, err
+  }
+
+  defer f.Close()
+
+  return ReadRecording(f)
+}
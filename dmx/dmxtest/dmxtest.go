@@ -0,0 +1,76 @@
+/*
+Helpers for testing attributes patched to DMX universes
+
+Universes send their changes as frames, coalescing any made close together, so
+channels can't be read straight after a value is set. Instead tests watch the
+universe and wait for a frame with the values they expect.
+*/
+package dmxtest
+
+import (
+  "testing"
+  "time"
+  "golx/dmx"
+  "golx/patch"
+)
+
+// How long to wait for a matching frame or level before giving up
+const Timeout = 2 * time.Second
+
+/*
+Read frames from watch until done returns true for one and return that frame.
+Returns nil if no frame matches within Timeout.
+*/
+func WaitFor(watch chan dmx.DMXFrame, done func(dmx.DMXFrame) bool) dmx.DMXFrame {
+  deadline := time.After(Timeout)
+
+  for {
+    select {
+    case frame := <-watch:
+      if done(frame) {
+        return frame
+      }
+    case _ = <-deadline:
+      return nil
+    }
+  }
+}
+
+// Read levels from c until one is level. False if none is within Timeout.
+func WaitForLevel(c chan dmx.DMXLevel, level dmx.DMXLevel) bool {
+  deadline := time.After(Timeout)
+
+  for {
+    select {
+    case val := <-c:
+      if val == level {
+        return true
+      }
+    case _ = <-deadline:
+      return false
+    }
+  }
+}
+
+/*
+Patch out to the first width channels of a new universe, most significant byte
+first. The universe is returned with a watch made before patching, so the
+frames sent by the patch are seen. The test is stopped if out can't be patched.
+*/
+func Patched(t *testing.T, out interface {}, width int) (*dmx.DMXUniverse, chan dmx.DMXFrame) {
+  u := dmx.NewDMXUniverse()
+  watch := u.Watch()
+
+  channel, err := u.GetMultiChannel(1, width, dmx.MSBFirst)
+  if err != nil {
+    t.Log(err.Error())
+    t.FailNow()
+  }
+
+  if err := patch.Patch(out, channel); err != nil {
+    t.Log(err.Error())
+    t.FailNow()
+  }
+
+  return u, watch
+}
@@ -0,0 +1,171 @@
+package dmxwheel
+
+import (
+  "math"
+  "testing"
+  "golx/dmx"
+  "golx/dmx/dmxtest"
+  "golx/fixture/profile"
+)
+
+var colourWheel = profile.AttributeDef{
+  Name: "Colour",
+  Type: "beam",
+  Channels: []int{1},
+  Ranges: []profile.Range{
+    {From: 0, To: 9, Name: "Open"},
+    {From: 10, To: 19, Name: "Red"},
+    {From: 20, To: 24, Name: "Red/Blue"},
+    {From: 25, To: 34, Name: "Blue"},
+    {From: 35, To: 127, Name: "Wheel CW"},
+    {From: 128, To: 129, Name: "Stop"},
+    {From: 130, To: 255, Name: "Wheel CCW fast-slow"},
+  },
+}
+
+var goboRotation = profile.AttributeDef{
+  Name: "Gobo Rotation",
+  Type: "beam",
+  Channels: []int{1, 2},
+  Ranges: []profile.Range{
+    {From: 0, To: 127, Name: "Index"},
+    {From: 128, To: 191, Name: "Rotation CW"},
+    {From: 192, To: 255, Name: "Rotation CCW"},
+  },
+}
+
+// The coarse DMX value of a level on the first channel of def
+func coarse(def profile.AttributeDef, level dmx.DMXLevel) int {
+  width := len(def.Channels)
+  value := math.Floor(float64(level) * float64(dmx.MaxLevel(width)) + 0.5)
+  return int(value) >> uint(8 * (width - 1))
+}
+
+func TestWheelSlots(t *testing.T) {
+  wheel, err := NewDMXWheel(nil, colourWheel)
+  if err != nil {
+    t.Log(err.Error())
+    t.FailNow()
+  }
+
+  u, watch := dmxtest.Patched(t, wheel.DMXOut(), 1)
+
+  if wheel.Value().Slot != "Open" {
+    t.Log("Wheel started on ", wheel.Value())
+    t.Fail()
+  }
+
+  if err := wheel.SetSlot("Red"); err != nil {
+    t.Log(err.Error())
+    t.Fail()
+  }
+
+  if dmxtest.WaitFor(watch, func(f dmx.DMXFrame) bool { return f[0] >= 10 && f[0] <= 19 }) == nil {
+    t.Log("Red slot output as ", u.GetChannel(1).Value())
+    t.Fail()
+  }
+
+  // Either order finds the split colour
+  if err := wheel.SetSplit("Blue", "Red"); err != nil {
+    t.Log(err.Error())
+    t.Fail()
+  }
+
+  if dmxtest.WaitFor(watch, func(f dmx.DMXFrame) bool { return f[0] >= 20 && f[0] <= 24 }) == nil {
+    t.Log("Split colour output as ", u.GetChannel(1).Value())
+    t.Fail()
+  }
+
+  if err := wheel.SetSplit("Open", "Blue"); err == nil {
+    t.Log("Set a half position the wheel doesn't have")
+    t.Fail()
+  }
+
+  if err := wheel.SetSlot("Green"); err == nil {
+    t.Log("Set a slot the wheel doesn't have")
+    t.Fail()
+  }
+
+  if _, err := NewDMXWheel(nil, profile.AttributeDef{Name: "Empty", Channels: []int{1}}); err == nil {
+    t.Log("Built a wheel without slots")
+    t.Fail()
+  }
+}
+
+func TestRotationLevels(t *testing.T) {
+  spin, err := NewDMXRotation(nil, colourWheel)
+  if err != nil {
+    t.Log(err.Error())
+    t.FailNow()
+  }
+
+  tests := []struct {
+    rot Rotation
+    from int
+    to int
+  }{
+    {Rotation{}, 128, 129},
+    {Rotation{Speed: 0.01}, 35, 37},
+    {Rotation{Speed: 1}, 126, 127},
+    // Anticlockwise runs from fast to slow
+    {Rotation{Speed: -1}, 130, 131},
+    {Rotation{Speed: -0.01}, 253, 255},
+  }
+
+  for _, test := range tests {
+    level, err := spin.Level(test.rot)
+    if c := coarse(colourWheel, level); err != nil || c < test.from || c > test.to {
+      t.Log("Rotation ", test.rot, " gave ", c, " expected ", test.from, " to ", test.to, err)
+      t.Fail()
+    }
+  }
+
+  if _, err := spin.Level(Rotation{Indexed: true}); err == nil {
+    t.Log("Indexed a wheel without an index range")
+    t.Fail()
+  }
+}
+
+func TestIndexedRotation(t *testing.T) {
+  gobo, err := NewDMXRotation(nil, goboRotation)
+  if err != nil {
+    t.Log(err.Error())
+    t.FailNow()
+  }
+
+  // Without a stop range the gobo starts indexed
+  if !gobo.Value().Indexed {
+    t.Log("Gobo rotation started as ", gobo.Value())
+    t.Fail()
+  }
+
+  level, _ := gobo.Level(Rotation{Indexed: true, Angle: 180})
+  if c := coarse(goboRotation, level); c != 63 && c != 64 {
+    t.Log("Index of 180 degrees gave ", c)
+    t.Fail()
+  }
+
+  // Negative angles wrap around
+  a, _ := gobo.Level(Rotation{Indexed: true, Angle: -90})
+  b, _ := gobo.Level(Rotation{Indexed: true, Angle: 270})
+  if a != b {
+    t.Log("-90 and 270 degrees differ: ", a, b)
+    t.Fail()
+  }
+
+  _, watch := dmxtest.Patched(t, gobo.DMXOut(), 2)
+
+  gobo.SetSpeed(-0.5)
+  level, _ = gobo.Level(Rotation{Speed: -0.5})
+  expected := dmx.DMXValue(coarse(goboRotation, level))
+
+  if dmxtest.WaitFor(watch, func(f dmx.DMXFrame) bool { return f[0] == expected }) == nil || gobo.Value().Speed != -0.5 {
+    t.Log("Speed was not set")
+    t.Fail()
+  }
+
+  if _, err := NewDMXRotation(nil, profile.AttributeDef{Name: "Plain", Channels: []int{1}}); err == nil {
+    t.Log("Built a rotation without rotation ranges")
+    t.Fail()
+  }
+}
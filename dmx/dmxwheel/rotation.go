@@ -0,0 +1,215 @@
+package dmxwheel
+
+import (
+  "errors"
+  "math"
+  "sync"
+  "golx/fixture"
+  "golx/fixture/mixer"
+  "golx/fixture/profile"
+  "golx/dmx"
+  "golx/dmx/dmxfixture"
+)
+
+/*
+A rotation, either spinning at Speed from -1 (fastest anticlockwise) to 1
+(fastest clockwise) or, if Indexed, held at Angle degrees
+*/
+type Rotation struct {
+  Speed float64
+  Indexed bool
+  Angle float64
+}
+
+// The ranges of a rotation channel, any of which may be missing
+type RotationRanges struct {
+  Index *profile.Range
+  Stop *profile.Range
+  Clockwise *profile.Range
+  Anticlockwise *profile.Range
+
+  // Set if the range runs from fast to slow
+  ClockwiseFastFirst bool
+  AnticlockwiseFastFirst bool
+}
+
+// Find the rotation ranges of an attribute by their names
+func FindRotationRanges(def profile.AttributeDef) (RotationRanges, error) {
+  var rr RotationRanges
+
+  for i := range def.Ranges {
+    r := &def.Ranges[i]
+    w := r.Words()
+
+    switch {
+    case w["index"] || w["indexed"] || w["indexing"]:
+      rr.Index = r
+    case w["stop"]:
+      rr.Stop = r
+    case w["ccw"] || w["anticlockwise"]:
+      rr.Anticlockwise = r
+      rr.AnticlockwiseFastFirst = w["fast-slow"]
+    case w["cw"] || w["clockwise"]:
+      rr.Clockwise = r
+      rr.ClockwiseFastFirst = w["fast-slow"]
+    }
+  }
+
+  if rr.Index == nil && rr.Clockwise == nil && rr.Anticlockwise == nil {
+    return rr, errors.New("Attribute " + def.Name + " has no index or rotation ranges")
+  }
+
+  return rr, nil
+}
+
+type DMXRotation struct {
+  fixture fixture.Fixture
+  def profile.AttributeDef
+  ranges RotationRanges
+  param *dmxfixture.DMXMultiParam
+  mixer *mixer.LTPMixer
+
+  input chan Rotation
+  value Rotation
+  valueLock sync.Mutex
+}
+
+/*
+Build a rotation attribute from a profile attribute, finding its ranges by name.
+The attribute starts stopped, or indexed at 0 if the channel has no stop range.
+*/
+func NewDMXRotation(fixture fixture.Fixture, def profile.AttributeDef) (*DMXRotation, error) {
+  ranges, err := FindRotationRanges(def)
+
+  if err != nil {
+    return nil, err
+  }
+
+  attr := new(DMXRotation)
+  attr.fixture = fixture
+  attr.def = def
+  attr.ranges = ranges
+
+  if ranges.Stop == nil && ranges.Index != nil {
+    attr.value = Rotation{Indexed: true}
+  }
+
+  attr.param = dmxfixture.NewDMXMultiParam(attr)
+  attr.param.SetClass(def.Class())
+
+  attr.input = make(chan Rotation)
+  attr.mixer, _ = mixer.NewLTPMixer(attr.input, attr.value)
+
+  go func() {
+    for rot := range attr.input {
+      level, err := attr.Level(rot)
+
+      // Rotations the channel can't make are ignored
+      if err != nil {
+        continue
+      }
+
+      attr.valueLock.Lock()
+      attr.value = rot
+      attr.valueLock.Unlock()
+
+      attr.param.SetValue(level)
+    }
+  }()
+
+  if level, err := attr.Level(attr.value); err == nil {
+    attr.param.SetValue(level)
+  }
+
+  return attr, nil
+}
+
+func (attr *DMXRotation) Fixture() fixture.Fixture {
+  return attr.fixture
+}
+
+func (attr *DMXRotation) Name() string {
+  return attr.def.Name
+}
+
+func (attr *DMXRotation) Ranges() RotationRanges {
+  return attr.ranges
+}
+
+// The level that makes a rotation
+func (attr *DMXRotation) Level(rot Rotation) (dmx.DMXLevel, error) {
+  rr := attr.ranges
+
+  if rot.Indexed {
+    if rr.Index == nil {
+      return 0, errors.New("Attribute " + attr.def.Name + " can't be indexed")
+    }
+
+    angle := math.Mod(rot.Angle, 360)
+    if angle < 0 {
+      angle += 360
+    }
+    return rangeLevel(attr.def, *rr.Index, angle / 360), nil
+  }
+
+  speed := math.Max(-1, math.Min(1, rot.Speed))
+  r, fastFirst := rr.Clockwise, rr.ClockwiseFastFirst
+  if speed < 0 {
+    r, fastFirst = rr.Anticlockwise, rr.AnticlockwiseFastFirst
+    speed = -speed
+  }
+
+  switch {
+  case speed == 0 && rr.Stop != nil:
+    return rangeLevel(attr.def, *rr.Stop, 0.5), nil
+  case speed == 0 && rr.Index != nil:
+    return rangeLevel(attr.def, *rr.Index, 0), nil
+  case speed == 0:
+    return 0, errors.New("Attribute " + attr.def.Name + " has no stop range")
+  case r == nil:
+    return 0, errors.New("Attribute " + attr.def.Name + " can't rotate in that direction")
+  }
+
+  if fastFirst {
+    speed = 1 - speed
+  }
+  return rangeLevel(attr.def, *r, speed), nil
+}
+
+func (attr *DMXRotation) SetValue(rot Rotation) {
+  attr.input <- rot
+}
+
+// Spin at a speed from -1 to 1, negative speeds being anticlockwise
+func (attr *DMXRotation) SetSpeed(speed float64) {
+  attr.SetValue(Rotation{Speed: speed})
+}
+
+// Hold at an angle in degrees
+func (attr *DMXRotation) SetAngle(angle float64) {
+  attr.SetValue(Rotation{Indexed: true, Angle: angle})
+}
+
+func (attr *DMXRotation) Value() Rotation {
+  attr.valueLock.Lock()
+  defer attr.valueLock.Unlock()
+  return attr.value
+}
+
+/*
+Get a new input for the attribute. Inputs are mixed latest takes precedence and
+the rotation returns to its starting value when every input has been closed.
+*/
+func (attr *DMXRotation) Input() chan Rotation {
+  c := make(chan Rotation)
+  attr.mixer.AddInput(c)
+  return c
+}
+
+func (attr *DMXRotation) Parameters() map[string] fixture.Parameter {
+  return map[string] fixture.Parameter{attr.def.Name: attr.param}
+}
+
+func (attr *DMXRotation) DMXOut() *dmxfixture.DMXMultiParam {
+  return attr.param
+}
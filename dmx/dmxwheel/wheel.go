@@ -0,0 +1,218 @@
+/*
+Wheel, gobo and prism attributes
+
+Fixture specific DMX ranges are hidden behind names taken from the ranges of a
+profile attribute. DMXWheel selects a named slot, such as a colour, a gobo, a
+shaking gobo or a prism, and can put a wheel half way between two slots where
+the fixture has a range for it. DMXRotation spins a gobo, prism or whole wheel
+at a speed from -1 to 1, or indexes it to an angle.
+
+Split colours and other half positions are ranges named after both slots,
+e.g. Red/Blue, and shaking slots are named after the slot, e.g. Dots shake, as
+the OFL importer names them. Rotation ranges are found by
+the words in their names:
+
+  Index        the range covers 0 to 360 degrees
+  Stop         no rotation
+  CW           clockwise, slow to fast
+  CCW          anticlockwise, slow to fast
+
+A rotation range that also contains fast-slow runs from fast to slow.
+*/
+package dmxwheel
+
+import (
+  "errors"
+  "strings"
+  "sync"
+  "golx/fixture"
+  "golx/fixture/converter"
+  "golx/fixture/mixer"
+  "golx/fixture/profile"
+  "golx/dmx"
+  "golx/dmx/dmxfixture"
+)
+
+/*
+A position of a wheel. Split is empty for a whole slot, or the name of the
+other slot for a half position.
+*/
+type Selection struct {
+  Slot string
+  Split string
+}
+
+func (sel Selection) String() string {
+  if sel.Split == "" {
+    return sel.Slot
+  }
+  return sel.Slot + "/" + sel.Split
+}
+
+type DMXWheel struct {
+  fixture fixture.Fixture
+  def profile.AttributeDef
+  param *dmxfixture.DMXMultiParam
+  mixer *mixer.LTPMixer
+
+  input chan Selection
+  value Selection
+  valueLock sync.Mutex
+}
+
+/*
+Build a wheel from a profile attribute's ranges. The wheel starts on the slot
+containing the attribute's default, or its first range.
+*/
+func NewDMXWheel(fixture fixture.Fixture, def profile.AttributeDef) (*DMXWheel, error) {
+  if len(def.Ranges) == 0 {
+    return nil, errors.New("Attribute " + def.Name + " has no ranges to make wheel slots from")
+  }
+
+  attr := new(DMXWheel)
+  attr.fixture = fixture
+  attr.def = def
+  attr.value = Selection{Slot: def.Ranges[0].Name}
+
+  coarse := int(def.Default * float64(dmx.MaxLevel(1)) + 0.5)
+  for _, r := range def.Ranges {
+    if coarse >= r.From && coarse <= r.To {
+      attr.value = Selection{Slot: r.Name}
+      break
+    }
+  }
+
+  attr.param = dmxfixture.NewDMXMultiParam(attr)
+  attr.param.SetClass(def.Class())
+
+  attr.input = make(chan Selection)
+  attr.mixer, _ = mixer.NewLTPMixer(attr.input, attr.value)
+
+  go func() {
+    for sel := range attr.input {
+      level, err := attr.Level(sel)
+
+      // Selections of slots the wheel doesn't have are ignored
+      if err != nil {
+        continue
+      }
+
+      attr.valueLock.Lock()
+      attr.value = sel
+      attr.valueLock.Unlock()
+
+      attr.param.SetValue(level)
+    }
+  }()
+
+  level, _ := attr.Level(attr.value)
+  attr.param.SetValue(level)
+
+  return attr, nil
+}
+
+func (attr *DMXWheel) Fixture() fixture.Fixture {
+  return attr.fixture
+}
+
+func (attr *DMXWheel) Name() string {
+  return attr.def.Name
+}
+
+// The names of the slots and other ranges of the wheel, in DMX order
+func (attr *DMXWheel) Slots() []string {
+  names := make([]string, len(attr.def.Ranges))
+  for i, r := range attr.def.Ranges {
+    names[i] = r.Name
+  }
+  return names
+}
+
+// The range for a selection, looking for either order of a split
+func (attr *DMXWheel) find(sel Selection) (profile.Range, error) {
+  if sel.Split == "" {
+    return attr.def.Range(sel.Slot)
+  }
+
+  for _, name := range []string{sel.Slot + "/" + sel.Split, sel.Split + "/" + sel.Slot} {
+    for _, r := range attr.def.Ranges {
+      if strings.EqualFold(strings.Replace(r.Name, " ", "", -1), strings.Replace(name, " ", "", -1)) {
+        return r, nil
+      }
+    }
+  }
+
+  return profile.Range{}, errors.New("Attribute " + attr.def.Name + " has no half position " + sel.String())
+}
+
+// The level that selects a slot or half position
+func (attr *DMXWheel) Level(sel Selection) (dmx.DMXLevel, error) {
+  r, err := attr.find(sel)
+
+  if err != nil {
+    return 0, err
+  }
+
+  return rangeLevel(attr.def, r, 0.5), nil
+}
+
+func (attr *DMXWheel) SetValue(sel Selection) {
+  attr.input <- sel
+}
+
+// Move to a named slot, or return an error if the wheel doesn't have it
+func (attr *DMXWheel) SetSlot(name string) error {
+  sel := Selection{Slot: name}
+
+  if _, err := attr.find(sel); err != nil {
+    return err
+  }
+
+  attr.SetValue(sel)
+  return nil
+}
+
+// Move half way between two slots, e.g. for a split colour
+func (attr *DMXWheel) SetSplit(slot, other string) error {
+  sel := Selection{Slot: slot, Split: other}
+
+  if _, err := attr.find(sel); err != nil {
+    return err
+  }
+
+  attr.SetValue(sel)
+  return nil
+}
+
+func (attr *DMXWheel) Value() Selection {
+  attr.valueLock.Lock()
+  defer attr.valueLock.Unlock()
+  return attr.value
+}
+
+/*
+Get a new input for the attribute. Inputs are mixed latest takes precedence and
+the wheel returns to its starting slot when every input has been closed.
+*/
+func (attr *DMXWheel) Input() chan Selection {
+  c := make(chan Selection)
+  attr.mixer.AddInput(c)
+  return c
+}
+
+func (attr *DMXWheel) Parameters() map[string] fixture.Parameter {
+  return map[string] fixture.Parameter{attr.def.Name: attr.param}
+}
+
+// Patch to the attribute's channels, e.g. with a dmx.DMXMultiChannel
+func (attr *DMXWheel) DMXOut() *dmxfixture.DMXMultiParam {
+  return attr.param
+}
+
+/*
+The level at a fraction of the way through a range of coarse values, at the
+full resolution of the attribute's channels
+*/
+func rangeLevel(def profile.AttributeDef, r profile.Range, fraction float64) dmx.DMXLevel {
+  return converter.RangeLevel(len(def.Channels), r.From, r.To, fraction)
+}
@@ -0,0 +1,169 @@
+/*
+Channels spanning several consecutive DMX slots
+
+Moving lights use a coarse and fine slot pair for 16 bit pan, tilt and dimmer
+and some LED fixtures use three slots for 24 bit values. A DMXMultiChannel takes
+a normalised DMXLevel and splits it across its slots. All of the slots are
+updated in a single step so the coarse and fine values never appear in
+different frames.
+*/
+package dmx
+
+import (
+  "errors"
+  "fmt"
+  "math"
+  "sync"
+  "golx/dmx/curve"
+)
+
+// Order of the slots in a multi-slot channel
+type ByteOrder int
+
+const (
+  // Coarse slot first, the usual layout
+  MSBFirst ByteOrder = iota
+  LSBFirst
+)
+
+const (
+  MaxChannelWidth int = 4
+)
+
+type DMXMultiChannel struct {
+  universe *DMXUniverse
+  channelNumber int
+  width int
+  order ByteOrder
+
+  input chan DMXLevel
+  inputOnce sync.Once
+}
+
+/*
+Get a channel made of width slots starting at channelNumber. Widths from 1 to
+MaxChannelWidth are supported.
+*/
+func (u *DMXUniverse) GetMultiChannel(channelNumber, width int, order ByteOrder) (*DMXMultiChannel, error) {
+  if width < 1 || width > MaxChannelWidth {
+    return nil, errors.New("Channel width must be between 1 and 4 slots")
+  }
+
+  if channelNumber < 1 || channelNumber + width - 1 > UniverseSize {
+    return nil, errors.New("Channel does not fit in the universe")
+  }
+
+  channel := new(DMXMultiChannel)
+  channel.universe = u
+  channel.channelNumber = channelNumber
+  channel.width = width
+  channel.order = order
+
+  return channel, nil
+}
+
+func (channel *DMXMultiChannel) String() string {
+  return fmt.Sprintf("[%s.%d-%d]", channel.universe.String(), channel.channelNumber,
+    channel.channelNumber + channel.width - 1)
+}
+
+// The first slot of the channel, counting from 1
+func (channel *DMXMultiChannel) ChannelNumber() int {
+  return channel.channelNumber
+}
+
+func (channel *DMXMultiChannel) Width() int {
+  return channel.width
+}
+
+func (channel *DMXMultiChannel) Universe() *DMXUniverse {
+  return channel.universe
+}
+
+func (channel *DMXMultiChannel) Input() chan DMXLevel {
+  channel.inputOnce.Do(channel.buildInput)
+  return channel.input
+}
+
+func (channel *DMXMultiChannel) SetValue(level DMXLevel) {
+  channel.universe.setValues(channel.channelNumber, channel.encode(level))
+}
+
+// The level currently in the universe
+func (channel *DMXMultiChannel) Value() DMXLevel {
+  return channel.decode(channel.universe.getValues(channel.channelNumber, channel.width))
+}
+
+// Mark the channel's slots as carrying a class of parameter
+func (channel *DMXMultiChannel) SetClass(class ParamClass) {
+  channel.universe.setClass(channel.channelNumber, channel.width, channel.order, class)
+}
+
+// Apply a curve to the channel's level as it is output, or remove it if c is nil
+func (channel *DMXMultiChannel) SetCurve(c curve.Curve) {
+  channel.universe.setCurve(channel.channelNumber, channel.width, channel.order, c)
+}
+
+// The class the channel's first slot is tagged with
+func (channel *DMXMultiChannel) Class() ParamClass {
+  return channel.universe.Class(channel.channelNumber)
+}
+
+func (channel *DMXMultiChannel) encode(level DMXLevel) []DMXValue {
+  return encodeLevel(level, channel.width, channel.order)
+}
+
+func (channel *DMXMultiChannel) decode(values []DMXValue) DMXLevel {
+  return decodeLevel(values[:channel.width], channel.order)
+}
+
+// Largest value width slots can hold
+func MaxLevel(width int) uint64 {
+  return (uint64(1) << uint(8 * width)) - 1
+}
+
+// Split a level into width slot values in the given byte order
+func encodeLevel(level DMXLevel, width int, order ByteOrder) []DMXValue {
+  clamped := math.Max(0, math.Min(1, float64(level)))
+  raw := uint64(math.Floor(clamped * float64(MaxLevel(width)) + 0.5))
+
+  values := make([]DMXValue, width)
+
+  for i := 0; i < width; i++ {
+    // Least significant byte first, reversed below if needed
+    values[i] = DMXValue(raw >> uint(8 * i))
+  }
+
+  if order == MSBFirst {
+    for i, j := 0, len(values) - 1; i < j; i, j = i + 1, j - 1 {
+      values[i], values[j] = values[j], values[i]
+    }
+  }
+
+  return values
+}
+
+func decodeLevel(values []DMXValue, order ByteOrder) DMXLevel {
+  width := len(values)
+  raw := uint64(0)
+
+  for i := 0; i < width; i++ {
+    b := values[i]
+    if order == LSBFirst {
+      b = values[width - 1 - i]
+    }
+    raw = raw << 8 | uint64(b)
+  }
+
+  return DMXLevel(float64(raw) / float64(MaxLevel(width)))
+}
+
+func (channel *DMXMultiChannel) buildInput() {
+  channel.input = make(chan DMXLevel)
+
+  go func() {
+    for level := range channel.input {
+      channel.SetValue(level)
+    }
+  }()
+}
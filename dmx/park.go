@@ -0,0 +1,113 @@
+/*
+Parked channels
+
+Parking holds a channel at a fixed level regardless of what is sent to it, e.g.
+a work light at full during focus or a faulty fixture held at zero. Parked
+levels are applied after every other stage so the master and curves have no
+effect on them. Parks belong to the universe rather than the show, so they stay
+in place while fixtures are rebuilt and repatched and whole frames are sent to
+Input. They are only removed by unparking.
+*/
+package dmx
+
+import (
+  "errors"
+  "sort"
+)
+
+type Park struct {
+  Channel int
+  Level DMXValue
+  Reason string
+}
+
+// Park a single channel at level
+func (u *DMXUniverse) Park(channel int, level DMXValue, reason string) error {
+  return u.ParkRange(channel, channel, level, reason)
+}
+
+/*
+Park every channel from first to last inclusive at level. Channels that are
+already parked take the new level and reason.
+*/
+func (u *DMXUniverse) ParkRange(first, last int, level DMXValue, reason string) error {
+  if first < 1 || last > UniverseSize || first > last {
+    return errors.New("Park range is not in the universe")
+  }
+
+  u.calls <- func(s *universeState) {
+    for n := first; n <= last; n++ {
+      s.parks[n] = Park{n, level, reason}
+    }
+    s.touch(first, last - first + 1)
+  }
+
+  return nil
+}
+
+// Release a parked channel, returning it to its normal output
+func (u *DMXUniverse) Unpark(channel int) {
+  u.UnparkRange(channel, channel)
+}
+
+func (u *DMXUniverse) UnparkRange(first, last int) {
+  u.calls <- func(s *universeState) {
+    for n := first; n <= last; n++ {
+      if _, parked := s.parks[n]; parked {
+        delete(s.parks, n)
+        s.changed[n] = true
+      }
+    }
+  }
+}
+
+func (u *DMXUniverse) UnparkAll() {
+  u.UnparkRange(1, UniverseSize)
+}
+
+// Every parked channel in channel order
+func (u *DMXUniverse) Parked() []Park {
+  reply := make(chan []Park)
+
+  u.calls <- func(s *universeState) {
+    parks := make([]Park, 0, len(s.parks))
+    for _, park := range s.parks {
+      parks = append(parks, park)
+    }
+    reply <- parks
+  }
+
+  parks := <-reply
+  sort.Sort(parksByChannel(parks))
+  return parks
+}
+
+func (u *DMXUniverse) IsParked(channel int) bool {
+  reply := make(chan bool)
+
+  u.calls <- func(s *universeState) {
+    _, parked := s.parks[channel]
+    reply <- parked
+  }
+
+  return <-reply
+}
+
+// Park the channel at level
+func (channel *DMXChannel) Park(level DMXValue, reason string) error {
+  return channel.universe.Park(channel.channelNumber, level, reason)
+}
+
+func (channel *DMXChannel) Unpark() {
+  channel.universe.Unpark(channel.channelNumber)
+}
+
+func (channel *DMXChannel) IsParked() bool {
+  return channel.universe.IsParked(channel.channelNumber)
+}
+
+type parksByChannel []Park
+
+func (p parksByChannel) Len() int { return len(p) }
+func (p parksByChannel) Less(i, j int) bool { return p[i].Channel < p[j].Channel }
+func (p parksByChannel) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
@@ -0,0 +1,166 @@
+package pattern
+
+import (
+  "time"
+  "golx/dmx"
+)
+
+const (
+  // Above anything a show would normally use
+  DefaultPriority int = 1000
+)
+
+/*
+Runs patterns on a universe through a dmx.DMXSource, so the pattern overrides
+the show without changing it. Releasing the generator returns every channel to
+the show's values.
+*/
+type Generator struct {
+  patterns chan Pattern
+  steps chan int
+  moves chan int
+  reads chan chan int
+  release chan bool
+  quit chan bool
+}
+
+func NewGenerator(u *dmx.DMXUniverse, priority int) *Generator {
+  g := new(Generator)
+  g.patterns = make(chan Pattern)
+  g.steps = make(chan int)
+  g.moves = make(chan int)
+  g.reads = make(chan chan int)
+  g.release = make(chan bool)
+  g.quit = make(chan bool)
+
+  go g.run(u, priority)
+
+  return g
+}
+
+// Start a pattern from its first step, replacing any running pattern
+func (g *Generator) Run(p Pattern) {
+  g.patterns <- p
+}
+
+// Move on from the step showing to the next, wrapping round to the first
+func (g *Generator) Next() {
+  g.moves <- 1
+}
+
+// Move back from the step showing to the previous, wrapping round to the last
+func (g *Generator) Previous() {
+  g.moves <- -1
+}
+
+func (g *Generator) SetStep(step int) {
+  g.steps <- step
+}
+
+// The step showing, including any a timed pattern has moved on by itself
+func (g *Generator) Step() int {
+  reply := make(chan int)
+  g.reads <- reply
+  return <-reply
+}
+
+// Stop the pattern and return the universe to the show
+func (g *Generator) Release() {
+  g.release <- true
+}
+
+// Release the universe and end the generator
+func (g *Generator) Close() {
+  g.quit <- true
+}
+
+func (g *Generator) run(u *dmx.DMXUniverse, priority int) {
+  var pattern Pattern
+  var src *dmx.DMXSource
+  var started time.Time
+  step := 0
+
+  // Channels set by the pattern, cleared if a later step doesn't set them
+  set := make(map[int] dmx.DMXValue)
+
+  var ticker *time.Ticker
+  var tick <-chan time.Time = nil
+
+  update := func(now time.Time) {
+    levels := pattern.Levels(step, now.Sub(started))
+
+    for channel := range set {
+      if _, exists := levels[channel]; !exists {
+        src.Clear(channel)
+        delete(set, channel)
+      }
+    }
+
+    for channel, level := range levels {
+      if current, exists := set[channel]; !exists || current != level {
+        src.SetValue(channel, level)
+        set[channel] = level
+      }
+    }
+  }
+
+  release := func() {
+    if ticker == nil {
+      return
+    }
+
+    ticker.Stop()
+    ticker, tick = nil, nil
+
+    src.Release()
+    src, pattern = nil, nil
+    set = make(map[int] dmx.DMXValue)
+  }
+
+  // The step showing, which a timed pattern moves on from the step it started at
+  current := func(now time.Time) int {
+    if timed, ok := pattern.(Timed); ok {
+      return timed.CurrentStep(step, now.Sub(started))
+    }
+    return step
+  }
+
+  // Move to a step, restarting the timing so chases continue from it
+  moveTo := func(s int) {
+    if pattern == nil {
+      return
+    }
+
+    n := pattern.Steps()
+    step = ((s % n) + n) % n
+    started = time.Now()
+    update(started)
+  }
+
+  for {
+    select {
+    case p := <-g.patterns:
+      release()
+
+      pattern = p
+      src = u.NewSource(priority)
+      ticker = time.NewTicker(dmx.DefaultRefreshPeriod)
+      tick = ticker.C
+      moveTo(0)
+    case s := <-g.steps:
+      moveTo(s)
+    case move := <-g.moves:
+      moveTo(current(time.Now()) + move)
+    case now := <-tick:
+      update(now)
+    case reply := <-g.reads:
+      reply <- current(time.Now())
+    case _ = <-g.release:
+      release()
+      step = 0
+    case _ = <-g.quit:
+      release()
+      return
+    }
+  }
+}
@@ -0,0 +1,189 @@
+/*
+Test patterns
+
+Patterns for checking a rig at load in: walking every address, chases, all at
+a level, ramps and flashing fixtures to identify them. A pattern describes the
+levels it wants for a step at a time into the pattern, and a Generator sends
+them to a universe.
+
+Patterns with several steps are moved through with the generator's Next and
+Previous. Timed patterns such as chases carry on from whichever step they are
+moved to, and moving them goes from the step they have reached.
+*/
+package pattern
+
+import (
+  "time"
+  "golx/dmx"
+)
+
+type Pattern interface {
+  // The number of steps in the pattern, at least 1
+  Steps() int
+
+  // The levels of the channels the pattern controls at a step and time into it
+  Levels(step int, elapsed time.Duration) map[int] dmx.DMXValue
+}
+
+/*
+A pattern whose step moves on by itself over time, such as a chase. The
+generator uses it to find the step a pattern has reached so Next and Previous
+move on from what is showing.
+*/
+type Timed interface {
+  Pattern
+
+  // The step reached at elapsed into a pattern started at step
+  CurrentStep(step int, elapsed time.Duration) int
+}
+
+// Fill the channels from first to last with level
+func fill(levels map[int] dmx.DMXValue, first, last int, level dmx.DMXValue) {
+  for channel := first; channel <= last; channel++ {
+    levels[channel] = level
+  }
+}
+
+func span(first, last int) int {
+  if last < first {
+    return 1
+  }
+  return last - first + 1
+}
+
+// One channel at a time at Level with the rest of the range at zero
+type ChannelCheck struct {
+  First int
+  Last int
+  Level dmx.DMXValue
+}
+
+func (p ChannelCheck) Steps() int {
+  return span(p.First, p.Last)
+}
+
+func (p ChannelCheck) Levels(step int, elapsed time.Duration) map[int] dmx.DMXValue {
+  levels := make(map[int] dmx.DMXValue)
+  fill(levels, p.First, p.Last, 0)
+  levels[p.First + step] = p.Level
+  return levels
+}
+
+/*
+Width channels at a time at Level, moving one channel along the range every
+Rate. A zero Rate only moves with Next and Previous.
+*/
+type Chase struct {
+  First int
+  Last int
+  Width int
+  Level dmx.DMXValue
+  Rate time.Duration
+}
+
+func (p Chase) Steps() int {
+  return span(p.First, p.Last)
+}
+
+func (p Chase) CurrentStep(step int, elapsed time.Duration) int {
+  if p.Rate > 0 {
+    step += int(elapsed / p.Rate)
+  }
+  return step % p.Steps()
+}
+
+func (p Chase) Levels(step int, elapsed time.Duration) map[int] dmx.DMXValue {
+  n := p.Steps()
+  step = p.CurrentStep(step, elapsed)
+
+  width := p.Width
+  if width < 1 {
+    width = 1
+  }
+
+  levels := make(map[int] dmx.DMXValue)
+  fill(levels, p.First, p.Last, 0)
+
+  for i := 0; i < width && i < n; i++ {
+    levels[p.First + (step + i) % n] = p.Level
+  }
+
+  return levels
+}
+
+// Every channel in the range at Level
+type AllAtLevel struct {
+  First int
+  Last int
+  Level dmx.DMXValue
+}
+
+func (p AllAtLevel) Steps() int {
+  return 1
+}
+
+func (p AllAtLevel) Levels(step int, elapsed time.Duration) map[int] dmx.DMXValue {
+  levels := make(map[int] dmx.DMXValue)
+  fill(levels, p.First, p.Last, p.Level)
+  return levels
+}
+
+// Every channel in the range rising from zero to full over Period, repeatedly
+type Ramp struct {
+  First int
+  Last int
+  Period time.Duration
+}
+
+func (p Ramp) Steps() int {
+  return 1
+}
+
+func (p Ramp) Levels(step int, elapsed time.Duration) map[int] dmx.DMXValue {
+  level := dmx.DMXValue(255)
+
+  if p.Period > 0 {
+    level = dmx.DMXValue(255 * (elapsed % p.Period) / p.Period)
+  }
+
+  levels := make(map[int] dmx.DMXValue)
+  fill(levels, p.First, p.Last, level)
+  return levels
+}
+
+/*
+Flash one fixture at a time between Level and zero, changing every Rate. Each
+fixture is the list of its channels, e.g. just the intensity or every channel
+needed to make it bright and open. Step through the fixtures with Next.
+*/
+type Identify struct {
+  Fixtures [][]int
+  Level dmx.DMXValue
+  Rate time.Duration
+}
+
+func (p Identify) Steps() int {
+  if len(p.Fixtures) < 1 {
+    return 1
+  }
+  return len(p.Fixtures)
+}
+
+func (p Identify) Levels(step int, elapsed time.Duration) map[int] dmx.DMXValue {
+  levels := make(map[int] dmx.DMXValue)
+
+  if step >= len(p.Fixtures) {
+    return levels
+  }
+
+  level := p.Level
+  if p.Rate > 0 && (elapsed / p.Rate) % 2 == 1 {
+    level = 0
+  }
+
+  for _, channel := range p.Fixtures[step] {
+    levels[channel] = level
+  }
+
+  return levels
+}
@@ -0,0 +1,125 @@
+package pattern
+
+import (
+  "testing"
+  "time"
+  "golx/dmx"
+  "golx/dmx/dmxtest"
+)
+
+func TestChannelCheckSteps(t *testing.T) {
+  u := dmx.NewDMXUniverse()
+  watch := u.Watch()
+
+  // The show has channel 11 up
+  u.GetChannel(11).Input() <- 80
+  dmxtest.WaitFor(watch, func(f dmx.DMXFrame) bool { return f[10] == 80 })
+
+  g := NewGenerator(u, DefaultPriority)
+  defer g.Close()
+
+  g.Run(ChannelCheck{10, 12, 255})
+
+  if dmxtest.WaitFor(watch, func(f dmx.DMXFrame) bool { return f[9] == 255 && f[10] == 0 && f[11] == 0 }) == nil {
+    t.Log("Channel check did not start on the first channel")
+    t.FailNow()
+  }
+
+  g.Next()
+
+  if dmxtest.WaitFor(watch, func(f dmx.DMXFrame) bool { return f[9] == 0 && f[10] == 255 }) == nil {
+    t.Log("Next did not move to the second channel")
+    t.FailNow()
+  }
+
+  // Previous from the first step wraps to the last
+  g.Previous()
+  g.Previous()
+
+  if dmxtest.WaitFor(watch, func(f dmx.DMXFrame) bool { return f[11] == 255 && f[10] == 0 }) == nil || g.Step() != 2 {
+    t.Log("Previous did not wrap to the last channel")
+    t.FailNow()
+  }
+
+  g.Release()
+
+  if dmxtest.WaitFor(watch, func(f dmx.DMXFrame) bool { return f[9] == 0 && f[10] == 80 && f[11] == 0 }) == nil {
+    t.Log("Releasing did not return to the show")
+    t.Fail()
+  }
+}
+
+func TestChaseMovesOnItsOwn(t *testing.T) {
+  levels := Chase{1, 4, 2, 255, 100 * time.Millisecond}.Levels(3, 100 * time.Millisecond)
+
+  // Step 3 plus one step of time wraps round to channels 1 and 2
+  if levels[1] != 255 || levels[2] != 255 || levels[3] != 0 || levels[4] != 0 {
+    t.Log("Chase levels are incorrect: ", levels)
+    t.Fail()
+  }
+}
+
+func TestIdentifyFlashes(t *testing.T) {
+  p := Identify{[][]int{{1, 2}, {5}}, 200, 50 * time.Millisecond}
+
+  on := p.Levels(0, 0)
+  off := p.Levels(0, 60 * time.Millisecond)
+  second := p.Levels(1, 0)
+
+  if on[1] != 200 || on[2] != 200 || off[1] != 0 || len(second) != 1 || second[5] != 200 {
+    t.Log("Identify levels are incorrect: ", on, off, second)
+    t.Fail()
+  }
+}
+
+func TestRampRises(t *testing.T) {
+  p := Ramp{1, 1, time.Second}
+
+  if p.Levels(0, 0)[1] != 0 || p.Levels(0, 500 * time.Millisecond)[1] != 127 {
+    t.Log("Ramp levels are incorrect")
+    t.Fail()
+  }
+}
+
+func TestIdentifyClearsPreviousFixture(t *testing.T) {
+  u := dmx.NewDMXUniverse()
+  watch := u.Watch()
+
+  g := NewGenerator(u, DefaultPriority)
+  defer g.Close()
+
+  g.Run(Identify{[][]int{{1}, {2}}, 255, 0})
+  dmxtest.WaitFor(watch, func(f dmx.DMXFrame) bool { return f[0] == 255 })
+
+  g.Next()
+
+  if dmxtest.WaitFor(watch, func(f dmx.DMXFrame) bool { return f[0] == 0 && f[1] == 255 }) == nil {
+    t.Log("Moving to the next fixture did not release the first")
+    t.Fail()
+  }
+}
+
+func TestNextMovesOnFromTimedStep(t *testing.T) {
+  u := dmx.NewDMXUniverse()
+
+  g := NewGenerator(u, DefaultPriority)
+  defer g.Close()
+
+  g.Run(Chase{1, 100, 1, 255, 20 * time.Millisecond})
+
+  deadline := time.Now().Add(dmxtest.Timeout)
+  for g.Step() < 3 {
+    if time.Now().After(deadline) {
+      t.Log("Chase did not move on by itself")
+      t.FailNow()
+    }
+    time.Sleep(5 * time.Millisecond)
+  }
+
+  g.Next()
+
+  if step := g.Step(); step < 4 {
+    t.Log("Next moved back to step ", step)
+    t.Fail()
+  }
+}
@@ -0,0 +1,224 @@
+/*
+Snapshots
+
+A UniverseSnapshot holds the output of a universe at one moment, after every
+stage including parks, along with which channels were parked, which had been
+set and the merged levels of the channels and sources before any stage. Snapshots can't be changed
+once taken so they can be kept as a look, compared with Diff to see what
+changed, or restored to a universe as a source.
+
+Diff and Value use the output, which is what was on stage. Restore uses the
+levels, since the universe applies its master, curves and parks to a restored
+source again.
+
+A Snapshot covers several universes by number, in the same way as the soft
+patch.
+*/
+package dmx
+
+import (
+  "sort"
+  "time"
+)
+
+type UniverseSnapshot struct {
+  taken time.Time
+  frame DMXFrame
+  levels DMXFrame
+  parked map[int] bool
+  set map[int] bool
+}
+
+// Snapshots of several universes by number
+type Snapshot map[int] *UniverseSnapshot
+
+// A channel that is different in two snapshots
+type Change struct {
+  Universe int
+  Channel int
+  Old DMXValue
+  New DMXValue
+}
+
+// Take a snapshot of the universe's current output
+func (u *DMXUniverse) Snapshot() *UniverseSnapshot {
+  reply := make(chan *UniverseSnapshot)
+
+  u.calls <- func(s *universeState) {
+    parked := make(map[int] bool)
+    for channel := range s.parks {
+      parked[channel] = true
+    }
+
+    levels := make(DMXFrame, UniverseSize)
+    s.merge(levels)
+
+    reply <- &UniverseSnapshot{time.Now(), s.render(), levels, parked, s.setChannels()}
+  }
+
+  return <-reply
+}
+
+/*
+Build a snapshot from a frame, e.g. one recieved from another console's output.
+Missing channels are taken as zero and every channel in the frame is taken as
+set. The frame is used as both the output and the levels to restore.
+*/
+func NewUniverseSnapshot(frame DMXFrame) *UniverseSnapshot {
+  snap := new(UniverseSnapshot)
+  snap.taken = time.Now()
+  snap.frame = make(DMXFrame, UniverseSize)
+  copy(snap.frame, frame)
+  snap.levels = snap.frame
+  snap.parked = make(map[int] bool)
+  snap.set = make(map[int] bool)
+  for i := 0; i < len(frame) && i < UniverseSize; i++ {
+    snap.set[i + 1] = true
+  }
+  return snap
+}
+
+// Snapshot every universe at as close to the same moment as possible
+func TakeSnapshot(universes map[int] *DMXUniverse) Snapshot {
+  snap := make(Snapshot)
+  for number, u := range universes {
+    snap[number] = u.Snapshot()
+  }
+  return snap
+}
+
+func (snap *UniverseSnapshot) Time() time.Time {
+  return snap.taken
+}
+
+func (snap *UniverseSnapshot) Value(channel int) DMXValue {
+  if channel < 1 || channel > UniverseSize {
+    return 0
+  }
+  return snap.frame[channel - 1]
+}
+
+// A copy of the whole universe
+func (snap *UniverseSnapshot) Frame() DMXFrame {
+  frame := make(DMXFrame, UniverseSize)
+  copy(frame, snap.frame)
+  return frame
+}
+
+// A copy of the levels before the master, curves and parks, as restored
+func (snap *UniverseSnapshot) Levels() DMXFrame {
+  levels := make(DMXFrame, UniverseSize)
+  copy(levels, snap.levels)
+  return levels
+}
+
+// True if the channel's value came from a park rather than the show
+func (snap *UniverseSnapshot) IsParked(channel int) bool {
+  return snap.parked[channel]
+}
+
+// The parked channels in order
+func (snap *UniverseSnapshot) Parked() []int {
+  channels := make([]int, 0, len(snap.parked))
+  for channel := range snap.parked {
+    channels = append(channels, channel)
+  }
+  sort.Ints(channels)
+  return channels
+}
+
+/*
+The channels that had been set when the snapshot was taken, in order, including
+those set to zero. Channels that were never written or set by a source are left
+out.
+*/
+func (snap *UniverseSnapshot) Set() []int {
+  channels := make([]int, 0, len(snap.set))
+  for channel := range snap.set {
+    channels = append(channels, channel)
+  }
+  sort.Ints(channels)
+  return channels
+}
+
+/*
+Channels that are different in newer. The changes are reported as universe 0,
+use Snapshot's Diff to compare several universes.
+*/
+func (snap *UniverseSnapshot) Diff(newer *UniverseSnapshot) []Change {
+  return diffFrames(0, snap, newer)
+}
+
+/*
+Add a source to the universe that sets every channel to the snapshot's level.
+The universe's own master, curves and parks are applied to it as to any other
+source, so parks are not restored.
+*/
+func (snap *UniverseSnapshot) Restore(u *DMXUniverse, priority int) *DMXSource {
+  src := u.NewSource(priority)
+
+  // Set every channel in a single step so the look appears in one frame
+  src.SetValues(1, snap.levels)
+
+  return src
+}
+
+/*
+Channels that are different in newer, in universe then channel order. A
+universe that is only in one snapshot is compared with a universe at zero.
+*/
+func (snap Snapshot) Diff(newer Snapshot) []Change {
+  numbers := make(map[int] bool)
+  for number := range snap {
+    numbers[number] = true
+  }
+  for number := range newer {
+    numbers[number] = true
+  }
+
+  sorted := make([]int, 0, len(numbers))
+  for number := range numbers {
+    sorted = append(sorted, number)
+  }
+  sort.Ints(sorted)
+
+  changes := make([]Change, 0)
+  for _, number := range sorted {
+    changes = append(changes, diffFrames(number, snap[number], newer[number])...)
+  }
+
+  return changes
+}
+
+// Restore each universe that has a snapshot, returning the sources by number
+func (snap Snapshot) Restore(universes map[int] *DMXUniverse, priority int) map[int] *DMXSource {
+  sources := make(map[int] *DMXSource)
+
+  for number, u := range universes {
+    if s, exists := snap[number]; exists {
+      sources[number] = s.Restore(u, priority)
+    }
+  }
+
+  return sources
+}
+
+func diffFrames(universe int, old, newer *UniverseSnapshot) []Change {
+  empty := NewUniverseSnapshot(nil)
+
+  if old == nil {
+    old = empty
+  }
+  if newer == nil {
+    newer = empty
+  }
+
+  changes := make([]Change, 0)
+  for i := 0; i < UniverseSize; i++ {
+    if old.frame[i] != newer.frame[i] {
+      changes = append(changes, Change{universe, i + 1, old.frame[i], newer.frame[i]})
+    }
+  }
+
+  return changes
+}
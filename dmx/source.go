@@ -0,0 +1,165 @@
+/*
+Universe sources
+
+A DMXSource sets channels in a universe alongside the values set through
+DMXChannel and Input, which act as a source at priority 0. Each channel is
+output from the highest priority source that has set it, and between sources
+of the same priority the latest to set the channel wins. Releasing a source
+returns its channels to whatever is underneath.
+*/
+package dmx
+
+import (
+  "sync"
+)
+
+type DMXSource struct {
+  universe *DMXUniverse
+  priority int
+
+  input chan DMXFrame
+  inputOnce sync.Once
+}
+
+// A source's values, owned by the universe's goroutine
+type sourceState struct {
+  priority int
+  values map[int] DMXValue
+
+  // When each channel was last set, for latest takes precedence
+  written map[int] uint64
+}
+
+// Add a new source to the universe
+func (u *DMXUniverse) NewSource(priority int) *DMXSource {
+  src := new(DMXSource)
+  src.universe = u
+  src.priority = priority
+
+  u.calls <- func(s *universeState) {
+    s.sources[src] = &sourceState{priority, make(map[int] DMXValue), make(map[int] uint64)}
+  }
+
+  return src
+}
+
+func (src *DMXSource) Universe() *DMXUniverse {
+  return src.universe
+}
+
+func (src *DMXSource) Priority() int {
+  return src.priority
+}
+
+/*
+Frames sent to Input set channels from 1 up to the length of the frame. Other
+channels are left as they are.
+*/
+func (src *DMXSource) Input() chan DMXFrame {
+  src.inputOnce.Do(func() {
+    src.input = make(chan DMXFrame)
+
+    go func() {
+      for frame := range src.input {
+        src.SetValues(1, frame)
+      }
+    }()
+  })
+
+  return src.input
+}
+
+func (src *DMXSource) SetValue(channel int, val DMXValue) {
+  src.SetValues(channel, []DMXValue{val})
+}
+
+// Set consecutive channels starting at channel in one step
+func (src *DMXSource) SetValues(channel int, values []DMXValue) {
+  values = append([]DMXValue{}, values...)
+
+  src.universe.calls <- func(s *universeState) {
+    state, exists := s.sources[src]
+    if !exists {
+      return
+    }
+
+    for i, val := range values {
+      n := channel + i
+      if n < 1 || n > UniverseSize {
+        continue
+      }
+
+      s.seq++
+      state.values[n] = val
+      state.written[n] = s.seq
+      s.changed[n] = true
+    }
+  }
+}
+
+// Stop setting a channel, leaving it to lower priority sources
+func (src *DMXSource) Clear(channel int) {
+  src.universe.calls <- func(s *universeState) {
+    if state, exists := s.sources[src]; exists {
+      delete(state.values, channel)
+      delete(state.written, channel)
+      s.changed[channel] = true
+    }
+  }
+}
+
+// Remove the source from the universe. It has no effect once released.
+func (src *DMXSource) Release() {
+  src.universe.calls <- func(s *universeState) {
+    if state, exists := s.sources[src]; exists {
+      for channel := range state.values {
+        s.changed[channel] = true
+      }
+      delete(s.sources, src)
+    }
+  }
+}
+
+/*
+Channels that have been set, by a change written to the channel or by any
+source, whatever their value now
+*/
+func (s *universeState) setChannels() map[int] bool {
+  set := make(map[int] bool)
+
+  for i, seq := range s.written {
+    if seq > 0 {
+      set[i + 1] = true
+    }
+  }
+
+  for _, state := range s.sources {
+    for channel := range state.values {
+      set[channel] = true
+    }
+  }
+
+  return set
+}
+
+// Resolve the value of every channel from the sources
+func (s *universeState) merge(frame DMXFrame) {
+  priorities := make([]int, UniverseSize)
+  written := make([]uint64, UniverseSize)
+
+  copy(frame, s.data)
+  copy(written, s.written)
+
+  for _, state := range s.sources {
+    for channel, val := range state.values {
+      i := channel - 1
+      seq := state.written[channel]
+
+      if state.priority > priorities[i] || (state.priority == priorities[i] && seq > written[i]) {
+        frame[i] = val
+        priorities[i] = state.priority
+        written[i] = seq
+      }
+    }
+  }
+}
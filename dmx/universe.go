@@ -1,29 +1,128 @@
 /*
 Abstracts a DMX Universe across a physical input and output and stores the
 most recent values
+
+Frames are built from the values set on channels in stages. Values from any
+sources added to the universe are merged first, then channels tagged
+with IntensityClass are scaled by the universe's master level, then curves set
+on channels, or copied from the parameters patched to them, are applied and
+finally parked channels are replaced by their parked levels. Curves therefore
+always see the level after the master, so a preheat or a switched curve is not
+scaled away by a grand master. The values set on channels are always the values before
+any stage.
+
+All of the universe's state is owned by a single goroutine. Changes from
+channels and frames sent to Input are applied as they arrive, and every change
+made within one refresh period is delivered to Output as a single frame. Frames
+from Output and Watch are snapshots that are never modified afterwards so they
+can be kept or passed between goroutines freely.
 */
 package dmx
 
-import "fmt"
+import (
+  "math"
+  "time"
+  "golx/dmx/curve"
+  "golx/patch/chanutil"
+)
 
 const (
   UniverseSize int = 512
+
+  // At most 40 frames a second, the same as an Art-Net node
+  DefaultRefreshPeriod time.Duration = 25 * time.Millisecond
 )
 
 type DMXUniverse struct {
-  data DMXFrame
   output chan DMXFrame
   input chan DMXFrame
   channels [](*DMXChannel)
+
+  updates chan valueUpdate
+  calls chan func(*universeState)
+
+  refresh time.Duration
+
+  changes chan DMXFrame
+  watchers *chanutil.Broadcaster
+
+  levelChanges chan DMXFrame
+  levelWatchers *chanutil.Broadcaster
+
+  masterChanges chan float64
+  masterWatchers *chanutil.Broadcaster
+}
+
+// Values for consecutive channels starting at channel, applied together
+type valueUpdate struct {
+  channel int
+  values []DMXValue
+}
+
+// Consecutive slots carrying one parameter
+type classRange struct {
+  width int
+  order ByteOrder
+  class ParamClass
+}
+
+type curveRange struct {
+  width int
+  order ByteOrder
+  curve curve.Curve
+}
+
+// Everything owned by the universe's goroutine
+type universeState struct {
+  data DMXFrame
+
+  // When each channel of data was last set, counted by seq
+  written []uint64
+  seq uint64
+
+  sources map[*DMXSource] *sourceState
+
+  // Channels changed since the last frame was built
+  changed map[int] bool
+
+  // Where to send a channel's value when it changes
+  feeds map[int] chan DMXValue
+
+  // Curves by the first slot of the range they apply to
+  curves map[int] curveRange
+
+  // Tagged ranges by their first slot
+  classes map[int] classRange
+  master float64
+
+  parks map[int] Park
+
+  // Merged levels are only built while something watches them
+  levelWatches int
 }
 
 func NewDMXUniverse() *DMXUniverse {
   universe := new(DMXUniverse)
   universe.output = make(chan DMXFrame)
   universe.input = make(chan DMXFrame)
-  universe.data = make(DMXFrame, UniverseSize)
   universe.channels = make([](*DMXChannel), UniverseSize)
   universe.buildChannels()
+
+  universe.updates = make(chan valueUpdate)
+  universe.calls = make(chan func(*universeState))
+  universe.refresh = DefaultRefreshPeriod
+
+  universe.changes = make(chan DMXFrame)
+  universe.watchers, _ = chanutil.NewBroadcaster(universe.changes)
+
+  universe.levelChanges = make(chan DMXFrame)
+  universe.levelWatchers, _ = chanutil.NewBroadcaster(universe.levelChanges)
+
+  universe.masterChanges = make(chan float64)
+  universe.masterWatchers, _ = chanutil.NewBroadcaster(universe.masterChanges)
+
+  go universe.run()
+
   return universe
 }
 
@@ -31,14 +130,6 @@ func (u *DMXUniverse) String() string {
   return "DMXUniverse"
 }
 
-func (u *DMXUniverse) listen() {
-  for frame := range u.input {
-    for i := 1; i <= len(u.data) && i <= len(frame); i++ {
-      u.setValue(i, frame[i])
-    }
-  }
-}
-
 func (u *DMXUniverse) buildChannels() {
   for i := 0; i < UniverseSize; i++ {
     u.channels[i] = newDMXChannel(u, i + 1)
@@ -54,22 +145,322 @@ func (u *DMXUniverse) Channels() *[]*DMXChannel {
 }
 
 func (u *DMXUniverse) setValue(channel int, value DMXValue) {
-  fmt.Println("Universe got data")
-  if value != u.getValue(channel) {
-    u.data[channel - 1] = value
-    u.output <- u.data
-    u.GetChannel(channel).sendOutput()
-  }
+  u.updates <- valueUpdate{channel, []DMXValue{value}}
 }
 
 func (u *DMXUniverse) getValue(channel int) DMXValue {
-  return u.data[channel - 1]
+  return u.getValues(channel, 1)[0]
+}
+
+// Set consecutive channels in one step so they never appear in different frames
+func (u *DMXUniverse) setValues(channel int, values []DMXValue) {
+  u.updates <- valueUpdate{channel, values}
+}
+
+func (u *DMXUniverse) getValues(channel, width int) []DMXValue {
+  reply := make(chan []DMXValue)
+  u.calls <- func(s *universeState) {
+    values := make([]DMXValue, width)
+    copy(values, s.data[channel - 1:])
+    reply <- values
+  }
+  return <-reply
+}
+
+// Send a channel's value to feed each time it changes
+func (u *DMXUniverse) addFeed(channel int, feed chan DMXValue) {
+  u.calls <- func(s *universeState) {
+    s.feeds[channel] = feed
+  }
 }
 
 func (u *DMXUniverse) Input() chan DMXFrame {
   return u.input
 }
 
+/*
+Frames containing every change made to the universe. If the frame hasn't been
+read by the end of the next refresh period it is replaced by a newer one, so a
+slow reader only ever sees the latest state.
+*/
 func (u *DMXUniverse) Output() chan DMXFrame {
   return u.output
 }
+
+/*
+Get a channel that recieves a copy of the universe each time a value in it
+changes. Unlike Output any number of watchers can be added and frames are skipped
+rather than blocking if a watcher falls behind.
+*/
+func (u *DMXUniverse) Watch() chan DMXFrame {
+  return u.watchers.Subscribe().(chan DMXFrame)
+}
+
+// Stop sending frames to a channel returned by Watch
+func (u *DMXUniverse) Unwatch(watch chan DMXFrame) {
+  u.watchers.Unsubscribe(watch)
+}
+
+/*
+Like Watch, but recieves the merged levels of the channels and sources before
+the master, curves and parks are applied. These are the levels to set on a
+source to reproduce the output, e.g. when recording.
+*/
+func (u *DMXUniverse) WatchLevels() chan DMXFrame {
+  watch := u.levelWatchers.Subscribe().(chan DMXFrame)
+  u.calls <- func(s *universeState) {
+    s.levelWatches++
+  }
+  return watch
+}
+
+// Stop sending frames to a channel returned by WatchLevels
+func (u *DMXUniverse) UnwatchLevels(watch chan DMXFrame) {
+  u.levelWatchers.Unsubscribe(watch)
+  u.calls <- func(s *universeState) {
+    s.levelWatches--
+  }
+}
+
+/*
+Apply a curve to a channel as it is output. Setting a nil curve outputs the
+channel unchanged. Curves on individual slots are not suitable for multi-slot
+channels; set the curve on the DMXMultiChannel or the parameter instead.
+*/
+func (u *DMXUniverse) SetCurve(channel int, c curve.Curve) {
+  u.setCurve(channel, 1, MSBFirst, c)
+}
+
+// Apply a curve to width slots from channel as one level
+func (u *DMXUniverse) setCurve(channel, width int, order ByteOrder, c curve.Curve) {
+  if channel < 1 || channel + width - 1 > UniverseSize {
+    return
+  }
+
+  u.calls <- func(s *universeState) {
+    for start, r := range s.curves {
+      if start < channel + width && channel < start + r.width {
+        delete(s.curves, start)
+        s.touch(start, r.width)
+      }
+    }
+
+    if c != nil {
+      s.curves[channel] = curveRange{width, order, c}
+    }
+
+    // Resend the channel with its new curve
+    s.touch(channel, width)
+  }
+}
+
+/*
+Scale every channel tagged with IntensityClass by level, from 0 to 1. Channels
+of other classes are unaffected. This is normally set by a grand master rather
+than directly.
+*/
+func (u *DMXUniverse) SetMaster(level float64) {
+  level = math.Max(0, math.Min(1, level))
+
+  u.calls <- func(s *universeState) {
+    if s.master == level {
+      return
+    }
+
+    s.master = level
+    s.touchClass(IntensityClass)
+    u.masterChanges <- level
+  }
+}
+
+// The level intensity channels are currently scaled by
+func (u *DMXUniverse) Master() float64 {
+  reply := make(chan float64)
+  u.calls <- func(s *universeState) {
+    reply <- s.master
+  }
+  return <-reply
+}
+
+/*
+Get a channel that recieves the master level each time it changes, so
+intensities outside the universe, e.g. a dmxintensity.VirtualIntensity, can be
+scaled along with its intensity channels.
+*/
+func (u *DMXUniverse) WatchMaster() chan float64 {
+  return u.masterWatchers.Subscribe().(chan float64)
+}
+
+// Stop sending levels to a channel returned by WatchMaster
+func (u *DMXUniverse) UnwatchMaster(watch chan float64) {
+  u.masterWatchers.Unsubscribe(watch)
+}
+
+/*
+Tag width slots starting at channel as carrying a class of parameter, stored in
+the given byte order. Any existing tags overlapping the slots are removed.
+Tagging with UnknownClass just removes the tags.
+*/
+func (u *DMXUniverse) setClass(channel, width int, order ByteOrder, class ParamClass) {
+  u.calls <- func(s *universeState) {
+    for start, r := range s.classes {
+      if start < channel + width && channel < start + r.width {
+        delete(s.classes, start)
+        s.touch(start, r.width)
+      }
+    }
+
+    if class != UnknownClass {
+      s.classes[channel] = classRange{width, order, class}
+      s.touch(channel, width)
+    }
+  }
+}
+
+// The class of parameter driving a channel
+func (u *DMXUniverse) Class(channel int) ParamClass {
+  reply := make(chan ParamClass)
+  u.calls <- func(s *universeState) {
+    reply <- s.classAt(channel)
+  }
+  return <-reply
+}
+
+func newUniverseState() *universeState {
+  s := new(universeState)
+  s.data = make(DMXFrame, UniverseSize)
+  s.written = make([]uint64, UniverseSize)
+  s.sources = make(map[*DMXSource] *sourceState)
+  s.changed = make(map[int] bool)
+  s.feeds = make(map[int] chan DMXValue)
+  s.curves = make(map[int] curveRange)
+  s.classes = make(map[int] classRange)
+  s.master = 1
+  s.parks = make(map[int] Park)
+  return s
+}
+
+// Mark width channels starting at channel to be sent in the next frame
+func (s *universeState) touch(channel, width int) {
+  for n := channel; n < channel + width; n++ {
+    s.changed[n] = true
+  }
+}
+
+// Mark every channel of a class to be sent in the next frame
+func (s *universeState) touchClass(class ParamClass) {
+  for start, r := range s.classes {
+    if r.class == class {
+      s.touch(start, r.width)
+    }
+  }
+}
+
+func (s *universeState) classAt(channel int) ParamClass {
+  for start, r := range s.classes {
+    if channel >= start && channel < start + r.width {
+      return r.class
+    }
+  }
+  return UnknownClass
+}
+
+func (s *universeState) apply(channel int, values []DMXValue) {
+  for i, val := range values {
+    n := channel + i
+
+    if n < 1 || n > UniverseSize || s.data[n - 1] == val {
+      continue
+    }
+
+    // Only a change takes precedence over sources at the same priority
+    s.seq++
+    s.data[n - 1] = val
+    s.written[n - 1] = s.seq
+    s.changed[n] = true
+  }
+}
+
+// Build the output frame from the current data
+func (s *universeState) render() DMXFrame {
+  frame := make(DMXFrame, UniverseSize)
+  s.merge(frame)
+
+  if s.master < 1 {
+    for start, r := range s.classes {
+      if r.class != IntensityClass || start + r.width - 1 > UniverseSize {
+        continue
+      }
+
+      // Multi-slot channels are scaled as a whole so the fine slot stays in step
+      slots := frame[start - 1:start - 1 + r.width]
+      level := decodeLevel(slots, r.order) * DMXLevel(s.master)
+      copy(slots, encodeLevel(level, r.width, r.order))
+    }
+  }
+
+  for start, r := range s.curves {
+    slots := frame[start - 1:start - 1 + r.width]
+    level := r.curve.Apply(float64(decodeLevel(slots, r.order)))
+    copy(slots, encodeLevel(DMXLevel(level), r.width, r.order))
+  }
+
+  for channel, park := range s.parks {
+    frame[channel - 1] = park.Level
+  }
+
+  return frame
+}
+
+// Owns the universe's data and delivers frames
+func (u *DMXUniverse) run() {
+  s := newUniverseState()
+
+  // Only set while there are changes waiting to be built into a frame
+  var flush <-chan time.Time = nil
+
+  // Only set while there is a frame waiting for the output to read it
+  var output chan DMXFrame = nil
+  var pending DMXFrame
+
+  for {
+    select {
+    case update := <-u.updates:
+      s.apply(update.channel, update.values)
+    case frame := <-u.input:
+      s.apply(1, frame)
+    case call := <-u.calls:
+      call(s)
+    case _ = <-flush:
+      flush = nil
+
+      frame := s.render()
+
+      pending = frame
+      output = u.output
+
+      // The broadcasters and channel feeds always accept data promptly
+      u.changes <- frame
+
+      if s.levelWatches > 0 {
+        levels := make(DMXFrame, UniverseSize)
+        s.merge(levels)
+        u.levelChanges <- levels
+      }
+
+      for channel := range s.changed {
+        if feed, exists := s.feeds[channel]; exists {
+          feed <- frame[channel - 1]
+        }
+      }
+
+      s.changed = make(map[int] bool)
+    case output <- pending:
+      output = nil
+    }
+
+    if len(s.changed) > 0 && flush == nil {
+      flush = time.After(u.refresh)
+    }
+  }
+}
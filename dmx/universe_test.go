@@ -0,0 +1,466 @@
+package dmx
+
+import (
+  "sync"
+  "testing"
+  "time"
+  "golx/dmx/curve"
+)
+
+// Read frames until one satisfies done or the timeout passes
+func waitForFrame(frames chan DMXFrame, timeout time.Duration, done func(DMXFrame) bool) (DMXFrame, int) {
+  deadline := time.After(timeout)
+  count := 0
+
+  for {
+    select {
+    case frame := <-frames:
+      count++
+      if done(frame) {
+        return frame, count
+      }
+    case _ = <-deadline:
+      return nil, count
+    }
+  }
+}
+
+func TestConcurrentChannelWrites(t *testing.T) {
+  u := NewDMXUniverse()
+
+  var wg sync.WaitGroup
+
+  for i := 1; i <= UniverseSize; i++ {
+    wg.Add(1)
+    go func(n int) {
+      defer wg.Done()
+      u.GetChannel(n).Input() <- DMXValue(n % 256)
+    }(i)
+  }
+
+  wg.Wait()
+
+  frame, _ := waitForFrame(u.Output(), time.Second, func(f DMXFrame) bool {
+    for i := range f {
+      if f[i] != DMXValue((i + 1) % 256) {
+        return false
+      }
+    }
+    return true
+  })
+
+  if frame == nil {
+    t.Log("Universe never output every channel's value")
+    t.Fail()
+  }
+
+  if u.GetChannel(300).Value() != DMXValue(300 % 256) {
+    t.Log("Channel value is incorrect: ", u.GetChannel(300).Value())
+    t.Fail()
+  }
+}
+
+func TestChangesAreCoalesced(t *testing.T) {
+  u := NewDMXUniverse()
+  c := u.GetChannel(1).Input()
+
+  for i := 1; i <= 200; i++ {
+    c <- DMXValue(i)
+  }
+
+  frame, count := waitForFrame(u.Output(), time.Second, func(f DMXFrame) bool {
+    return f[0] == 200
+  })
+
+  if frame == nil {
+    t.Log("Final value was never output")
+    t.FailNow()
+  }
+
+  // 200 changes take far less than 20 refresh periods to send
+  if count > 20 {
+    t.Log("Changes were not coalesced, got ", count, " frames")
+    t.Fail()
+  }
+}
+
+func TestSlowOutputDoesNotBlockChannels(t *testing.T) {
+  u := NewDMXUniverse()
+  c := u.GetChannel(5).Input()
+
+  // Nothing reads Output while the channel changes over several refreshes
+  done := make(chan bool)
+  go func() {
+    for i := 0; i < 10; i++ {
+      c <- DMXValue(i)
+      time.Sleep(DefaultRefreshPeriod)
+    }
+    done <- true
+  }()
+
+  select {
+  case _ = <-done:
+  case _ = <-time.After(5 * time.Second):
+    t.Log("Writing to a channel blocked on the universe output")
+    t.FailNow()
+  }
+
+  // The output has the latest frame, not the first
+  frame, _ := waitForFrame(u.Output(), time.Second, func(f DMXFrame) bool { return true })
+
+  if frame == nil || frame[4] != 9 {
+    t.Log("Output did not have the latest frame: ", frame)
+    t.Fail()
+  }
+}
+
+func TestInputFramesApply(t *testing.T) {
+  u := NewDMXUniverse()
+  watch := u.Watch()
+
+  input := make(DMXFrame, 3)
+  input[0], input[1], input[2] = 11, 22, 33
+  u.Input() <- input
+
+  frame, _ := waitForFrame(watch, time.Second, func(f DMXFrame) bool { return f[2] == 33 })
+
+  if frame == nil || frame[0] != 11 || frame[1] != 22 {
+    t.Log("Input frame was not applied: ", frame)
+    t.FailNow()
+  }
+
+  // Frames are snapshots that don't change with the universe
+  u.GetChannel(1).Input() <- 99
+  waitForFrame(watch, time.Second, func(f DMXFrame) bool { return f[0] == 99 })
+
+  if frame[0] != 11 {
+    t.Log("Frame was modified after it was delivered")
+    t.Fail()
+  }
+}
+
+func TestChannelOutput(t *testing.T) {
+  u := NewDMXUniverse()
+  output := u.GetChannel(7).Output()
+
+  u.GetChannel(7).Input() <- 70
+
+  select {
+  case val := <-output:
+    if val != 70 {
+      t.Log("Channel output the wrong value: ", val)
+      t.Fail()
+    }
+  case _ = <-time.After(time.Second):
+    t.Log("Channel did not output its new value")
+    t.Fail()
+  }
+}
+
+func TestMultiChannelEncoding(t *testing.T) {
+  u := NewDMXUniverse()
+
+  msb, _ := u.GetMultiChannel(1, 2, MSBFirst)
+  lsb, _ := u.GetMultiChannel(3, 2, LSBFirst)
+  wide, _ := u.GetMultiChannel(5, 3, MSBFirst)
+
+  msb.SetValue(0.5)
+  lsb.SetValue(0.5)
+  wide.SetValue(1)
+
+  values := u.getValues(1, 7)
+  expected := []DMXValue{0x80, 0x00, 0x00, 0x80, 0xff, 0xff, 0xff}
+
+  for i := range expected {
+    if values[i] != expected[i] {
+      t.Log("Slots were set to ", values, " expected ", expected)
+      t.FailNow()
+    }
+  }
+
+  if level := lsb.Value(); level < 0.49 || level > 0.51 {
+    t.Log("Level read back incorrectly: ", level)
+    t.Fail()
+  }
+
+  if _, err := u.GetMultiChannel(511, 3, MSBFirst); err == nil {
+    t.Log("Channel past the end of the universe was allowed")
+    t.Fail()
+  }
+}
+
+func TestMultiChannelDoesNotTear(t *testing.T) {
+  u := NewDMXUniverse()
+  watch := u.Watch()
+
+  channel, _ := u.GetMultiChannel(10, 2, MSBFirst)
+  input := channel.Input()
+
+  // Alternate across a coarse boundary while frames are being built
+  done := make(chan bool)
+  go func() {
+    for i := 0; i <= 2000; i++ {
+      input <- DMXLevel(float64(i % 2 * 255 + 128) / 65535)
+    }
+    done <- true
+  }()
+
+  // Only 0x0080 and 0x017F are ever sent, so any other pair is a torn update
+  for {
+    select {
+    case f := <-watch:
+      coarse, fine := f[9], f[10]
+      if !(coarse == 0x00 && fine == 0x80) && !(coarse == 0x01 && fine == 0x7f) {
+        t.Log("Torn frame: ", coarse, fine)
+        t.FailNow()
+      }
+    case _ = <-done:
+      return
+    }
+  }
+}
+
+func TestChannelCurves(t *testing.T) {
+  u := NewDMXUniverse()
+  watch := u.Watch()
+
+  u.GetChannel(1).SetCurve(curve.SquareLaw{})
+  u.GetChannel(2).SetCurve(curve.NewLimit(curve.Linear{}, 0.2, 0.8))
+
+  u.GetChannel(1).Input() <- 128
+  u.GetChannel(3).Input() <- 128
+
+  frame, _ := waitForFrame(watch, time.Second, func(f DMXFrame) bool { return f[2] == 128 })
+
+  if frame == nil || frame[0] != 64 || frame[1] != 51 {
+    t.Log("Curves were not applied to the output: ", frame)
+    t.FailNow()
+  }
+
+  // The channel keeps the value set on it
+  if u.GetChannel(1).Value() != 128 {
+    t.Log("Channel value was changed by its curve")
+    t.Fail()
+  }
+
+  u.GetChannel(1).SetCurve(nil)
+  frame, _ = waitForFrame(watch, time.Second, func(f DMXFrame) bool { return f[0] == 128 })
+
+  if frame == nil {
+    t.Log("Removing the curve did not restore the value")
+    t.Fail()
+  }
+}
+
+func TestMultiChannelCurves(t *testing.T) {
+  u := NewDMXUniverse()
+  watch := u.Watch()
+
+  channel, _ := u.GetMultiChannel(1, 2, MSBFirst)
+  channel.SetCurve(curve.SquareLaw{})
+  channel.Input() <- 0.5
+
+  // The curve is applied to the whole level, not to each slot
+  frame, _ := waitForFrame(watch, time.Second, func(f DMXFrame) bool { return f[0] == 64 })
+
+  if frame == nil || frame[1] != 0 {
+    t.Log("Curve was not applied to the whole channel: ", frame)
+    t.Fail()
+  }
+
+  // A curve on one of its slots replaces it
+  u.GetChannel(2).SetCurve(curve.Linear{})
+  frame, _ = waitForFrame(watch, time.Second, func(f DMXFrame) bool { return f[0] == 128 })
+
+  if frame == nil {
+    t.Log("Overlapping curve did not replace the channel's curve")
+    t.Fail()
+  }
+}
+
+func TestParkOverridesOutput(t *testing.T) {
+  u := NewDMXUniverse()
+  watch := u.Watch()
+
+  u.GetChannel(1).SetCurve(curve.SquareLaw{})
+  u.GetChannel(1).Input() <- 100
+  u.GetChannel(2).Input() <- 100
+
+  if err := u.ParkRange(1, 2, 255, "Work light"); err != nil {
+    t.Log("Error parking: ", err.Error())
+    t.FailNow()
+  }
+
+  frame, _ := waitForFrame(watch, time.Second, func(f DMXFrame) bool { return f[0] == 255 && f[1] == 255 })
+
+  if frame == nil {
+    t.Log("Parked channels were not output at their parked level")
+    t.FailNow()
+  }
+
+  // Reloading the show sends whole frames, which the park ignores
+  u.Input() <- make(DMXFrame, UniverseSize)
+  u.GetChannel(3).Input() <- 30
+
+  frame, _ = waitForFrame(watch, time.Second, func(f DMXFrame) bool { return f[2] == 30 })
+
+  if frame == nil || frame[0] != 255 || frame[1] != 255 {
+    t.Log("Park did not survive new input: ", frame)
+    t.Fail()
+  }
+
+  parked := u.Parked()
+
+  if len(parked) != 2 || parked[0].Channel != 1 || parked[1].Reason != "Work light" {
+    t.Log("Parked channels listed incorrectly: ", parked)
+    t.Fail()
+  }
+
+  u.GetChannel(1).Input() <- 128
+  u.GetChannel(1).Unpark()
+
+  // The channel returns to its own value with its curve
+  frame, _ = waitForFrame(watch, time.Second, func(f DMXFrame) bool { return f[0] == 64 })
+
+  if frame == nil || frame[1] != 255 || u.IsParked(1) {
+    t.Log("Unparking did not restore the channel: ", frame)
+    t.Fail()
+  }
+
+  if u.Park(0, 0, "") == nil {
+    t.Log("Parking a channel outside the universe was allowed")
+    t.Fail()
+  }
+}
+
+func TestSourcePriority(t *testing.T) {
+  u := NewDMXUniverse()
+  watch := u.Watch()
+
+  low := u.NewSource(0)
+  high := u.NewSource(10)
+
+  // Channel inputs are applied asynchronously so wait before overriding
+  u.GetChannel(1).Input() <- 10
+  waitForFrame(watch, time.Second, func(f DMXFrame) bool { return f[0] == 10 })
+  low.SetValue(1, 20)
+  high.SetValue(2, 30)
+  u.GetChannel(2).Input() <- 40
+
+  // Latest wins at the same priority, higher priority wins regardless
+  frame, _ := waitForFrame(watch, time.Second, func(f DMXFrame) bool { return f[0] == 20 && f[1] == 30 })
+
+  if frame == nil {
+    t.Log("Sources were not merged by priority")
+    t.FailNow()
+  }
+
+  u.GetChannel(1).Input() <- 50
+  high.Release()
+
+  frame, _ = waitForFrame(watch, time.Second, func(f DMXFrame) bool { return f[0] == 50 && f[1] == 40 })
+
+  if frame == nil {
+    t.Log("Channels did not return to the values underneath")
+    t.Fail()
+  }
+}
+
+func TestSnapshotDiffAndRestore(t *testing.T) {
+  u := NewDMXUniverse()
+  watch := u.Watch()
+
+  u.GetChannel(1).Input() <- 100
+  u.GetChannel(2).Input() <- 200
+  u.Park(3, 255, "Work light")
+  waitForFrame(watch, time.Second, func(f DMXFrame) bool { return f[0] == 100 && f[1] == 200 && f[2] == 255 })
+
+  look := u.Snapshot()
+
+  if look.Value(1) != 100 || !look.IsParked(3) || look.IsParked(1) {
+    t.Log("Snapshot did not capture the output and parks")
+    t.FailNow()
+  }
+
+  u.GetChannel(1).Input() <- 0
+  u.GetChannel(4).Input() <- 50
+  waitForFrame(watch, time.Second, func(f DMXFrame) bool { return f[0] == 0 && f[3] == 50 })
+
+  changes := look.Diff(u.Snapshot())
+
+  if len(changes) != 2 || changes[0] != (Change{0, 1, 100, 0}) || changes[1] != (Change{0, 4, 0, 50}) {
+    t.Log("Diff was incorrect: ", changes)
+    t.Fail()
+  }
+
+  // Restoring the look brings channel 1 back without touching the park
+  u.Unpark(3)
+  src := look.Restore(u, 5)
+  frame, _ := waitForFrame(watch, time.Second, func(f DMXFrame) bool { return f[0] == 100 })
+
+  if frame == nil || frame[2] != 0 || frame[3] != 0 {
+    t.Log("Snapshot was not restored: ", frame)
+    t.Fail()
+  }
+
+  src.Release()
+  frame, _ = waitForFrame(watch, time.Second, func(f DMXFrame) bool { return f[0] == 0 && f[3] == 50 })
+
+  if frame == nil {
+    t.Log("Releasing the restored look did not return to the live values")
+    t.Fail()
+  }
+}
+
+func TestRestoreIsNotScaledTwice(t *testing.T) {
+  u := NewDMXUniverse()
+  watch := u.Watch()
+
+  u.GetChannel(1).SetClass(IntensityClass)
+  u.GetChannel(2).SetCurve(curve.SquareLaw{})
+  u.SetMaster(0.5)
+  u.GetChannel(1).Input() <- 200
+  u.GetChannel(2).Input() <- 128
+  waitForFrame(watch, time.Second, func(f DMXFrame) bool { return f[0] == 100 && f[1] == 64 })
+
+  look := u.Snapshot()
+
+  if look.Value(1) != 100 || look.Levels()[0] != 200 || look.Levels()[1] != 128 {
+    t.Log("Snapshot output was ", look.Value(1), " with levels ", look.Levels()[:2])
+    t.FailNow()
+  }
+
+  u.GetChannel(1).Input() <- 0
+  u.GetChannel(2).Input() <- 0
+  waitForFrame(watch, time.Second, func(f DMXFrame) bool { return f[0] == 0 && f[1] == 0 })
+
+  // The restored look passes through the master and curve once, as it was taken
+  look.Restore(u, 5)
+  frame, _ := waitForFrame(watch, time.Second, func(f DMXFrame) bool { return f[0] != 0 && f[1] != 0 })
+
+  if frame == nil || frame[0] != 100 || frame[1] != 64 {
+    t.Log("Restored look output as ", frame)
+    t.Fail()
+  }
+}
+
+func TestMultiUniverseDiff(t *testing.T) {
+  a := NewUniverseSnapshot(DMXFrame{1, 2})
+  b := NewUniverseSnapshot(DMXFrame{1, 3})
+
+  changes := Snapshot{1: a, 2: a}.Diff(Snapshot{1: b, 3: a})
+
+  expected := []Change{{1, 2, 2, 3}, {2, 1, 1, 0}, {2, 2, 2, 0}, {3, 1, 0, 1}, {3, 2, 0, 2}}
+
+  if len(changes) != len(expected) {
+    t.Log("Wrong number of changes: ", changes)
+    t.FailNow()
+  }
+
+  for i := range expected {
+    if changes[i] != expected[i] {
+      t.Log("Change ", i, " was ", changes[i], " expected ", expected[i])
+      t.Fail()
+    }
+  }
+}
@@ -1 +1,180 @@
-/* Converter
+/*
+Converter
+
+Converts between the physical values of attributes, such as a zoom angle in
+degrees or a strobe rate in Hz, and the DMX values fixtures need for them.
+Fixtures rarely respond linearly across their whole range, so conversions are
+piecewise linear between points measured for each fixture model. DMX values are
+on the attribute's coarse channel but may be fractional to use the resolution
+of any fine channels, and Level turns them into levels for all the channels.
+*/
+package converter
+
+import (
+  "errors"
+  "fmt"
+  "sort"
+  "golx/dmx"
+)
+
+type Converter interface {
+  // The DMX value for a physical value, limited to what the fixture can do
+  DMX(value float64) float64
+
+  // The physical value a DMX value gives
+  Value(dmx float64) float64
+}
+
+// A physical value and the DMX value that gives it
+type Point struct {
+  DMX float64 `json:"dmx"`
+  Value float64 `json:"value"`
+}
+
+/*
+A conversion through a list of points. Physical values must only increase or
+only decrease as DMX values increase so that every value has one DMX value.
+*/
+type Piecewise struct {
+  points []Point
+  increasing bool
+}
+
+func NewPiecewise(points []Point) (*Piecewise, error) {
+  if len(points) < 2 {
+    return nil, errors.New("A conversion needs at least two points")
+  }
+
+  p := new(Piecewise)
+  p.points = append([]Point{}, points...)
+
+  sort.SliceStable(p.points, func(i, j int) bool {
+    return p.points[i].DMX < p.points[j].DMX
+  })
+
+  first, last := p.points[0], p.points[len(p.points) - 1]
+  if first.Value == last.Value {
+    return nil, errors.New("A conversion must cover more than one value")
+  }
+  p.increasing = last.Value > first.Value
+
+  for i := 1; i < len(p.points); i++ {
+    prev, point := p.points[i - 1], p.points[i]
+
+    if point.DMX == prev.DMX {
+      return nil, fmt.Errorf("DMX value %g is in the conversion twice", point.DMX)
+    }
+
+    if (p.increasing && point.Value < prev.Value) || (!p.increasing && point.Value > prev.Value) {
+      return nil, fmt.Errorf("Value %g at DMX %g goes back on itself", point.Value, point.DMX)
+    }
+  }
+
+  return p, nil
+}
+
+// Convert linearly from one DMX value and physical value to another
+func Linear(fromDMX, fromValue, toDMX, toValue float64) (*Piecewise, error) {
+  return NewPiecewise([]Point{{fromDMX, fromValue}, {toDMX, toValue}})
+}
+
+func (p *Piecewise) Points() []Point {
+  return append([]Point{}, p.points...)
+}
+
+// The smallest physical value the conversion covers
+func (p *Piecewise) Min() float64 {
+  if p.increasing {
+    return p.points[0].Value
+  }
+  return p.points[len(p.points) - 1].Value
+}
+
+// The largest physical value the conversion covers
+func (p *Piecewise) Max() float64 {
+  if p.increasing {
+    return p.points[len(p.points) - 1].Value
+  }
+  return p.points[0].Value
+}
+
+func interpolate(x, x0, x1, y0, y1 float64) float64 {
+  if x1 == x0 {
+    return y0
+  }
+  return y0 + (x - x0) * (y1 - y0) / (x1 - x0)
+}
+
+/*
+The DMX value for a physical value. Values outside the conversion are limited
+to its ends. Where a value is held across a range of DMX values the first is
+used.
+*/
+func (p *Piecewise) DMX(value float64) float64 {
+  if value <= p.Min() {
+    value = p.Min()
+  } else if value >= p.Max() {
+    value = p.Max()
+  }
+
+  for i := 1; i < len(p.points); i++ {
+    a, b := p.points[i - 1], p.points[i]
+
+    if (value >= a.Value && value <= b.Value) || (value <= a.Value && value >= b.Value) {
+      return interpolate(value, a.Value, b.Value, a.DMX, b.DMX)
+    }
+  }
+
+  return p.points[len(p.points) - 1].DMX
+}
+
+// The physical value of a DMX value, limited to the ends of the conversion
+func (p *Piecewise) Value(dmx float64) float64 {
+  first, last := p.points[0], p.points[len(p.points) - 1]
+
+  if dmx <= first.DMX {
+    return first.Value
+  }
+  if dmx >= last.DMX {
+    return last.Value
+  }
+
+  for i := 1; i < len(p.points); i++ {
+    a, b := p.points[i - 1], p.points[i]
+
+    if dmx <= b.DMX {
+      return interpolate(dmx, a.DMX, b.DMX, a.Value, b.Value)
+    }
+  }
+
+  return last.Value
+}
+
+/*
+The level for a possibly fractional DMX value of the coarse channel, at the
+full resolution of width channels
+*/
+func Level(width int, value float64) dmx.DMXLevel {
+  scale := float64(uint64(1) << uint(8 * (width - 1)))
+  level := value * scale / float64(dmx.MaxLevel(width))
+
+  if level > 1 {
+    return 1
+  } else if level < 0 {
+    return 0
+  }
+  return dmx.DMXLevel(level)
+}
+
+/*
+The level at a fraction of the way through the coarse values from and to,
+running from the first fine value of from to the last fine value of to
+*/
+func RangeLevel(width, from, to int, fraction float64) dmx.DMXLevel {
+  step := 1 / float64(uint64(1) << uint(8 * (width - 1)))
+
+  low := float64(from)
+  high := float64(to + 1) - step
+
+  return Level(width, low + fraction * (high - low))
+}
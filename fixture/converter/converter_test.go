@@ -0,0 +1,101 @@
+package converter
+
+import (
+  "math"
+  "testing"
+)
+
+func near(a, b float64) bool {
+  return math.Abs(a - b) < 1e-9
+}
+
+func TestPiecewise(t *testing.T) {
+  // A zoom that narrows quickly then slowly as the DMX value rises
+  zoom, err := NewPiecewise([]Point{{255, 5}, {0, 40}, {64, 20}})
+  if err != nil {
+    t.Log(err.Error())
+    t.FailNow()
+  }
+
+  if zoom.Min() != 5 || zoom.Max() != 40 {
+    t.Log("Zoom covers ", zoom.Min(), " to ", zoom.Max())
+    t.Fail()
+  }
+
+  tests := []struct {
+    value float64
+    dmx float64
+  }{
+    {40, 0},
+    {30, 32},
+    {20, 64},
+    {12.5, 159.5},
+    {5, 255},
+  }
+
+  for _, test := range tests {
+    if dmx := zoom.DMX(test.value); !near(dmx, test.dmx) {
+      t.Log("Zoom of ", test.value, " gave DMX ", dmx, " expected ", test.dmx)
+      t.Fail()
+    }
+
+    if value := zoom.Value(test.dmx); !near(value, test.value) {
+      t.Log("DMX ", test.dmx, " gave zoom ", value, " expected ", test.value)
+      t.Fail()
+    }
+  }
+
+  // Values outside the fixture's range are limited
+  if zoom.DMX(60) != 0 || zoom.DMX(1) != 255 || zoom.Value(300) != 5 {
+    t.Log("Values outside the conversion were not limited")
+    t.Fail()
+  }
+}
+
+func TestHeldValues(t *testing.T) {
+  // Strobe rates in two ranges that meet at 10Hz
+  strobe, err := NewPiecewise([]Point{{64, 1}, {127, 10}, {128, 10}, {255, 20}})
+  if err != nil {
+    t.Log(err.Error())
+    t.FailNow()
+  }
+
+  if strobe.DMX(10) != 127 || !near(strobe.DMX(15), 191.5) {
+    t.Log("Strobe conversion is incorrect: ", strobe.DMX(10), strobe.DMX(15))
+    t.Fail()
+  }
+}
+
+func TestInvalidPiecewise(t *testing.T) {
+  invalid := [][]Point{
+    {{0, 1}},
+    {{0, 1}, {255, 1}},
+    {{0, 1}, {0, 2}},
+    {{0, 1}, {128, 10}, {255, 5}},
+  }
+
+  for _, points := range invalid {
+    if _, err := NewPiecewise(points); err == nil {
+      t.Log("Built a conversion from ", points)
+      t.Fail()
+    }
+  }
+
+  if _, err := Linear(0, 0, 255, 100); err != nil {
+    t.Log(err.Error())
+    t.Fail()
+  }
+}
+
+func TestLevels(t *testing.T) {
+  if Level(1, 255) != 1 || Level(1, 300) != 1 || Level(2, -1) != 0 || !near(float64(Level(2, 128)), 128.0 * 256 / 65535) {
+    t.Log("Levels of coarse values are incorrect: ", Level(2, 128))
+    t.Fail()
+  }
+
+  // A 16 bit range runs from the first fine value of from to the last of to
+  if !near(float64(RangeLevel(2, 10, 19, 0)), 2560.0 / 65535) || !near(float64(RangeLevel(2, 10, 19, 1)), 5119.0 / 65535) {
+    t.Log("Range levels are incorrect: ", RangeLevel(2, 10, 19, 0), RangeLevel(2, 10, 19, 1))
+    t.Fail()
+  }
+}
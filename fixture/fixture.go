@@ -3,3 +3,42 @@ package fixture
 type Fixture interface {
   Attributes() map[string] Attribute
 }
+
+/*
+A fixture made of other fixtures, such as an LED batten or pixel bar made of
+cells. The parent's own attributes act on the whole fixture, e.g. a master
+intensity or a colour for every cell, and each child can be programmed on its
+own.
+*/
+type Parent interface {
+  Fixture
+
+  // The children in cell order, for effects and pixel mapping
+  Children() []Fixture
+}
+
+// The children of a fixture in cell order, or nil if it doesn't have any
+func Children(f Fixture) []Fixture {
+  if parent, ok := f.(Parent); ok {
+    return parent.Children()
+  }
+  return nil
+}
+
+/*
+The fixtures without children under a fixture, in cell order with each child's
+cells in place of the child. A fixture without children is its own only cell.
+*/
+func Cells(f Fixture) []Fixture {
+  children := Children(f)
+
+  if len(children) == 0 {
+    return []Fixture{f}
+  }
+
+  cells := make([]Fixture, 0, len(children))
+  for _, child := range children {
+    cells = append(cells, Cells(child)...)
+  }
+  return cells
+}
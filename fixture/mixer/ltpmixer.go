@@ -4,6 +4,7 @@ import (
   "reflect"
   "errors"
   "fmt"
+  "sync/atomic"
   "golx/patch/chanutil"
 )
 
@@ -60,15 +61,11 @@ func NewLTPMixer(output, def interface {}) (*LTPMixer, error) {
   return mixer, nil
 }
 
-var counter int
-
-func init() {
-  counter = 1
-}
+var counter int32 = 1
 
+// Mixers are started from many goroutines so the counter is atomic
 func token() int {
-  counter += 1
-  return counter
+  return int(atomic.AddInt32(&counter, 1))
 }
 
 func (mixer *LTPMixer) Stop() {
@@ -122,7 +119,7 @@ func (mixer *LTPMixer) mix(output reflect.Value) {
       }
     case 3:
       fmt.Println(loopToken, "Got stop. Quitting")
-      reflect.Indirect(head).FieldByName("Stop").Send(recv)
+      reflect.Indirect(head).FieldByName("Stop").Elem().Send(recv)
       return
     default:
       fmt.Println(loopToken, "Got unexpected index")
@@ -205,7 +202,7 @@ func addLink(input, tail reflect.Value) reflect.Value {
         }
       case 1:
         // Stop command
-        reflect.Indirect(tail).FieldByName("Stop").Send(reflect.ValueOf(true))
+        reflect.Indirect(tail).FieldByName("Stop").Elem().Send(reflect.ValueOf(true))
         return
       case 2:
         // Next link quit, replace tail
@@ -0,0 +1,210 @@
+package mvr
+
+import (
+  "archive/zip"
+  "crypto/rand"
+  "encoding/xml"
+  "errors"
+  "fmt"
+  "io"
+  "os"
+  "sort"
+  "strconv"
+  "golx/dmx"
+)
+
+// Layer for fixtures that weren't given one
+const DefaultLayer = "GoLX"
+
+// A layer or group being built for export
+type node struct {
+  obj xmlObject
+  children []*node
+  groups map[string] *node
+}
+
+func newNode(kind, name, uuid string) *node {
+  n := new(node)
+  n.obj = xmlObject{XMLName: xml.Name{Local: kind}, Name: name, UUID: uuid}
+  n.groups = make(map[string] *node)
+  return n
+}
+
+func (n *node) items() []xmlObject {
+  items := make([]xmlObject, 0, len(n.children))
+
+  for _, child := range n.children {
+    obj := child.obj
+    if obj.XMLName.Local == "GroupObject" {
+      obj.Children = &xmlChildList{child.items()}
+    }
+    items = append(items, obj)
+  }
+
+  return items
+}
+
+/*
+Write the rig as an MVR archive. Fixtures are written in their layers and
+groups with absolute DMX addresses, and every GDTF file added to the rig is
+included. Fixtures, layers and groups without a UUID are given one, which is
+kept so later exports match.
+
+Fixtures whose GDTF file hasn't been added with AddType, such as those added
+with AddFixture, are still written but are reported as the archive doesn't
+describe their fixture type.
+*/
+func (r *Rig) Export(w io.Writer) ([]Warning, error) {
+  layers := make(map[string] *node)
+  order := make([]string, 0)
+  warnings := make([]Warning, 0)
+  missing := make(map[string] bool)
+
+  for _, f := range r.Fixtures {
+    layerName := f.Layer
+    if layerName == "" {
+      layerName = DefaultLayer
+    }
+
+    layer, exists := layers[layerName]
+    if !exists {
+      uuid, err := r.uuid(layerName)
+      if err != nil {
+        return warnings, err
+      }
+
+      layer = newNode("Layer", layerName, uuid)
+      layers[layerName] = layer
+      order = append(order, layerName)
+    }
+
+    parent := layer
+    for i, name := range f.Groups {
+      group, exists := parent.groups[name]
+      if !exists {
+        uuid, err := r.uuid(groupPath(layerName, f.Groups[:i + 1]))
+        if err != nil {
+          return warnings, err
+        }
+
+        group = newNode("GroupObject", name, uuid)
+        parent.groups[name] = group
+        parent.children = append(parent.children, group)
+      }
+      parent = group
+    }
+
+    if f.UUID == "" {
+      uuid, err := newUUID()
+      if err != nil {
+        return warnings, err
+      }
+      f.UUID = uuid
+    }
+
+    if spec := specFile(f.Spec); f.Spec != "" && r.specs[spec] == nil && !missing[spec] {
+      missing[spec] = true
+      warnings = append(warnings, Warning{f.Spec, "Fixture type is not in the rig, add it with AddType to include it in the archive"})
+    }
+
+    fixture := newNode("Fixture", f.Name, f.UUID)
+    fixture.obj.Matrix = formatMatrix(f.Transform)
+    fixture.obj.GDTFSpec = f.Spec
+    fixture.obj.GDTFMode = f.Mode
+    fixture.obj.FixtureID = f.FixtureID
+
+    if f.Address != 0 {
+      absolute := (f.Universe - 1) * dmx.UniverseSize + f.Address
+      fixture.obj.Addresses = []xmlAddress{{0, strconv.Itoa(absolute)}}
+    }
+
+    parent.children = append(parent.children, fixture)
+  }
+
+  doc := xmlScene{VerMajor: 1, VerMinor: 5}
+  for _, name := range order {
+    layer := layers[name]
+    doc.Layers = append(doc.Layers, xmlLayer{
+      Name: layer.obj.Name,
+      UUID: layer.obj.UUID,
+      Children: xmlChildList{layer.items()},
+    })
+  }
+
+  archive := zip.NewWriter(w)
+
+  scene, err := archive.Create(sceneFile)
+  if err != nil {
+    return warnings, err
+  }
+
+  if _, err := io.WriteString(scene, xml.Header); err != nil {
+    return warnings, err
+  }
+
+  encoder := xml.NewEncoder(scene)
+  encoder.Indent("", "  ")
+  if err := encoder.Encode(doc); err != nil {
+    return warnings, err
+  }
+
+  specs := make([]string, 0, len(r.specs))
+  for spec := range r.specs {
+    specs = append(specs, spec)
+  }
+  sort.Strings(specs)
+
+  for _, spec := range specs {
+    file, err := archive.Create(spec)
+    if err != nil {
+      return warnings, err
+    }
+
+    if _, err := file.Write(r.specs[spec]); err != nil {
+      return warnings, err
+    }
+  }
+
+  return warnings, archive.Close()
+}
+
+func (r *Rig) ExportFile(path string) ([]Warning, error) {
+  f, err := os.Create(path)
+
+  if err != nil {
+    return nil, err
+  }
+
+  warnings, err := r.Export(f)
+  if err != nil {
+    f.Close()
+    return warnings, err
+  }
+
+  return warnings, f.Close()
+}
+
+// The UUID of a layer or group, made the first time it is needed
+func (r *Rig) uuid(path string) (string, error) {
+  if r.uuids[path] == "" {
+    uuid, err := newUUID()
+    if err != nil {
+      return "", err
+    }
+    r.uuids[path] = uuid
+  }
+  return r.uuids[path], nil
+}
+
+// A random version 4 UUID
+func newUUID() (string, error) {
+  var b [16]byte
+  if _, err := rand.Read(b[:]); err != nil {
+    return "", errors.New("Making a UUID: " + err.Error())
+  }
+
+  b[6] = b[6] & 0x0f | 0x40
+  b[8] = b[8] & 0x3f | 0x80
+
+  return fmt.Sprintf("%X-%X-%X-%X-%X", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
@@ -0,0 +1,568 @@
+/*
+MVR import and export
+
+Reads My Virtual Rig files, the zip archives CAD software exports with a
+GeneralSceneDescription.xml and the GDTF files of the fixtures in the rig.
+Every fixture instance in the scene becomes a Fixture with its layer, the
+groups it is in, its DMX address and its position. Patch then builds profile
+fixtures from the embedded GDTF files and patches them to the universes.
+
+A rig can also be built from fixtures that are already patched and exported,
+so the drawing can be brought up to date with changes made on the console.
+
+MVR positions are in millimetres with a 4x3 matrix; they are converted to the
+4x4 matrices in metres used by GDTF geometry. Only fixtures are imported;
+trusses, scene objects and other objects are reported as warnings.
+*/
+package mvr
+
+import (
+  "archive/zip"
+  "bytes"
+  "encoding/xml"
+  "errors"
+  "fmt"
+  "io"
+  "os"
+  "strconv"
+  "strings"
+  "golx/dmx"
+  "golx/fixture/profile"
+  "golx/fixture/profile/gdtf"
+)
+
+const sceneFile = "GeneralSceneDescription.xml"
+
+// Something in a scene that couldn't be imported exactly
+type Warning struct {
+  Object string
+  Message string
+}
+
+func (w Warning) String() string {
+  if w.Object == "" {
+    return w.Message
+  }
+  return w.Object + ": " + w.Message
+}
+
+// A fixture instance in the rig
+type Fixture struct {
+  Name string
+  UUID string
+  FixtureID string
+
+  Layer string
+
+  // Names of the groups the fixture is in, outermost first
+  Groups []string
+
+  // The GDTF file and the DMX mode of the fixture type
+  Spec string
+  Mode string
+
+  // Zero if the fixture isn't patched
+  Universe int
+  Address int
+
+  // Relative to the origin of the scene, in metres
+  Transform gdtf.Matrix
+
+  // Nil if the rig doesn't contain the fixture type
+  Type *gdtf.FixtureType
+
+  instance *profile.Fixture
+}
+
+func (f *Fixture) String() string {
+  return fmt.Sprintf("[%s %s at %d/%d]", f.Name, f.Spec, f.Universe, f.Address)
+}
+
+// The profile fixture built by Rig.Patch or given to Rig.AddFixture
+func (f *Fixture) Instance() *profile.Fixture {
+  return f.instance
+}
+
+// Where the fixture is in the scene, in metres
+func (f *Fixture) Position() (x, y, z float64) {
+  return f.Transform.Translation()
+}
+
+type Rig struct {
+  Fixtures []*Fixture
+
+  // Fixture types and the GDTF archives they were read from, by file name
+  types map[string] *gdtf.FixtureType
+  specs map[string] []byte
+
+  // UUIDs of layers and groups by their path of names, kept between exports
+  uuids map[string] string
+}
+
+func NewRig() *Rig {
+  r := new(Rig)
+  r.Fixtures = make([]*Fixture, 0)
+  r.types = make(map[string] *gdtf.FixtureType)
+  r.specs = make(map[string] []byte)
+  r.uuids = make(map[string] string)
+  return r
+}
+
+/*
+Add a GDTF archive to the rig under its file name so fixtures can use it and it
+is included in exports
+*/
+func (r *Rig) AddType(spec string, archive []byte) (*gdtf.FixtureType, []gdtf.Warning, error) {
+  ft, warnings, err := gdtf.Import(bytes.NewReader(archive), int64(len(archive)))
+
+  if err != nil {
+    return nil, warnings, errors.New(spec + ": " + err.Error())
+  }
+
+  r.types[specFile(spec)] = ft
+  r.specs[specFile(spec)] = archive
+
+  for _, f := range r.Fixtures {
+    if specFile(f.Spec) == specFile(spec) {
+      f.Type = ft
+    }
+  }
+
+  return ft, warnings, nil
+}
+
+// The fixture type read from a GDTF file in the rig, or nil
+func (r *Rig) Type(spec string) *gdtf.FixtureType {
+  return r.types[specFile(spec)]
+}
+
+func (r *Rig) Add(f *Fixture) {
+  if f.Type == nil {
+    f.Type = r.types[specFile(f.Spec)]
+  }
+  r.Fixtures = append(r.Fixtures, f)
+}
+
+/*
+Add a fixture that is already patched, for export. The GDTF file name is made
+from the profile's manufacturer and model in the form used by the GDTF share.
+Add the GDTF file under that name with AddType for it to be included in
+exports.
+*/
+func (r *Rig) AddFixture(name string, f *profile.Fixture, universe int, transform gdtf.Matrix) *Fixture {
+  p := f.Profile()
+
+  rf := &Fixture{
+    Name: name,
+    Spec: p.Manufacturer + "@" + p.Model + ".gdtf",
+    Mode: f.Mode().Name,
+    Universe: universe,
+    Address: f.Address(),
+    Transform: transform,
+    instance: f,
+  }
+
+  r.Add(rf)
+  return rf
+}
+
+// The fixture with the given FixtureID, or nil
+func (r *Rig) Fixture(id string) *Fixture {
+  for _, f := range r.Fixtures {
+    if f.FixtureID == id {
+      return f
+    }
+  }
+  return nil
+}
+
+// Fixtures in the named group or any group inside it
+func (r *Rig) Group(name string) []*Fixture {
+  fixtures := make([]*Fixture, 0)
+
+  for _, f := range r.Fixtures {
+    for _, group := range f.Groups {
+      if group == name {
+        fixtures = append(fixtures, f)
+        break
+      }
+    }
+  }
+
+  return fixtures
+}
+
+func (r *Rig) Layer(name string) []*Fixture {
+  fixtures := make([]*Fixture, 0)
+
+  for _, f := range r.Fixtures {
+    if f.Layer == name {
+      fixtures = append(fixtures, f)
+    }
+  }
+
+  return fixtures
+}
+
+/*
+Build a profile fixture for every patched fixture with a fixture type and patch
+it to its universe. Fixtures that have already been patched are left alone.
+*/
+func (r *Rig) Patch(universes map[int] *dmx.DMXUniverse) error {
+  for _, f := range r.Fixtures {
+    if f.instance != nil || f.Address == 0 {
+      continue
+    }
+
+    if f.Type == nil {
+      return errors.New("Fixture " + f.Name + ": fixture type " + f.Spec + " is not in the rig")
+    }
+
+    u, exists := universes[f.Universe]
+    if !exists {
+      return fmt.Errorf("Fixture %s: there is no universe %d", f.Name, f.Universe)
+    }
+
+    instance, err := profile.New(f.Type.Profile, f.Mode, f.Address)
+    if err != nil {
+      return errors.New("Fixture " + f.Name + ": " + err.Error())
+    }
+
+    if err := instance.Patch(u); err != nil {
+      return errors.New("Fixture " + f.Name + ": " + err.Error())
+    }
+
+    f.instance = instance
+  }
+
+  return nil
+}
+
+type xmlScene struct {
+  XMLName xml.Name `xml:"GeneralSceneDescription"`
+  VerMajor int `xml:"verMajor,attr"`
+  VerMinor int `xml:"verMinor,attr"`
+  Layers []xmlLayer `xml:"Scene>Layers>Layer"`
+}
+
+type xmlLayer struct {
+  Name string `xml:"name,attr"`
+  UUID string `xml:"uuid,attr"`
+  Matrix string `xml:"Matrix,omitempty"`
+  Children xmlChildList `xml:"ChildList"`
+}
+
+type xmlChildList struct {
+  Items []xmlObject `xml:",any"`
+}
+
+// Any object in a child list, with the elements of fixtures and groups
+type xmlObject struct {
+  XMLName xml.Name
+  Name string `xml:"name,attr"`
+  UUID string `xml:"uuid,attr"`
+  Matrix string `xml:"Matrix,omitempty"`
+  GDTFSpec string `xml:"GDTFSpec,omitempty"`
+  GDTFMode string `xml:"GDTFMode,omitempty"`
+  Addresses []xmlAddress `xml:"Addresses>Address"`
+  FixtureID string `xml:"FixtureID,omitempty"`
+  Children *xmlChildList `xml:"ChildList"`
+}
+
+type xmlAddress struct {
+  Break int `xml:"break,attr"`
+  Value string `xml:",chardata"`
+}
+
+func ImportFile(path string) (*Rig, []Warning, error) {
+  f, err := os.Open(path)
+
+  if err != nil {
+    return nil, nil, err
+  }
+
+  defer f.Close()
+
+  info, err := f.Stat()
+
+  if err != nil {
+    return nil, nil, err
+  }
+
+  rig, warnings, err := Import(f, info.Size())
+
+  if err != nil {
+    return nil, warnings, errors.New(path + ": " + err.Error())
+  }
+
+  return rig, warnings, nil
+}
+
+// Read an MVR archive of size bytes
+func Import(r io.ReaderAt, size int64) (*Rig, []Warning, error) {
+  archive, err := zip.NewReader(r, size)
+
+  if err != nil {
+    return nil, nil, errors.New("Not an MVR archive: " + err.Error())
+  }
+
+  files := make(map[string] *zip.File)
+  for _, file := range archive.File {
+    files[file.Name] = file
+  }
+
+  scene, exists := files[sceneFile]
+  if !exists {
+    return nil, nil, errors.New("Archive has no " + sceneFile)
+  }
+
+  var doc xmlScene
+  if err := unmarshal(scene, &doc); err != nil {
+    return nil, nil, errors.New("Invalid scene description: " + err.Error())
+  }
+
+  if doc.VerMajor != 1 {
+    return nil, nil, fmt.Errorf("MVR version %d.%d is not supported", doc.VerMajor, doc.VerMinor)
+  }
+
+  imp := &importer{rig: NewRig(), skipped: make(map[string] int), warnings: make([]Warning, 0)}
+
+  for _, layer := range doc.Layers {
+    transform, err := parseMatrix(layer.Matrix)
+    if err != nil {
+      return nil, imp.warnings, errors.New("Layer " + layer.Name + ": " + err.Error())
+    }
+
+    imp.rig.uuids[layer.Name] = layer.UUID
+
+    if err := imp.objects(layer.Children.Items, layer.Name, nil, transform); err != nil {
+      return nil, imp.warnings, err
+    }
+  }
+
+  for kind, count := range imp.skipped {
+    imp.warn("", "%d %s objects are not supported and were left out", count, kind)
+  }
+
+  // Read each fixture type once, however many fixtures use it
+  read := make(map[string] bool)
+  for _, f := range imp.rig.Fixtures {
+    if f.Spec == "" || read[specFile(f.Spec)] {
+      continue
+    }
+    read[specFile(f.Spec)] = true
+
+    file, exists := files[specFile(f.Spec)]
+    if !exists {
+      imp.warn(f.Spec, "Fixture type is not in the archive, its fixtures can't be patched")
+      continue
+    }
+
+    data, err := readFile(file)
+    if err != nil {
+      return nil, imp.warnings, err
+    }
+
+    _, warnings, err := imp.rig.AddType(f.Spec, data)
+    for _, w := range warnings {
+      imp.warn(f.Spec, "%s", w.String())
+    }
+    if err != nil {
+      return nil, imp.warnings, err
+    }
+  }
+
+  return imp.rig, imp.warnings, nil
+}
+
+type importer struct {
+  rig *Rig
+
+  // Number of objects of each unsupported kind
+  skipped map[string] int
+
+  warnings []Warning
+}
+
+func (imp *importer) warn(object, format string, args ...interface {}) {
+  imp.warnings = append(imp.warnings, Warning{object, fmt.Sprintf(format, args...)})
+}
+
+func (imp *importer) objects(items []xmlObject, layer string, groups []string, parent gdtf.Matrix) error {
+  for _, item := range items {
+    local, err := parseMatrix(item.Matrix)
+    if err != nil {
+      return errors.New(item.XMLName.Local + " " + item.Name + ": " + err.Error())
+    }
+    transform := parent.Mul(local)
+
+    switch item.XMLName.Local {
+    case "Fixture":
+      if err := imp.fixture(item, layer, groups, transform); err != nil {
+        return errors.New("Fixture " + item.Name + ": " + err.Error())
+      }
+    case "GroupObject":
+      if item.Children != nil {
+        inner := append(append([]string{}, groups...), item.Name)
+        imp.rig.uuids[groupPath(layer, inner)] = item.UUID
+        if err := imp.objects(item.Children.Items, layer, inner, transform); err != nil {
+          return err
+        }
+      }
+    default:
+      imp.skipped[item.XMLName.Local]++
+    }
+  }
+
+  return nil
+}
+
+func (imp *importer) fixture(item xmlObject, layer string, groups []string, transform gdtf.Matrix) error {
+  f := &Fixture{
+    Name: item.Name,
+    UUID: item.UUID,
+    FixtureID: strings.TrimSpace(item.FixtureID),
+    Layer: layer,
+    Groups: groups,
+    Spec: strings.TrimSpace(item.GDTFSpec),
+    Mode: strings.TrimSpace(item.GDTFMode),
+    Transform: transform,
+  }
+
+  if len(item.Addresses) > 1 {
+    imp.warn(item.Name, "Fixtures using more than one DMX break are not supported, only the first address was imported")
+  }
+
+  if len(item.Addresses) > 0 {
+    universe, address, err := parseAddress(item.Addresses[0].Value)
+    if err != nil {
+      return err
+    }
+    f.Universe = universe
+    f.Address = address
+  }
+
+  if f.Spec == "" {
+    imp.warn(item.Name, "Fixture has no fixture type")
+  }
+
+  imp.rig.Add(f)
+  return nil
+}
+
+// The name of a GDTF file, which scenes may give without its extension
+func specFile(spec string) string {
+  if strings.HasSuffix(strings.ToLower(spec), ".gdtf") {
+    return spec
+  }
+  return spec + ".gdtf"
+}
+
+// A key for a layer or a group within it
+func groupPath(layer string, groups []string) string {
+  return strings.Join(append([]string{layer}, groups...), "\x00")
+}
+
+/*
+Parse a DMX address, either absolute counting from 1 in universe 1 or in the
+form universe.address
+*/
+func parseAddress(text string) (int, int, error) {
+  text = strings.TrimSpace(text)
+
+  if parts := strings.SplitN(text, ".", 2); len(parts) == 2 {
+    universe, err1 := strconv.Atoi(parts[0])
+    address, err2 := strconv.Atoi(parts[1])
+    if err1 != nil || err2 != nil || universe < 1 || address < 1 || address > dmx.UniverseSize {
+      return 0, 0, errors.New("Invalid address " + text)
+    }
+    return universe, address, nil
+  }
+
+  absolute, err := strconv.Atoi(text)
+  if err != nil || absolute < 1 {
+    return 0, 0, errors.New("Invalid address " + text)
+  }
+
+  return (absolute - 1) / dmx.UniverseSize + 1, (absolute - 1) % dmx.UniverseSize + 1, nil
+}
+
+/*
+Parse an MVR matrix, {ux,uy,uz}{vx,vy,vz}{wx,wy,wz}{ox,oy,oz}. The first three
+rows are the rotated axes and the last is the offset in millimetres.
+*/
+func parseMatrix(text string) (gdtf.Matrix, error) {
+  m := gdtf.Identity()
+  text = strings.TrimSpace(text)
+
+  if text == "" {
+    return m, nil
+  }
+
+  rows := strings.Split(strings.TrimSuffix(strings.TrimPrefix(text, "{"), "}"), "}{")
+  if len(rows) != 4 {
+    return m, errors.New("Matrix " + text + " must have 4 rows")
+  }
+
+  for i, row := range rows {
+    values := strings.Split(row, ",")
+    if len(values) != 3 {
+      return m, errors.New("Matrix " + text + " must have 3 columns")
+    }
+
+    for j, value := range values {
+      v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+      if err != nil {
+        return m, errors.New("Matrix " + text + " has an invalid value " + value)
+      }
+
+      if i == 3 {
+        m[j][3] = v / 1000
+      } else {
+        m[j][i] = v
+      }
+    }
+  }
+
+  return m, nil
+}
+
+func formatMatrix(m gdtf.Matrix) string {
+  var buf bytes.Buffer
+
+  for col := 0; col < 4; col++ {
+    scale := 1.0
+    if col == 3 {
+      scale = 1000
+    }
+
+    values := make([]string, 3)
+    for row := 0; row < 3; row++ {
+      values[row] = strconv.FormatFloat(m[row][col] * scale, 'f', -1, 64)
+    }
+    buf.WriteString("{" + strings.Join(values, ",") + "}")
+  }
+
+  return buf.String()
+}
+
+func readFile(file *zip.File) ([]byte, error) {
+  rc, err := file.Open()
+
+  if err != nil {
+    return nil, err
+  }
+
+  defer rc.Close()
+  return io.ReadAll(rc)
+}
+
+func unmarshal(file *zip.File, v interface {}) error {
+  data, err := readFile(file)
+
+  if err != nil {
+    return err
+  }
+
+  return xml.Unmarshal(data, v)
+}
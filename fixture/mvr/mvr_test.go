@@ -0,0 +1,234 @@
+package mvr
+
+import (
+  "archive/zip"
+  "bytes"
+  "math"
+  "os"
+  "strings"
+  "testing"
+  "golx/dmx"
+  "golx/dmx/dmxtest"
+)
+
+const spotSpec = "GoLX Test@Robin Spot.gdtf"
+
+func zipFiles(t *testing.T, files map[string] []byte) []byte {
+  var buf bytes.Buffer
+  w := zip.NewWriter(&buf)
+
+  for name, content := range files {
+    f, err := w.Create(name)
+    if err != nil {
+      t.Log("Error building archive: ", err.Error())
+      t.FailNow()
+    }
+    f.Write(content)
+  }
+
+  if err := w.Close(); err != nil {
+    t.Log("Error building archive: ", err.Error())
+    t.FailNow()
+  }
+
+  return buf.Bytes()
+}
+
+func readTestFile(t *testing.T, path string) []byte {
+  data, err := os.ReadFile(path)
+  if err != nil {
+    t.Log(err.Error())
+    t.FailNow()
+  }
+  return data
+}
+
+// The test scene with the GDTF file of the spots
+func importScene(t *testing.T) (*Rig, []Warning) {
+  spot := zipFiles(t, map[string] []byte{
+    "description.xml": readTestFile(t, "../profile/gdtf/testdata/spot/description.xml"),
+  })
+
+  data := zipFiles(t, map[string] []byte{
+    sceneFile: readTestFile(t, "testdata/GeneralSceneDescription.xml"),
+    spotSpec: spot,
+  })
+
+  rig, warnings, err := Import(bytes.NewReader(data), int64(len(data)))
+  if err != nil {
+    t.Log("Error importing: ", err.Error())
+    t.FailNow()
+  }
+
+  return rig, warnings
+}
+
+func hasWarning(warnings []Warning, text string) bool {
+  for _, w := range warnings {
+    if strings.Contains(w.String(), text) {
+      return true
+    }
+  }
+  return false
+}
+
+func TestImportScene(t *testing.T) {
+  rig, warnings := importScene(t)
+
+  if len(rig.Fixtures) != 4 {
+    t.Log("Imported ", len(rig.Fixtures), " fixtures, expected 4")
+    t.FailNow()
+  }
+
+  if !hasWarning(warnings, "1 Truss objects") || !hasWarning(warnings, "Missing@Hazer") {
+    t.Log("Missing warnings: ", warnings)
+    t.Fail()
+  }
+
+  spot := rig.Fixture("102")
+  if spot == nil || spot.Layer != "Overhead" || len(spot.Groups) != 1 || spot.Groups[0] != "LX1" {
+    t.Log("Spot 2 imported incorrectly: ", spot)
+    t.FailNow()
+  }
+
+  if spot.Universe != 1 || spot.Address != 21 || spot.Type == nil || spot.Mode != "Standard" {
+    t.Log("Spot 2 patched incorrectly: ", spot)
+    t.Fail()
+  }
+
+  // The group's offset is added to the fixture's, and millimetres become metres
+  if x, _, z := spot.Position(); x != 1 || z != 5 {
+    t.Log("Spot 2 is at ", x, z, " expected 1, 5")
+    t.Fail()
+  }
+
+  // The hung fixture's axes are flipped by the scene's matrix
+  if spot.Transform[2][2] != -1 {
+    t.Log("Spot 2 rotation imported incorrectly: ", spot.Transform)
+    t.Fail()
+  }
+
+  floor := rig.Fixture("201")
+  if floor.Universe != 2 || floor.Address != 1 || floor.Type == nil {
+    t.Log("Floor spot imported incorrectly: ", floor)
+    t.Fail()
+  }
+
+  if len(rig.Group("LX1")) != 2 || len(rig.Layer("Floor")) != 2 {
+    t.Log("Groups or layers imported incorrectly")
+    t.Fail()
+  }
+}
+
+func TestPatchScene(t *testing.T) {
+  rig, _ := importScene(t)
+
+  universes := map[int] *dmx.DMXUniverse{1: dmx.NewDMXUniverse(), 2: dmx.NewDMXUniverse()}
+  watch1 := universes[1].Watch()
+  watch2 := universes[2].Watch()
+
+  if err := rig.Patch(universes); err != nil {
+    t.Log("Error patching: ", err.Error())
+    t.FailNow()
+  }
+
+  // The unaddressed hazer has no fixture type and is left unpatched
+  if rig.Fixture("301").Instance() != nil {
+    t.Log("Unaddressed fixture was patched")
+    t.Fail()
+  }
+
+  // Dimmer is channels 7 and 8 of the spot at address 21
+  rig.Fixture("102").Instance().Attribute("Dimmer").SetValue(1)
+
+  if dmxtest.WaitFor(watch1, func(f dmx.DMXFrame) bool { return f[26] == 255 && f[27] == 255 }) == nil {
+    t.Log("Spot 2 dimmer was not patched to 1/27")
+    t.Fail()
+  }
+
+  // Pan defaults to half
+  if dmxtest.WaitFor(watch2, func(f dmx.DMXFrame) bool { return f[0] == 128 }) == nil {
+    t.Log("Floor spot was not patched to universe 2")
+    t.Fail()
+  }
+
+  if err := rig.Patch(map[int] *dmx.DMXUniverse{}); err != nil {
+    t.Log("Patching again failed: ", err.Error())
+    t.Fail()
+  }
+}
+
+func TestExportRoundTrip(t *testing.T) {
+  rig, _ := importScene(t)
+
+  // Move a fixture, as if it had been changed on the console
+  rig.Fixture("101").Transform[0][3] = -2
+
+  var buf bytes.Buffer
+  warnings, err := rig.Export(&buf)
+  if err != nil {
+    t.Log("Error exporting: ", err.Error())
+    t.FailNow()
+  }
+
+  // The hazer's GDTF file wasn't in the imported scene so it can't be exported
+  if len(warnings) != 1 || !hasWarning(warnings, "Missing@Hazer.gdtf: Fixture type is not in the rig") {
+    t.Log("Missing fixture types reported as ", warnings)
+    t.Fail()
+  }
+
+  again, _, err := Import(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+  if err != nil {
+    t.Log("Error importing export: ", err.Error())
+    t.FailNow()
+  }
+
+  if len(again.Fixtures) != 4 {
+    t.Log("Exported ", len(again.Fixtures), " fixtures, expected 4")
+    t.FailNow()
+  }
+
+  for _, f := range rig.Fixtures {
+    g := again.Fixture(f.FixtureID)
+
+    if g == nil || g.UUID != f.UUID || g.Layer != f.Layer || strings.Join(g.Groups, "/") != strings.Join(f.Groups, "/") {
+      t.Log("Fixture ", f, " exported as ", g)
+      t.Fail()
+      continue
+    }
+
+    if g.Universe != f.Universe || g.Address != f.Address || g.Mode != f.Mode {
+      t.Log("Patch of ", f, " exported as ", g)
+      t.Fail()
+    }
+
+    for row := 0; row < 4; row++ {
+      for col := 0; col < 4; col++ {
+        if math.Abs(g.Transform[row][col] - f.Transform[row][col]) > 1e-9 {
+          t.Log("Position of ", f, " exported as ", g.Transform)
+          t.Fail()
+        }
+      }
+    }
+  }
+
+  if again.Type(spotSpec) == nil {
+    t.Log("GDTF file was not included in the export")
+    t.Fail()
+  }
+}
+
+func TestParseAddress(t *testing.T) {
+  for text, expected := range map[string] [2]int{"1": {1, 1}, "512": {1, 512}, "513": {2, 1}, "3.100": {3, 100}} {
+    universe, address, err := parseAddress(text)
+    if err != nil || universe != expected[0] || address != expected[1] {
+      t.Log("Address ", text, " parsed as ", universe, address, err)
+      t.Fail()
+    }
+  }
+
+  if _, _, err := parseAddress("1.600"); err == nil {
+    t.Log("Parsed an address outside the universe")
+    t.Fail()
+  }
+}
@@ -0,0 +1,210 @@
+package profile
+
+import (
+  "errors"
+  "fmt"
+  "sort"
+  "sync"
+  "golx/fixture"
+  "golx/fixture/mixer"
+  "golx/dmx"
+  "golx/dmx/dmxfixture"
+  "golx/patch"
+)
+
+/*
+A fixture built from a profile. Each attribute in the chosen mode becomes an
+Attribute with a level from 0 to 1 and a parameter tagged with the attribute's
+class, ready to patch to the fixture's channels.
+*/
+type Fixture struct {
+  profile *Profile
+  mode *Mode
+  address int
+  attrs map[string] *Attribute
+}
+
+type Attribute struct {
+  fixture *Fixture
+  def AttributeDef
+  param *dmxfixture.DMXMultiParam
+  mixer *mixer.LTPMixer
+
+  input chan dmx.DMXLevel
+  value dmx.DMXLevel
+  valueLock sync.Mutex
+}
+
+/*
+Build a fixture in the named mode starting at address. The fixture isn't
+connected to a universe until Patch is called.
+*/
+func New(p *Profile, mode string, address int) (*Fixture, error) {
+  m, err := p.Mode(mode)
+
+  if err != nil {
+    return nil, err
+  }
+
+  if address < 1 || address + m.Footprint() - 1 > dmx.UniverseSize {
+    return nil, fmt.Errorf("%s in mode %s does not fit at address %d", p.String(), mode, address)
+  }
+
+  f := new(Fixture)
+  f.profile = p
+  f.mode = m
+  f.address = address
+  f.attrs = make(map[string] *Attribute)
+
+  for _, def := range m.Attributes {
+    f.attrs[def.Name] = newAttribute(f, def)
+  }
+
+  return f, nil
+}
+
+func newAttribute(f *Fixture, def AttributeDef) *Attribute {
+  attr := new(Attribute)
+  attr.fixture = f
+  attr.def = def
+  attr.value = dmx.DMXLevel(def.Default)
+
+  attr.param = dmxfixture.NewDMXMultiParam(attr)
+  attr.param.SetClass(def.Class())
+
+  attr.input = make(chan dmx.DMXLevel)
+  attr.mixer, _ = mixer.NewLTPMixer(attr.input, dmx.DMXLevel(def.Default))
+
+  go func() {
+    for level := range attr.input {
+      attr.valueLock.Lock()
+      attr.value = level
+      attr.valueLock.Unlock()
+
+      attr.param.SetValue(level)
+    }
+  }()
+
+  attr.param.SetValue(attr.value)
+
+  return attr
+}
+
+func (f *Fixture) String() string {
+  return fmt.Sprintf("[%s %s at %d]", f.profile.String(), f.mode.Name, f.address)
+}
+
+func (f *Fixture) Profile() *Profile {
+  return f.profile
+}
+
+func (f *Fixture) Mode() *Mode {
+  return f.mode
+}
+
+func (f *Fixture) Address() int {
+  return f.address
+}
+
+func (f *Fixture) Attributes() map[string] fixture.Attribute {
+  attrs := make(map[string] fixture.Attribute)
+  for name, attr := range f.attrs {
+    attrs[name] = attr
+  }
+  return attrs
+}
+
+// The named attribute, or nil if the mode doesn't have it
+func (f *Fixture) Attribute(name string) *Attribute {
+  return f.attrs[name]
+}
+
+// Patch every attribute to its channels in u
+func (f *Fixture) Patch(u *dmx.DMXUniverse) error {
+  names := make([]string, 0, len(f.attrs))
+  for name := range f.attrs {
+    names = append(names, name)
+  }
+  sort.Strings(names)
+
+  for _, name := range names {
+    attr := f.attrs[name]
+
+    first, order, err := attr.def.layout()
+    if err != nil {
+      return err
+    }
+
+    channel, err := u.GetMultiChannel(f.address + first - 1, len(attr.def.Channels), order)
+    if err != nil {
+      return errors.New("Attribute " + name + ": " + err.Error())
+    }
+
+    if err := patch.Patch(attr.param, channel); err != nil {
+      return errors.New("Attribute " + name + ": " + err.Error())
+    }
+  }
+
+  return nil
+}
+
+func (attr *Attribute) Fixture() fixture.Fixture {
+  return attr.fixture
+}
+
+func (attr *Attribute) Name() string {
+  return attr.def.Name
+}
+
+func (attr *Attribute) Definition() AttributeDef {
+  return attr.def
+}
+
+func (attr *Attribute) Class() dmx.ParamClass {
+  return attr.def.Class()
+}
+
+func (attr *Attribute) Parameters() map[string] fixture.Parameter {
+  return map[string] fixture.Parameter{attr.def.Name: attr.param}
+}
+
+func (attr *Attribute) DMXOut() *dmxfixture.DMXMultiParam {
+  return attr.param
+}
+
+/*
+Get a new input for the attribute. Inputs are mixed latest takes precedence and
+the attribute returns to its default when every input has been closed.
+*/
+func (attr *Attribute) Input() chan dmx.DMXLevel {
+  c := make(chan dmx.DMXLevel)
+  attr.mixer.AddInput(c)
+  return c
+}
+
+func (attr *Attribute) SetValue(level dmx.DMXLevel) {
+  attr.input <- level
+}
+
+func (attr *Attribute) Value() dmx.DMXLevel {
+  attr.valueLock.Lock()
+  defer attr.valueLock.Unlock()
+  return attr.value
+}
+
+// Set the attribute to the middle of one of its named ranges
+func (attr *Attribute) SetRange(name string) error {
+  r, err := attr.def.Range(name)
+
+  if err != nil {
+    return err
+  }
+
+  // Ranges are values of the coarse channel
+  width := len(attr.def.Channels)
+  max := float64(uint64(1) << uint(8 * width) - 1)
+  mid := float64(r.From + r.To) / 2 * float64(uint64(1) << uint(8 * (width - 1)))
+
+  attr.SetValue(dmx.DMXLevel(mid / max))
+  return nil
+}
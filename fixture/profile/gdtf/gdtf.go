@@ -0,0 +1,835 @@
+/*
+GDTF import
+
+Reads General Device Type Format fixture types, the zip archives that
+manufacturers ship with a description.xml, without needing any network access.
+Each DMX mode becomes a profile mode with one attribute per DMX channel. Channel
+functions and channel sets become named ranges, with wheel slots named from the
+fixture's wheels.
+
+The rest of the description is kept alongside the profile: the geometry tree
+and models for visualisation and position solving, the wheels, the physical
+description and the physical range of every channel function.
+
+Modes spread over several DMX breaks, virtual channels and channels of
+referenced geometries (multi-instance fixtures) aren't supported. They are
+left out and reported as warnings; an archive that isn't a GDTF file or uses a
+newer major version of the format is an error.
+*/
+package gdtf
+
+import (
+  "archive/zip"
+  "encoding/xml"
+  "errors"
+  "fmt"
+  "io"
+  "os"
+  "sort"
+  "strconv"
+  "strings"
+  "golx/fixture/profile"
+)
+
+// Something in a fixture type that couldn't be imported exactly
+type Warning struct {
+  Mode string
+  Message string
+}
+
+func (w Warning) String() string {
+  if w.Mode == "" {
+    return w.Message
+  }
+  return w.Mode + ": " + w.Message
+}
+
+// A colour in CIE 1931 xyY
+type CIE struct {
+  X float64
+  Y float64
+  Luminance float64
+}
+
+type Slot struct {
+  Name string
+  Colour CIE
+
+  // The name of the image of a gobo in the archive's wheels folder
+  Media string
+}
+
+type Wheel struct {
+  Name string
+  Slots []Slot
+}
+
+type Emitter struct {
+  Name string
+  Colour CIE
+
+  // In nanometres
+  DominantWaveLength float64
+}
+
+type Physical struct {
+  Emitters []Emitter
+
+  // In kilograms
+  Weight float64
+
+  // In metres
+  LegHeight float64
+
+  // In degrees Celsius
+  MinTemperature float64
+  MaxTemperature float64
+}
+
+/*
+A span of a channel that controls one attribute. From is the DMX value the
+function starts at, at the full width of the channel. The function ends where
+the next one starts.
+*/
+type Function struct {
+  Name string
+  Attribute string
+  From int
+
+  // The values of the attribute at the start and end of the function
+  PhysicalFrom float64
+  PhysicalTo float64
+
+  // The wheel the function selects slots from, if any
+  Wheel string
+}
+
+type Channel struct {
+  // The name of the attribute in the profile
+  Attribute string
+
+  // The geometry the channel controls
+  Geometry string
+
+  Functions []Function
+}
+
+type Mode struct {
+  Name string
+
+  // The root of the geometry tree used by the mode
+  Geometry string
+
+  Channels []Channel
+}
+
+type FixtureType struct {
+  Profile *profile.Profile
+
+  ID string
+  LongName string
+  Description string
+
+  Modes []Mode
+
+  // The top level geometries. Those only used by geometry references are included
+  Geometries []*Geometry
+
+  Models map[string] Model
+  Wheels []Wheel
+  Physical Physical
+}
+
+type xmlGDTF struct {
+  XMLName xml.Name `xml:"GDTF"`
+  DataVersion string `xml:"DataVersion,attr"`
+  FixtureType *xmlFixtureType `xml:"FixtureType"`
+}
+
+type xmlFixtureType struct {
+  Name string `xml:"Name,attr"`
+  LongName string `xml:"LongName,attr"`
+  Manufacturer string `xml:"Manufacturer,attr"`
+  Description string `xml:"Description,attr"`
+  FixtureTypeID string `xml:"FixtureTypeID,attr"`
+
+  Attributes []xmlAttribute `xml:"AttributeDefinitions>Attributes>Attribute"`
+  Wheels []xmlWheel `xml:"Wheels>Wheel"`
+  Emitters []xmlEmitter `xml:"PhysicalDescriptions>Emitters>Emitter"`
+  Weight xmlValue `xml:"PhysicalDescriptions>Properties>Weight"`
+  LegHeight xmlValue `xml:"PhysicalDescriptions>Properties>LegHeight"`
+  Temperature xmlTemperature `xml:"PhysicalDescriptions>Properties>OperatingTemperature"`
+  Models []xmlModel `xml:"Models>Model"`
+  Geometries xmlGeometries `xml:"Geometries"`
+  Modes []xmlMode `xml:"DMXModes>DMXMode"`
+}
+
+type xmlAttribute struct {
+  Name string `xml:"Name,attr"`
+  Feature string `xml:"Feature,attr"`
+}
+
+type xmlWheel struct {
+  Name string `xml:"Name,attr"`
+  Slots []xmlSlot `xml:"Slot"`
+}
+
+type xmlSlot struct {
+  Name string `xml:"Name,attr"`
+  Color string `xml:"Color,attr"`
+  MediaFileName string `xml:"MediaFileName,attr"`
+}
+
+type xmlEmitter struct {
+  Name string `xml:"Name,attr"`
+  Color string `xml:"Color,attr"`
+  DominantWaveLength float64 `xml:"DominantWaveLength,attr"`
+}
+
+type xmlValue struct {
+  Value float64 `xml:"Value,attr"`
+}
+
+type xmlTemperature struct {
+  Low float64 `xml:"Low,attr"`
+  High float64 `xml:"High,attr"`
+}
+
+type xmlModel struct {
+  Name string `xml:"Name,attr"`
+  Length float64 `xml:"Length,attr"`
+  Width float64 `xml:"Width,attr"`
+  Height float64 `xml:"Height,attr"`
+  PrimitiveType string `xml:"PrimitiveType,attr"`
+  File string `xml:"File,attr"`
+}
+
+type xmlGeometries struct {
+  Items []xmlGeometry `xml:",any"`
+}
+
+// Any kind of geometry, with the attributes of all of them
+type xmlGeometry struct {
+  XMLName xml.Name
+  Name string `xml:"Name,attr"`
+  Model string `xml:"Model,attr"`
+  Position string `xml:"Position,attr"`
+  Geometry string `xml:"Geometry,attr"`
+
+  LampType string `xml:"LampType,attr"`
+  BeamType string `xml:"BeamType,attr"`
+  BeamAngle float64 `xml:"BeamAngle,attr"`
+  FieldAngle float64 `xml:"FieldAngle,attr"`
+  BeamRadius float64 `xml:"BeamRadius,attr"`
+  LuminousFlux float64 `xml:"LuminousFlux,attr"`
+  ColorTemperature float64 `xml:"ColorTemperature,attr"`
+  PowerConsumption float64 `xml:"PowerConsumption,attr"`
+
+  Children []xmlGeometry `xml:",any"`
+}
+
+type xmlMode struct {
+  Name string `xml:"Name,attr"`
+  Geometry string `xml:"Geometry,attr"`
+  Channels []xmlChannel `xml:"DMXChannels>DMXChannel"`
+}
+
+type xmlChannel struct {
+  DMXBreak string `xml:"DMXBreak,attr"`
+  Offset string `xml:"Offset,attr"`
+  Default string `xml:"Default,attr"`
+  Geometry string `xml:"Geometry,attr"`
+  LogicalChannels []xmlLogicalChannel `xml:"LogicalChannel"`
+}
+
+type xmlLogicalChannel struct {
+  Attribute string `xml:"Attribute,attr"`
+  Functions []xmlFunction `xml:"ChannelFunction"`
+}
+
+type xmlFunction struct {
+  Name string `xml:"Name,attr"`
+  Attribute string `xml:"Attribute,attr"`
+  DMXFrom string `xml:"DMXFrom,attr"`
+  Default string `xml:"Default,attr"`
+  PhysicalFrom float64 `xml:"PhysicalFrom,attr"`
+  PhysicalTo float64 `xml:"PhysicalTo,attr"`
+  Wheel string `xml:"Wheel,attr"`
+  Sets []xmlSet `xml:"ChannelSet"`
+}
+
+type xmlSet struct {
+  Name string `xml:"Name,attr"`
+  DMXFrom string `xml:"DMXFrom,attr"`
+  WheelSlotIndex int `xml:"WheelSlotIndex,attr"`
+}
+
+// GoLX attribute types for GDTF feature groups
+var featureGroups = map[string] string{
+  "Dimmer": "intensity",
+  "Position": "position",
+  "Color": "colour",
+  "Gobo": "beam",
+  "Beam": "beam",
+  "Focus": "beam",
+  "Shapers": "beam",
+  "Control": "control",
+  "Video": "control",
+}
+
+// Read a .gdtf file
+func ImportFile(path string) (*FixtureType, []Warning, error) {
+  f, err := os.Open(path)
+
+  if err != nil {
+    return nil, nil, err
+  }
+
+  defer f.Close()
+
+  info, err := f.Stat()
+
+  if err != nil {
+    return nil, nil, err
+  }
+
+  ft, warnings, err := Import(f, info.Size())
+
+  if err != nil {
+    return nil, warnings, errors.New(path + ": " + err.Error())
+  }
+
+  return ft, warnings, nil
+}
+
+// Read a GDTF archive of size bytes
+func Import(r io.ReaderAt, size int64) (*FixtureType, []Warning, error) {
+  archive, err := zip.NewReader(r, size)
+
+  if err != nil {
+    return nil, nil, errors.New("Not a GDTF archive: " + err.Error())
+  }
+
+  for _, file := range archive.File {
+    if file.Name != "description.xml" {
+      continue
+    }
+
+    description, err := file.Open()
+
+    if err != nil {
+      return nil, nil, err
+    }
+
+    defer description.Close()
+
+    return ImportDescription(description)
+  }
+
+  return nil, nil, errors.New("Archive has no description.xml")
+}
+
+// Read the description.xml from a GDTF archive
+func ImportDescription(r io.Reader) (*FixtureType, []Warning, error) {
+  var doc xmlGDTF
+
+  if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+    return nil, nil, errors.New("Invalid GDTF description: " + err.Error())
+  }
+
+  major := strings.SplitN(doc.DataVersion, ".", 2)[0]
+  if major != "1" {
+    return nil, nil, errors.New("GDTF version " + doc.DataVersion + " is not supported")
+  }
+
+  if doc.FixtureType == nil {
+    return nil, nil, errors.New("Description has no fixture type")
+  }
+
+  x := doc.FixtureType
+
+  if x.Name == "" {
+    return nil, nil, errors.New("Fixture type has no name")
+  }
+
+  imp := &importer{
+    fixture: x,
+    features: make(map[string] string),
+    referenced: make(map[string] bool),
+    warnings: make([]Warning, 0),
+  }
+
+  for _, attr := range x.Attributes {
+    imp.features[attr.Name] = attr.Feature
+  }
+
+  ft := &FixtureType{
+    ID: x.FixtureTypeID,
+    LongName: x.LongName,
+    Description: x.Description,
+    Models: make(map[string] Model),
+  }
+
+  for _, m := range x.Models {
+    ft.Models[m.Name] = Model{m.Name, m.Length, m.Width, m.Height, m.PrimitiveType, m.File}
+  }
+
+  for _, w := range x.Wheels {
+    wheel := Wheel{Name: w.Name}
+    for _, s := range w.Slots {
+      wheel.Slots = append(wheel.Slots, Slot{s.Name, parseCIE(s.Color), s.MediaFileName})
+    }
+    ft.Wheels = append(ft.Wheels, wheel)
+  }
+
+  ft.Physical = Physical{
+    Weight: x.Weight.Value,
+    LegHeight: x.LegHeight.Value,
+    MinTemperature: x.Temperature.Low,
+    MaxTemperature: x.Temperature.High,
+  }
+  for _, e := range x.Emitters {
+    ft.Physical.Emitters = append(ft.Physical.Emitters, Emitter{e.Name, parseCIE(e.Color), e.DominantWaveLength})
+  }
+
+  for _, item := range x.Geometries.Items {
+    g, err := imp.geometry(item, nil)
+    if err != nil {
+      return nil, imp.warnings, err
+    }
+    ft.Geometries = append(ft.Geometries, g)
+  }
+  imp.ft = ft
+
+  p := new(profile.Profile)
+  p.Manufacturer = x.Manufacturer
+  p.Model = x.Name
+
+  for _, mode := range x.Modes {
+    pm, m, ok, err := imp.mode(mode)
+
+    if err != nil {
+      return nil, imp.warnings, errors.New("Mode " + mode.Name + ": " + err.Error())
+    }
+
+    if ok {
+      p.Modes = append(p.Modes, pm)
+      ft.Modes = append(ft.Modes, m)
+    }
+  }
+
+  if err := p.Validate(); err != nil {
+    return nil, imp.warnings, err
+  }
+
+  ft.Profile = p
+
+  return ft, imp.warnings, nil
+}
+
+func (ft *FixtureType) String() string {
+  return ft.Profile.String()
+}
+
+func (ft *FixtureType) Mode(name string) (*Mode, error) {
+  for i := range ft.Modes {
+    if ft.Modes[i].Name == name {
+      return &ft.Modes[i], nil
+    }
+  }
+
+  return nil, errors.New("Fixture type " + ft.String() + " has no mode " + name)
+}
+
+func (ft *FixtureType) Wheel(name string) (*Wheel, error) {
+  for i := range ft.Wheels {
+    if ft.Wheels[i].Name == name {
+      return &ft.Wheels[i], nil
+    }
+  }
+
+  return nil, errors.New("Fixture type " + ft.String() + " has no wheel " + name)
+}
+
+// The geometry with the given name in any of the geometry trees, or nil
+func (ft *FixtureType) Geometry(name string) *Geometry {
+  for _, g := range ft.Geometries {
+    if found := g.Find(name); found != nil {
+      return found
+    }
+  }
+
+  return nil
+}
+
+// The channel of a mode that drives a profile attribute, or nil
+func (m *Mode) Channel(attribute string) *Channel {
+  for i := range m.Channels {
+    if m.Channels[i].Attribute == attribute {
+      return &m.Channels[i]
+    }
+  }
+
+  return nil
+}
+
+type importer struct {
+  fixture *xmlFixtureType
+  ft *FixtureType
+
+  // Feature of each attribute, e.g. Position.PanTilt
+  features map[string] string
+
+  // Geometries that are instantiated by geometry references
+  referenced map[string] bool
+
+  warnings []Warning
+}
+
+func (imp *importer) warn(mode, format string, args ...interface {}) {
+  imp.warnings = append(imp.warnings, Warning{mode, fmt.Sprintf(format, args...)})
+}
+
+func (imp *importer) geometry(x xmlGeometry, parent *Geometry) (*Geometry, error) {
+  position, err := parseMatrix(x.Position)
+
+  if err != nil {
+    return nil, errors.New("Geometry " + x.Name + ": " + err.Error())
+  }
+
+  g := &Geometry{
+    Name: x.Name,
+    Type: x.XMLName.Local,
+    Model: x.Model,
+    Position: position,
+    Parent: parent,
+  }
+
+  switch g.Type {
+  case "Beam":
+    g.Beam = &Beam{
+      LampType: x.LampType,
+      BeamType: x.BeamType,
+      BeamAngle: x.BeamAngle,
+      FieldAngle: x.FieldAngle,
+      BeamRadius: x.BeamRadius,
+      LuminousFlux: x.LuminousFlux,
+      ColourTemperature: x.ColorTemperature,
+      PowerConsumption: x.PowerConsumption,
+    }
+  case "GeometryReference":
+    g.Reference = x.Geometry
+    imp.referenced[x.Geometry] = true
+  }
+
+  for _, child := range x.Children {
+    // Breaks of a geometry reference aren't geometries
+    if child.XMLName.Local == "Break" {
+      continue
+    }
+
+    c, err := imp.geometry(child, g)
+    if err != nil {
+      return nil, err
+    }
+    g.Children = append(g.Children, c)
+  }
+
+  return g, nil
+}
+
+/*
+Convert a DMX mode. Returns false if the mode can't be used, in which case a
+warning has been given.
+*/
+func (imp *importer) mode(x xmlMode) (profile.Mode, Mode, bool, error) {
+  pm := profile.Mode{Name: x.Name}
+  m := Mode{Name: x.Name, Geometry: x.Geometry}
+
+  if x.Geometry != "" && imp.ft.Geometry(x.Geometry) == nil {
+    return pm, m, false, errors.New("Unknown geometry " + x.Geometry)
+  }
+
+  used := make([]xmlChannel, 0, len(x.Channels))
+
+  for _, channel := range x.Channels {
+    if channel.DMXBreak != "" && channel.DMXBreak != "1" {
+      imp.warn(x.Name, "Modes using more than one DMX break are not supported, mode was left out")
+      return pm, m, false, nil
+    }
+
+    if len(channel.LogicalChannels) == 0 {
+      return pm, m, false, errors.New("Channel has no logical channel")
+    }
+
+    attr := channel.LogicalChannels[0].Attribute
+
+    if strings.TrimSpace(channel.Offset) == "" || strings.EqualFold(channel.Offset, "None") {
+      imp.warn(x.Name, "Virtual channel %s has no DMX channels and was left out", attr)
+      continue
+    }
+
+    if imp.referenced[channel.Geometry] {
+      imp.warn(x.Name, "Channel %s of referenced geometry %s is not supported and was left out", attr, channel.Geometry)
+      continue
+    }
+
+    if len(channel.LogicalChannels) > 1 {
+      imp.warn(x.Name, "Only the first logical channel of %s was imported", attr)
+    }
+
+    used = append(used, channel)
+  }
+
+  // Attributes used by more than one channel are told apart by their geometry
+  counts := make(map[string] int)
+  for _, channel := range used {
+    counts[channel.LogicalChannels[0].Attribute]++
+  }
+
+  for _, channel := range used {
+    attr := channel.LogicalChannels[0].Attribute
+    name := attr
+    if counts[attr] > 1 {
+      name = channel.Geometry + " " + attr
+    }
+
+    def, c, err := imp.channel(x.Name, name, channel)
+    if err != nil {
+      return pm, m, false, errors.New("Channel " + name + ": " + err.Error())
+    }
+
+    pm.Attributes = append(pm.Attributes, def)
+    m.Channels = append(m.Channels, c)
+  }
+
+  if len(pm.Attributes) == 0 {
+    imp.warn(x.Name, "Mode has no DMX channels and was left out")
+    return pm, m, false, nil
+  }
+
+  return pm, m, true, nil
+}
+
+func (imp *importer) channel(mode, name string, x xmlChannel) (profile.AttributeDef, Channel, error) {
+  logical := x.LogicalChannels[0]
+  def := profile.AttributeDef{Name: name}
+  c := Channel{Attribute: name, Geometry: x.Geometry}
+
+  for _, offset := range strings.Split(x.Offset, ",") {
+    number, err := strconv.Atoi(strings.TrimSpace(offset))
+    if err != nil {
+      return def, c, errors.New("Invalid offset " + x.Offset)
+    }
+    def.Channels = append(def.Channels, number)
+  }
+
+  for i := 1; i < len(def.Channels); i++ {
+    step := def.Channels[i] - def.Channels[i - 1]
+    if (step != 1 && step != -1) || (i > 1 && step != def.Channels[1] - def.Channels[0]) {
+      imp.warn(mode, "Channels of %s are not consecutive, imported as 8 bit", name)
+      def.Channels = def.Channels[:1]
+      break
+    }
+  }
+
+  width := len(def.Channels)
+
+  def.Type = imp.attributeType(mode, logical.Attribute)
+
+  if len(logical.Functions) == 0 {
+    return def, c, errors.New("No channel functions")
+  }
+
+  // The default of the channel itself is only in newer versions of the format
+  defaultValue := x.Default
+  if defaultValue == "" {
+    defaultValue = logical.Functions[0].Default
+  }
+
+  if defaultValue != "" {
+    value, bytes, err := parseDMX(defaultValue)
+    if err != nil {
+      return def, c, err
+    }
+    def.Default = float64(value) / float64(uint64(1) << uint(8 * bytes) - 1)
+    if def.Default > 1 {
+      def.Default = 1
+    }
+  }
+
+  points := make([]point, 0)
+
+  for _, f := range logical.Functions {
+    from, bytes, err := parseDMX(f.DMXFrom)
+    if err != nil {
+      return def, c, err
+    }
+
+    attr := f.Attribute
+    if attr == "" {
+      attr = logical.Attribute
+    }
+
+    c.Functions = append(c.Functions, Function{
+      Name: f.Name,
+      Attribute: attr,
+      From: scaleDMX(from, bytes, width),
+      PhysicalFrom: f.PhysicalFrom,
+      PhysicalTo: f.PhysicalTo,
+      Wheel: f.Wheel,
+    })
+
+    sets := 0
+    for _, set := range f.Sets {
+      setName := imp.setName(f, set)
+      if setName == "" {
+        continue
+      }
+
+      value, bytes, err := parseDMX(set.DMXFrom)
+      if err != nil {
+        return def, c, err
+      }
+      points = append(points, point{scaleDMX(value, bytes, 1), setName})
+      sets++
+    }
+
+    // A channel with a single function doesn't need a range for it
+    if sets == 0 && len(logical.Functions) > 1 {
+      points = append(points, point{scaleDMX(from, bytes, 1), f.Name})
+    }
+  }
+
+  def.Ranges = ranges(points)
+
+  return def, c, nil
+}
+
+// The GoLX type of a GDTF attribute, from its feature group
+func (imp *importer) attributeType(mode, attr string) string {
+  feature, known := imp.features[attr]
+
+  if !known {
+    imp.warn(mode, "Attribute %s is not defined, imported as control", attr)
+    return "control"
+  }
+
+  group := strings.SplitN(feature, ".", 2)[0]
+  kind, known := featureGroups[group]
+
+  if !known {
+    imp.warn(mode, "Feature group %s of %s is not supported, imported as control", group, attr)
+    return "control"
+  }
+
+  return kind
+}
+
+// A channel set's name, or the name of the wheel slot it selects
+func (imp *importer) setName(f xmlFunction, set xmlSet) string {
+  if set.Name != "" {
+    return set.Name
+  }
+
+  if f.Wheel == "" || set.WheelSlotIndex < 1 {
+    return ""
+  }
+
+  for _, wheel := range imp.fixture.Wheels {
+    if wheel.Name == f.Wheel && set.WheelSlotIndex <= len(wheel.Slots) {
+      return wheel.Slots[set.WheelSlotIndex - 1].Name
+    }
+  }
+
+  return ""
+}
+
+// The start of a named range on the coarse channel
+type point struct {
+  from int
+  name string
+}
+
+// Named ranges that each end where the next one starts
+func ranges(points []point) []profile.Range {
+  sort.SliceStable(points, func(i, j int) bool {
+    return points[i].from < points[j].from
+  })
+
+  result := make([]profile.Range, 0, len(points))
+
+  for i, p := range points {
+    // Functions and sets that start together are the same range
+    if i > 0 && points[i - 1].from == p.from {
+      continue
+    }
+
+    to := 255
+    for _, next := range points[i + 1:] {
+      if next.from > p.from {
+        to = next.from - 1
+        break
+      }
+    }
+
+    result = append(result, profile.Range{From: p.from, To: to, Name: p.name})
+  }
+
+  if len(result) == 0 {
+    return nil
+  }
+
+  return result
+}
+
+/*
+Parse a DMX value in the GDTF format, value/bytes, e.g. 128/1 or 32768/2. A
+value without a byte count is 8 bit.
+*/
+func parseDMX(text string) (uint64, int, error) {
+  parts := strings.SplitN(text, "/", 2)
+
+  value, err := strconv.ParseUint(strings.TrimSpace(parts[0]), 10, 64)
+  if err != nil {
+    return 0, 0, errors.New("Invalid DMX value " + text)
+  }
+
+  bytes := 1
+  if len(parts) == 2 {
+    bytes, err = strconv.Atoi(strings.TrimSpace(parts[1]))
+    if err != nil || bytes < 1 || bytes > 4 {
+      return 0, 0, errors.New("Invalid DMX value " + text)
+    }
+  }
+
+  return value, bytes, nil
+}
+
+// Convert a value of bytes bytes to one of width bytes
+func scaleDMX(value uint64, bytes, width int) int {
+  if bytes > width {
+    return int(value >> uint(8 * (bytes - width)))
+  }
+  return int(value << uint(8 * (width - bytes)))
+}
+
+// Parse a colour in the GDTF format, x,y,Y. Invalid colours are black
+func parseCIE(text string) CIE {
+  parts := strings.Split(text, ",")
+
+  if len(parts) != 3 {
+    return CIE{}
+  }
+
+  values := make([]float64, 3)
+  for i, part := range parts {
+    v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+    if err != nil {
+      return CIE{}
+    }
+    values[i] = v
+  }
+
+  return CIE{values[0], values[1], values[2]}
+}
@@ -0,0 +1,202 @@
+package gdtf
+
+import (
+  "archive/zip"
+  "bytes"
+  "math"
+  "os"
+  "strings"
+  "testing"
+)
+
+// Build a GDTF archive in memory containing the given files
+func archive(t *testing.T, files map[string] string) *bytes.Reader {
+  var buf bytes.Buffer
+  w := zip.NewWriter(&buf)
+
+  for name, content := range files {
+    f, err := w.Create(name)
+    if err != nil {
+      t.Log("Error building archive: ", err.Error())
+      t.FailNow()
+    }
+    f.Write([]byte(content))
+  }
+
+  if err := w.Close(); err != nil {
+    t.Log("Error building archive: ", err.Error())
+    t.FailNow()
+  }
+
+  return bytes.NewReader(buf.Bytes())
+}
+
+func importSpot(t *testing.T) (*FixtureType, []Warning) {
+  description, err := os.ReadFile("testdata/spot/description.xml")
+  if err != nil {
+    t.Log(err.Error())
+    t.FailNow()
+  }
+
+  r := archive(t, map[string] string{
+    "description.xml": string(description),
+    "wheels/dots.png": "",
+  })
+
+  ft, warnings, err := Import(r, r.Size())
+  if err != nil {
+    t.Log("Error importing: ", err.Error())
+    t.FailNow()
+  }
+
+  return ft, warnings
+}
+
+func hasWarning(warnings []Warning, text string) bool {
+  for _, w := range warnings {
+    if strings.Contains(w.String(), text) {
+      return true
+    }
+  }
+  return false
+}
+
+func TestImportModes(t *testing.T) {
+  ft, warnings := importSpot(t)
+
+  if ft.Profile.Manufacturer != "GoLX Test" || ft.Profile.Model != "Robin Spot" {
+    t.Log("Fixture type imported as ", ft.Profile)
+    t.Fail()
+  }
+
+  // The two break mode is left out
+  if len(ft.Profile.Modes) != 1 || !hasWarning(warnings, "more than one DMX break") {
+    t.Log("Modes imported incorrectly: ", ft.Profile.Modes, warnings)
+    t.Fail()
+  }
+
+  if !hasWarning(warnings, "Virtual channel Control1") {
+    t.Log("No warning for the virtual channel: ", warnings)
+    t.Fail()
+  }
+
+  mode, err := ft.Profile.Mode("Standard")
+  if err != nil {
+    t.Log(err.Error())
+    t.FailNow()
+  }
+
+  if mode.Footprint() != 8 || len(mode.Attributes) != 5 {
+    t.Log("Standard mode imported incorrectly: ", mode)
+    t.FailNow()
+  }
+
+  pan := mode.Attributes[0]
+  if pan.Name != "Pan" || pan.Type != "position" || len(pan.Channels) != 2 || math.Abs(pan.Default - 0.5) > 0.01 {
+    t.Log("Pan imported incorrectly: ", pan)
+    t.Fail()
+  }
+
+  dimmer := mode.Attributes[4]
+  if dimmer.Name != "Dimmer" || dimmer.Type != "intensity" || dimmer.Channels[0] != 7 || dimmer.Default != 0 {
+    t.Log("Dimmer imported incorrectly: ", dimmer)
+    t.Fail()
+  }
+
+  // Channel sets become ranges, named from the wheel when they have no name
+  colour := mode.Attributes[2]
+  if r, err := colour.Range("Red"); colour.Type != "colour" || err != nil || r.From != 10 || r.To != 19 {
+    t.Log("Colour wheel ranges imported incorrectly: ", colour.Ranges)
+    t.Fail()
+  }
+
+  gobo := mode.Attributes[3]
+  if r, err := gobo.Range("Breakup"); gobo.Type != "beam" || err != nil || r.From != 20 || r.To != 255 {
+    t.Log("Gobo wheel ranges imported incorrectly: ", gobo.Ranges)
+    t.Fail()
+  }
+
+  // Physical ranges of channel functions are kept
+  m, _ := ft.Mode("Standard")
+  tilt := m.Channel("Tilt")
+  if tilt == nil || tilt.Geometry != "Head" || tilt.Functions[0].PhysicalFrom != -135 || tilt.Functions[0].PhysicalTo != 135 {
+    t.Log("Tilt channel functions imported incorrectly: ", tilt)
+    t.Fail()
+  }
+}
+
+func TestImportGeometry(t *testing.T) {
+  ft, _ := importSpot(t)
+
+  beam := ft.Geometry("Beam")
+  if beam == nil || beam.Beam == nil || beam.Beam.BeamAngle != 38 || beam.Parent.Name != "Head" {
+    t.Log("Beam geometry imported incorrectly: ", beam)
+    t.FailNow()
+  }
+
+  // Offsets accumulate down the tree
+  _, _, z := beam.Transform().Translation()
+  if math.Abs(z - 0.27) > 1e-9 {
+    t.Log("Beam is at height ", z, " expected 0.27")
+    t.Fail()
+  }
+
+  if ft.Models["Head"].Height != 0.3 {
+    t.Log("Models imported incorrectly: ", ft.Models)
+    t.Fail()
+  }
+
+  wheel, err := ft.Wheel("Color Wheel")
+  if err != nil || len(wheel.Slots) != 3 || wheel.Slots[1].Colour.X != 0.64 {
+    t.Log("Colour wheel imported incorrectly: ", wheel, err)
+    t.Fail()
+  }
+
+  if ft.Physical.Weight != 22.5 || len(ft.Physical.Emitters) != 1 {
+    t.Log("Physical description imported incorrectly: ", ft.Physical)
+    t.Fail()
+  }
+}
+
+func TestImportErrors(t *testing.T) {
+  notZip := strings.NewReader("not a zip")
+  if _, _, err := Import(notZip, notZip.Size()); err == nil {
+    t.Log("Imported something that isn't an archive")
+    t.Fail()
+  }
+
+  empty := archive(t, map[string] string{"thumbnail.png": ""})
+  if _, _, err := Import(empty, empty.Size()); err == nil || !strings.Contains(err.Error(), "description.xml") {
+    t.Log("Expected a missing description error, got ", err)
+    t.Fail()
+  }
+
+  future := archive(t, map[string] string{"description.xml": `<GDTF DataVersion="2.0"><FixtureType Name="X"/></GDTF>`})
+  if _, _, err := Import(future, future.Size()); err == nil || !strings.Contains(err.Error(), "2.0") {
+    t.Log("Expected an unsupported version error, got ", err)
+    t.Fail()
+  }
+
+  if _, _, err := ImportFile("testdata/missing.gdtf"); err == nil {
+    t.Log("Imported a missing file")
+    t.Fail()
+  }
+}
+
+func TestParseMatrix(t *testing.T) {
+  m, err := parseMatrix("{1,0,0,0.5}{0,1,0,0}{0,0,1,2}{0,0,0,1}")
+  if err != nil {
+    t.Log(err.Error())
+    t.FailNow()
+  }
+
+  if x, _, z := m.Translation(); x != 0.5 || z != 2 {
+    t.Log("Matrix parsed incorrectly: ", m)
+    t.Fail()
+  }
+
+  if _, err := parseMatrix("{1,0}{0,1,0}"); err == nil {
+    t.Log("Parsed an invalid matrix")
+    t.Fail()
+  }
+}
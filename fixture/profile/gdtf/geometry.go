@@ -0,0 +1,163 @@
+package gdtf
+
+import (
+  "errors"
+  "fmt"
+  "strconv"
+  "strings"
+)
+
+/*
+A 4x4 transform in row major order. Translations are in the last column, in
+metres.
+*/
+type Matrix [4][4]float64
+
+func Identity() Matrix {
+  return Matrix{{1, 0, 0, 0}, {0, 1, 0, 0}, {0, 0, 1, 0}, {0, 0, 0, 1}}
+}
+
+// Apply b and then a
+func (a Matrix) Mul(b Matrix) Matrix {
+  var m Matrix
+
+  for row := 0; row < 4; row++ {
+    for col := 0; col < 4; col++ {
+      for i := 0; i < 4; i++ {
+        m[row][col] += a[row][i] * b[i][col]
+      }
+    }
+  }
+
+  return m
+}
+
+func (m Matrix) Translation() (x, y, z float64) {
+  return m[0][3], m[1][3], m[2][3]
+}
+
+// Parse a matrix in the GDTF format, {1,0,0,0}{0,1,0,0}{0,0,1,0}{0,0,0,1}
+func parseMatrix(text string) (Matrix, error) {
+  m := Identity()
+  text = strings.TrimSpace(text)
+
+  if text == "" {
+    return m, nil
+  }
+
+  rows := strings.Split(strings.TrimSuffix(strings.TrimPrefix(text, "{"), "}"), "}{")
+
+  // Rotation only matrices leave out the last row and column
+  if len(rows) != 3 && len(rows) != 4 {
+    return m, errors.New("Matrix " + text + " must have 3 or 4 rows")
+  }
+
+  for i, row := range rows {
+    values := strings.Split(row, ",")
+
+    if len(values) != len(rows) {
+      return m, errors.New("Matrix " + text + " is not square")
+    }
+
+    for j, value := range values {
+      v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+      if err != nil {
+        return m, errors.New("Matrix " + text + " has an invalid value " + value)
+      }
+      m[i][j] = v
+    }
+  }
+
+  return m, nil
+}
+
+// The physical size and basic shape of part of a fixture
+type Model struct {
+  Name string
+
+  // In metres
+  Length float64
+  Width float64
+  Height float64
+
+  // Undefined when the model is a mesh from File
+  PrimitiveType string
+  File string
+}
+
+type Beam struct {
+  LampType string
+  BeamType string
+
+  // In degrees
+  BeamAngle float64
+  FieldAngle float64
+
+  // In metres
+  BeamRadius float64
+
+  LuminousFlux float64
+  ColourTemperature float64
+  PowerConsumption float64
+}
+
+/*
+A part of a fixture. Geometries form a tree from the base of the fixture, each
+placed relative to its parent. Axis geometries are moved by the attributes of
+the channels that refer to them, e.g. pan turns the yoke.
+*/
+type Geometry struct {
+  Name string
+
+  // The GDTF element, e.g. Geometry, Axis or Beam
+  Type string
+
+  Model string
+  Position Matrix
+
+  // Set for Beam geometries
+  Beam *Beam
+
+  // For GeometryReference, the name of the geometry it is an instance of
+  Reference string
+
+  Parent *Geometry
+  Children []*Geometry
+}
+
+func (g *Geometry) String() string {
+  return fmt.Sprintf("[%s %s]", g.Type, g.Name)
+}
+
+// The geometry or one of its descendants with the given name, or nil
+func (g *Geometry) Find(name string) *Geometry {
+  if g.Name == name {
+    return g
+  }
+
+  for _, child := range g.Children {
+    if found := child.Find(name); found != nil {
+      return found
+    }
+  }
+
+  return nil
+}
+
+// The position of the geometry relative to the root of its tree
+func (g *Geometry) Transform() Matrix {
+  if g.Parent == nil {
+    return g.Position
+  }
+
+  return g.Parent.Transform().Mul(g.Position)
+}
+
+// Visit the geometry and its descendants, parents first
+func (g *Geometry) Walk(visit func(*Geometry)) {
+  visit(g)
+
+  for _, child := range g.Children {
+    child.Walk(visit)
+  }
+}
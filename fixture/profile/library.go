@@ -0,0 +1,110 @@
+package profile
+
+import (
+  "errors"
+  "os"
+  "path/filepath"
+  "sort"
+  "strings"
+  "sync"
+)
+
+/*
+A set of profiles by manufacturer and model, usually loaded from a directory of
+.json files. Loading again picks up profiles added while GoLX is running.
+*/
+type Library struct {
+  lock sync.Mutex
+  profiles map[string] *Profile
+}
+
+func NewLibrary() *Library {
+  lib := new(Library)
+  lib.profiles = make(map[string] *Profile)
+  return lib
+}
+
+// Build a library from every .json file under dir
+func LoadLibrary(dir string) (*Library, error) {
+  lib := NewLibrary()
+  return lib, lib.Load(dir)
+}
+
+func key(manufacturer, model string) string {
+  return strings.ToLower(manufacturer) + "/" + strings.ToLower(model)
+}
+
+/*
+Add every .json file under dir, replacing profiles with the same manufacturer
+and model. Every file is tried and the first error is returned.
+*/
+func (lib *Library) Load(dir string) error {
+  var first error
+
+  err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+    if err != nil {
+      return err
+    }
+
+    if info.IsDir() || strings.ToLower(filepath.Ext(path)) != ".json" {
+      return nil
+    }
+
+    p, err := LoadFile(path)
+
+    if err != nil {
+      if first == nil {
+        first = err
+      }
+      return nil
+    }
+
+    lib.Add(p)
+    return nil
+  })
+
+  if err != nil {
+    return err
+  }
+
+  return first
+}
+
+func (lib *Library) Add(p *Profile) {
+  lib.lock.Lock()
+  defer lib.lock.Unlock()
+  lib.profiles[key(p.Manufacturer, p.Model)] = p
+}
+
+// Find a profile, ignoring case
+func (lib *Library) Get(manufacturer, model string) (*Profile, error) {
+  lib.lock.Lock()
+  defer lib.lock.Unlock()
+
+  p, exists := lib.profiles[key(manufacturer, model)]
+
+  if !exists {
+    return nil, errors.New("No profile for " + manufacturer + " " + model)
+  }
+
+  return p, nil
+}
+
+// Every profile ordered by manufacturer then model
+func (lib *Library) Profiles() []*Profile {
+  lib.lock.Lock()
+  defer lib.lock.Unlock()
+
+  keys := make([]string, 0, len(lib.profiles))
+  for k := range lib.profiles {
+    keys = append(keys, k)
+  }
+  sort.Strings(keys)
+
+  profiles := make([]*Profile, len(keys))
+  for i, k := range keys {
+    profiles[i] = lib.profiles[k]
+  }
+
+  return profiles
+}
@@ -0,0 +1,706 @@
+/*
+Open Fixture Library import
+
+Converts fixture definitions in the Open Fixture Library JSON format to GoLX
+profiles. Each OFL channel in a mode becomes an attribute, with its fine
+channel aliases making it a 16 or 24 bit attribute. Capabilities with DMX
+ranges become named ranges; wheel slots and shakes are named from the
+fixture's wheels and rotations through stop are split into their directions.
+Zoom angles, iris and frost percentages and strobe rates in Hz give the
+attribute a map of physical values.
+
+OFL files don't contain the manufacturer's name, which comes from the
+directory the file is in. Anything that can't be represented, such as matrix
+channels, switching channels or capability types GoLX doesn't know, is left
+out or imported as a control attribute and reported as a warning.
+*/
+package ofl
+
+import (
+  "encoding/json"
+  "errors"
+  "fmt"
+  "io"
+  "os"
+  "path/filepath"
+  "strconv"
+  "strings"
+  "golx/fixture/converter"
+  "golx/fixture/profile"
+)
+
+// Something in a fixture definition that couldn't be imported exactly
+type Warning struct {
+  Channel string
+  Message string
+}
+
+func (w Warning) String() string {
+  if w.Channel == "" {
+    return w.Message
+  }
+  return w.Channel + ": " + w.Message
+}
+
+type oflFixture struct {
+  Name string `json:"name"`
+  Matrix *oflMatrix `json:"matrix"`
+  Wheels map[string] oflWheel `json:"wheels"`
+  AvailableChannels map[string] oflChannel `json:"availableChannels"`
+  TemplateChannels map[string] json.RawMessage `json:"templateChannels"`
+  Modes []oflMode `json:"modes"`
+}
+
+type oflMatrix struct {
+  PixelCount []int `json:"pixelCount"`
+  PixelKeys json.RawMessage `json:"pixelKeys"`
+}
+
+type oflWheel struct {
+  Slots []oflSlot `json:"slots"`
+}
+
+type oflSlot struct {
+  Type string `json:"type"`
+  Name string `json:"name"`
+}
+
+type oflChannel struct {
+  FineChannelAliases []string `json:"fineChannelAliases"`
+  DMXValueResolution string `json:"dmxValueResolution"`
+  DefaultValue json.RawMessage `json:"defaultValue"`
+  Capability *oflCapability `json:"capability"`
+  Capabilities []oflCapability `json:"capabilities"`
+}
+
+type oflCapability struct {
+  DMXRange []int `json:"dmxRange"`
+  Type string `json:"type"`
+  Comment string `json:"comment"`
+  Color string `json:"color"`
+  SlotNumber float64 `json:"slotNumber"`
+  SlotNumberStart float64 `json:"slotNumberStart"`
+  SlotNumberEnd float64 `json:"slotNumberEnd"`
+  Speed string `json:"speed"`
+  SpeedStart string `json:"speedStart"`
+  SpeedEnd string `json:"speedEnd"`
+  Angle string `json:"angle"`
+  AngleStart string `json:"angleStart"`
+  AngleEnd string `json:"angleEnd"`
+  OpenPercent string `json:"openPercent"`
+  OpenPercentStart string `json:"openPercentStart"`
+  OpenPercentEnd string `json:"openPercentEnd"`
+  FrostIntensity string `json:"frostIntensity"`
+  FrostIntensityStart string `json:"frostIntensityStart"`
+  FrostIntensityEnd string `json:"frostIntensityEnd"`
+  ShutterEffect string `json:"shutterEffect"`
+  EffectName string `json:"effectName"`
+  SwitchChannels map[string] string `json:"switchChannels"`
+}
+
+type oflMode struct {
+  Name string `json:"name"`
+  Channels []json.RawMessage `json:"channels"`
+}
+
+// A matrix insert in a mode's channel list
+type oflInsert struct {
+  Insert string `json:"insert"`
+  RepeatFor json.RawMessage `json:"repeatFor"`
+  TemplateChannels []string `json:"templateChannels"`
+}
+
+// GoLX attribute types for OFL capability types
+var capabilityTypes = map[string] string{
+  "Intensity": "intensity",
+
+  // Scaling a shutter would change its effect rather than its level
+  "ShutterStrobe": "beam",
+  "Pan": "position",
+  "PanContinuous": "position",
+  "Tilt": "position",
+  "TiltContinuous": "position",
+  "PanTiltSpeed": "position",
+  "ColorIntensity": "colour",
+  "ColorPreset": "colour",
+  "ColorTemperature": "colour",
+  "WheelSlot": "beam",
+  "WheelShake": "beam",
+  "WheelSlotRotation": "beam",
+  "WheelRotation": "beam",
+  "Prism": "beam",
+  "PrismRotation": "beam",
+  "Iris": "beam",
+  "IrisEffect": "beam",
+  "Frost": "beam",
+  "FrostEffect": "beam",
+  "Zoom": "beam",
+  "Focus": "beam",
+  "BeamAngle": "beam",
+  "BeamPosition": "beam",
+  "BladeInsertion": "beam",
+  "BladeRotation": "beam",
+  "BladeSystemRotation": "beam",
+  "Effect": "control",
+  "EffectSpeed": "control",
+  "EffectDuration": "control",
+  "EffectParameter": "control",
+  "SoundSensitivity": "control",
+  "Speed": "control",
+  "Time": "control",
+  "Rotation": "control",
+  "Fog": "control",
+  "FogOutput": "control",
+  "FogType": "control",
+  "Maintenance": "control",
+  "Generic": "control",
+  "NoFunction": "",
+}
+
+// Units of the capability types with physical values, and their OFL suffixes
+var physicalUnits = map[string] struct{ unit, suffix string }{
+  "Zoom": {"degrees", "deg"},
+  "Iris": {"percent", "%"},
+  "Frost": {"percent", "%"},
+  "ShutterStrobe": {"Hz", "Hz"},
+}
+
+// Import a fixture definition made by manufacturer
+func Import(r io.Reader, manufacturer string) (*profile.Profile, []Warning, error) {
+  var f oflFixture
+
+  if err := json.NewDecoder(r).Decode(&f); err != nil {
+    return nil, nil, err
+  }
+
+  if f.Name == "" {
+    return nil, nil, errors.New("Fixture has no name")
+  }
+
+  imp := &importer{fixture: &f, warnings: make([]Warning, 0)}
+
+  p := new(profile.Profile)
+  p.Manufacturer = manufacturer
+  p.Model = f.Name
+
+  for _, mode := range f.Modes {
+    m, err := imp.mode(mode)
+
+    if err != nil {
+      return nil, imp.warnings, errors.New("Mode " + mode.Name + ": " + err.Error())
+    }
+
+    p.Modes = append(p.Modes, m)
+  }
+
+  if err := p.Validate(); err != nil {
+    return nil, imp.warnings, err
+  }
+
+  return p, imp.warnings, nil
+}
+
+/*
+Import a fixture definition file, taking the manufacturer from the name of the
+directory it is in as in the OFL repository
+*/
+func ImportFile(path string) (*profile.Profile, []Warning, error) {
+  f, err := os.Open(path)
+
+  if err != nil {
+    return nil, nil, err
+  }
+
+  defer f.Close()
+
+  manufacturer := filepath.Base(filepath.Dir(path))
+  p, warnings, err := Import(f, manufacturer)
+
+  if err != nil {
+    return nil, warnings, errors.New(path + ": " + err.Error())
+  }
+
+  return p, warnings, nil
+}
+
+type importer struct {
+  fixture *oflFixture
+  warnings []Warning
+}
+
+func (imp *importer) warn(channel, format string, args ...interface {}) {
+  imp.warnings = append(imp.warnings, Warning{channel, fmt.Sprintf(format, args...)})
+}
+
+// Find the channel that a fine alias belongs to and how fine it is
+func (imp *importer) fineOf(name string) (string, int, bool) {
+  for key, channel := range imp.fixture.AvailableChannels {
+    for i, alias := range channel.FineChannelAliases {
+      if alias == name {
+        return key, i + 1, true
+      }
+    }
+  }
+  return "", 0, false
+}
+
+func (imp *importer) mode(mode oflMode) (profile.Mode, error) {
+  m := profile.Mode{Name: mode.Name}
+
+  // Channel numbers of each OFL channel by name, coarse channels first
+  positions := make(map[string] int)
+  fines := make(map[string] map[int] int)
+  order := make([]string, 0)
+
+  number := 0
+  for _, raw := range mode.Channels {
+    number++
+
+    var name string
+    if err := json.Unmarshal(raw, &name); err != nil {
+      // Not a channel name, so it should be a matrix insert
+      n, err := imp.insert(raw)
+      if err != nil {
+        return m, err
+      }
+      number += n - 1
+      continue
+    }
+
+    if name == "" {
+      // Unused channel
+      continue
+    }
+
+    if _, exists := imp.fixture.AvailableChannels[name]; exists {
+      positions[name] = number
+      order = append(order, name)
+    } else if coarse, fineness, ok := imp.fineOf(name); ok {
+      if fines[coarse] == nil {
+        fines[coarse] = make(map[int] int)
+      }
+      fines[coarse][fineness] = number
+    } else {
+      return m, fmt.Errorf("Channel %d refers to unknown channel %s", number, name)
+    }
+  }
+
+  for coarse := range fines {
+    if _, exists := positions[coarse]; !exists {
+      imp.warn(coarse, "Fine channel in mode %s without its coarse channel was left out", mode.Name)
+    }
+  }
+
+  for _, name := range order {
+    def, ok := imp.attribute(name, positions[name], fines[name], mode.Name)
+    if ok {
+      m.Attributes = append(m.Attributes, def)
+    }
+  }
+
+  return m, nil
+}
+
+// Skip the channels of a matrix insert, returning how many there are
+func (imp *importer) insert(raw json.RawMessage) (int, error) {
+  var insert oflInsert
+
+  if err := json.Unmarshal(raw, &insert); err != nil || insert.Insert != "matrixChannels" {
+    return 0, errors.New("Unsupported entry in channel list")
+  }
+
+  pixels := 0
+  var keys []json.RawMessage
+
+  if err := json.Unmarshal(insert.RepeatFor, &keys); err == nil {
+    pixels = len(keys)
+  } else if imp.fixture.Matrix != nil && len(imp.fixture.Matrix.PixelCount) > 0 {
+    pixels = 1
+    for _, n := range imp.fixture.Matrix.PixelCount {
+      pixels *= n
+    }
+  } else {
+    return 0, errors.New("Matrix channels need pixelCount or a list of pixel keys")
+  }
+
+  count := pixels * len(insert.TemplateChannels)
+  imp.warn("", "Matrix channels are not supported, %d channels were left out", count)
+
+  return count, nil
+}
+
+func (imp *importer) attribute(name string, coarse int, fines map[int] int, mode string) (profile.AttributeDef, bool) {
+  channel := imp.fixture.AvailableChannels[name]
+  def := profile.AttributeDef{Name: name, Channels: []int{coarse}}
+
+  // Fine channels are only usable if they follow the coarse channel in order
+  for i := 1; i <= len(fines); i++ {
+    number, exists := fines[i]
+    if !exists || number != coarse + i {
+      imp.warn(name, "Fine channels in mode %s are not consecutive, imported as 8 bit", mode)
+      def.Channels = []int{coarse}
+      break
+    }
+    def.Channels = append(def.Channels, number)
+  }
+
+  capabilities := channel.Capabilities
+  if channel.Capability != nil {
+    capabilities = []oflCapability{*channel.Capability}
+  }
+
+  if len(capabilities) == 0 {
+    imp.warn(name, "Channel has no capabilities")
+    return def, false
+  }
+
+  // Dmx ranges are given at the channel's resolution, whatever the mode uses
+  shift := uint(8 * (resolution(channel) - 1))
+
+  points := make([]converter.Point, 0)
+
+  for _, capability := range capabilities {
+    if len(capability.SwitchChannels) > 0 {
+      imp.warn(name, "Switching channels are not supported")
+    }
+
+    kind, known := capabilityTypes[capability.Type]
+
+    if !known {
+      imp.warn(name, "Unsupported capability %s imported as control", capability.Type)
+      kind = "control"
+    }
+
+    // The first capability that does something decides the attribute type
+    if def.Type == "" {
+      def.Type = kind
+    }
+
+    if len(capability.DMXRange) == 2 {
+      from, to := capability.DMXRange[0] >> shift, capability.DMXRange[1] >> shift
+      def.Ranges = append(def.Ranges, imp.ranges(name, capability, from, to)...)
+    }
+
+    if unit, start, end, ok := physical(capability); ok {
+      // A capability without a range covers the whole channel
+      from, to := 0.0, 255.0
+      if len(capability.DMXRange) == 2 {
+        from = float64(capability.DMXRange[0]) / float64(uint64(1) << shift)
+        to = float64(capability.DMXRange[1]) / float64(uint64(1) << shift)
+      }
+
+      def.Unit = unit
+      points = append(points, converter.Point{DMX: from, Value: start})
+      if to != from {
+        points = append(points, converter.Point{DMX: to, Value: end})
+      }
+    }
+  }
+
+  // A single capability can't be converted, e.g. a strobe at one rate
+  if len(points) > 1 {
+    def.Map = points
+    if _, err := def.Converter(); err != nil {
+      imp.warn(name, "Physical values were left out: %s", err.Error())
+      def.Unit = ""
+      def.Map = nil
+    }
+  } else {
+    def.Unit = ""
+  }
+
+  if def.Type == "" {
+    def.Type = "control"
+  }
+
+  def.Default = imp.defaultLevel(name, channel)
+
+  return def, true
+}
+
+/*
+The named ranges of a capability from one DMX value to another. Most
+capabilities are a single range, but a shake across several slots has a range
+for each slot and a rotation that passes through stop is split at the stop.
+*/
+func (imp *importer) ranges(channel string, capability oflCapability, from, to int) []profile.Range {
+  if capability.Type == "WheelShake" {
+    if shakes := imp.shakeRanges(channel, capability, from, to); shakes != nil {
+      return shakes
+    }
+  }
+
+  if strings.HasSuffix(capability.Type, "Rotation") {
+    if split := splitRotation(capability, from, to); split != nil {
+      return split
+    }
+  }
+
+  return []profile.Range{{From: from, To: to, Name: imp.rangeName(channel, capability)}}
+}
+
+/*
+A range for each slot a wheel shakes, dividing the capability evenly, named
+after the slot, e.g. Dots shake, or Gobo 3 shake for a slot without a name.
+Returns nil if the slots aren't on the wheel.
+*/
+func (imp *importer) shakeRanges(channel string, capability oflCapability, from, to int) []profile.Range {
+  wheel, exists := imp.fixture.Wheels[channel]
+  if !exists {
+    return nil
+  }
+
+  first, last := int(capability.SlotNumber), int(capability.SlotNumber)
+  if capability.SlotNumberStart >= 1 {
+    first, last = int(capability.SlotNumberStart), int(capability.SlotNumberEnd)
+    if last < first {
+      first, last = last, first
+    }
+  }
+
+  if first < 1 || last > len(wheel.Slots) || to - from + 1 < last - first + 1 {
+    return nil
+  }
+
+  count := last - first + 1
+  ranges := make([]profile.Range, 0, count)
+
+  for i := 0; i < count; i++ {
+    number := first + i
+
+    name := wheel.Slots[number - 1].Name
+    if name == "" {
+      name = fmt.Sprintf("%s %d", wheel.Slots[number - 1].Type, number)
+    }
+
+    ranges = append(ranges, profile.Range{
+      From: from + (to - from + 1) * i / count,
+      To: from + (to - from + 1) * (i + 1) / count - 1,
+      Name: name + " shake",
+    })
+  }
+
+  return ranges
+}
+
+/*
+Split a rotation that runs from one direction to the other, e.g. fast CCW to
+fast CW, into a range for each direction with a stop between them. Returns nil
+for rotations in one direction.
+*/
+func splitRotation(capability oflCapability, from, to int) []profile.Range {
+  first := direction(capability.SpeedStart)
+  second := direction(capability.SpeedEnd)
+
+  if first == "" || second == "" || first == second || to - from < 2 {
+    return nil
+  }
+
+  middle := (from + to) / 2
+
+  // The speed falls to the stop and rises again after it
+  return []profile.Range{
+    {From: from, To: middle - 1, Name: capabilityName(capability, []string{first, "fast-slow"})},
+    {From: middle, To: middle, Name: capabilityName(capability, []string{"Stop"})},
+    {From: middle + 1, To: to, Name: capabilityName(capability, []string{second})},
+  }
+}
+
+// The direction of a speed, CW, CCW or an empty string if it has none
+func direction(speed string) string {
+  speed = strings.ToLower(speed)
+
+  switch {
+  case strings.Contains(speed, "ccw"):
+    return "CCW"
+  case strings.Contains(speed, "cw"):
+    return "CW"
+  }
+  return ""
+}
+
+/*
+A name for a capability's range, using wheel slot names where possible. Slots
+between two others, such as split colours, are named after both, e.g.
+Red/Blue. Rotations are named with their direction so the dmxwheel package
+can find them.
+*/
+func (imp *importer) rangeName(channel string, capability oflCapability) string {
+  if capability.Type == "WheelSlot" && capability.SlotNumber >= 1 {
+    if wheel, exists := imp.fixture.Wheels[channel]; exists {
+      first := int(capability.SlotNumber)
+      names := []string{imp.slotName(wheel, first)}
+
+      if float64(first) != capability.SlotNumber {
+        names = append(names, imp.slotName(wheel, first + 1))
+      }
+
+      if names[0] != "" && names[len(names) - 1] != "" {
+        return strings.Join(names, "/")
+      }
+    }
+  }
+
+  var words []string
+  if strings.HasSuffix(capability.Type, "Rotation") {
+    words = rotationName(capability)
+  }
+
+  return capabilityName(capability, words)
+}
+
+// The capability's type followed by words and the capability's details
+func capabilityName(capability oflCapability, words []string) string {
+  parts := append([]string{capability.Type}, words...)
+
+  for _, detail := range []string{capability.ShutterEffect, capability.Color, capability.EffectName, capability.Comment} {
+    if detail != "" {
+      parts = append(parts, detail)
+    }
+  }
+
+  return strings.Join(parts, " ")
+}
+
+// The name of a slot counting from 1, or an empty string if there isn't one
+func (imp *importer) slotName(wheel oflWheel, number int) string {
+  if number < 1 || number > len(wheel.Slots) {
+    return ""
+  }
+
+  slot := wheel.Slots[number - 1]
+  if slot.Name != "" {
+    return slot.Name
+  }
+  return slot.Type
+}
+
+// Words describing a rotation: Index, Stop, CW or CCW and whether it slows down
+func rotationName(capability oflCapability) []string {
+  if capability.Angle != "" || capability.AngleStart != "" {
+    return []string{"Index"}
+  }
+
+  start := strings.ToLower(capability.SpeedStart)
+  end := strings.ToLower(capability.SpeedEnd)
+  if capability.Speed != "" {
+    start = strings.ToLower(capability.Speed)
+    end = start
+  }
+
+  switch {
+  case start == "" && end == "":
+    return nil
+  case strings.Contains(start, "stop") && strings.Contains(end, "stop"):
+    return []string{"Stop"}
+  }
+
+  words := make([]string, 0, 2)
+  if dir := direction(start + end); dir != "" {
+    words = append(words, dir)
+  }
+
+  if strings.Contains(start, "fast") && (strings.Contains(end, "slow") || strings.Contains(end, "stop")) {
+    words = append(words, "fast-slow")
+  }
+
+  return words
+}
+
+/*
+The unit and the values at the start and end of a capability's range, if its
+type has physical values and they are numbers, e.g. 20deg rather than wide
+*/
+func physical(capability oflCapability) (string, float64, float64, bool) {
+  units, known := physicalUnits[capability.Type]
+  if !known {
+    return "", 0, 0, false
+  }
+
+  var pair [3]string
+  switch capability.Type {
+  case "Zoom":
+    pair = [3]string{capability.Angle, capability.AngleStart, capability.AngleEnd}
+  case "Iris":
+    pair = [3]string{capability.OpenPercent, capability.OpenPercentStart, capability.OpenPercentEnd}
+  case "Frost":
+    pair = [3]string{capability.FrostIntensity, capability.FrostIntensityStart, capability.FrostIntensityEnd}
+  case "ShutterStrobe":
+    // Only plain strobes map rates to values, not pulses, ramps or random strobes
+    if capability.ShutterEffect != "Strobe" {
+      return "", 0, 0, false
+    }
+    pair = [3]string{capability.Speed, capability.SpeedStart, capability.SpeedEnd}
+  }
+
+  // A single value holds across the range
+  if pair[0] != "" {
+    pair[1], pair[2] = pair[0], pair[0]
+  }
+
+  start, err := strconv.ParseFloat(strings.TrimSuffix(pair[1], units.suffix), 64)
+  if err != nil || !strings.HasSuffix(pair[1], units.suffix) {
+    return "", 0, 0, false
+  }
+
+  end, err := strconv.ParseFloat(strings.TrimSuffix(pair[2], units.suffix), 64)
+  if err != nil || !strings.HasSuffix(pair[2], units.suffix) {
+    return "", 0, 0, false
+  }
+
+  return units.unit, start, end, true
+}
+
+/*
+The number of bytes DMX values of a channel are given in. Without a
+resolution they are given at the channel's highest resolution, with a byte for
+the coarse channel and each of its fine channels, even in modes that don't use
+the fine channels.
+*/
+func resolution(channel oflChannel) int {
+  switch channel.DMXValueResolution {
+  case "8bit":
+    return 1
+  case "16bit":
+    return 2
+  case "24bit":
+    return 3
+  }
+  return len(channel.FineChannelAliases) + 1
+}
+
+// Convert a default value, either a DMX value or a percentage, to a level
+func (imp *importer) defaultLevel(name string, channel oflChannel) float64 {
+  if len(channel.DefaultValue) == 0 {
+    return 0
+  }
+
+  var text string
+  if err := json.Unmarshal(channel.DefaultValue, &text); err == nil {
+    percent, err := strconv.ParseFloat(strings.TrimSuffix(text, "%"), 64)
+    if err != nil || !strings.HasSuffix(text, "%") {
+      imp.warn(name, "Default value %s was not understood", text)
+      return 0
+    }
+    return clamp(percent / 100)
+  }
+
+  var value float64
+  if err := json.Unmarshal(channel.DefaultValue, &value); err != nil {
+    imp.warn(name, "Default value was not understood")
+    return 0
+  }
+
+  bits := uint(8 * resolution(channel))
+  return clamp(value / float64(uint64(1) << bits - 1))
+}
+
+func clamp(level float64) float64 {
+  if level < 0 {
+    return 0
+  }
+  if level > 1 {
+    return 1
+  }
+  return level
+}
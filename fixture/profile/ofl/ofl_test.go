@@ -0,0 +1,200 @@
+package ofl
+
+import (
+  "strings"
+  "testing"
+  "golx/fixture/profile"
+)
+
+func hasWarning(warnings []Warning, text string) bool {
+  for _, w := range warnings {
+    if strings.Contains(w.String(), text) {
+      return true
+    }
+  }
+  return false
+}
+
+func TestImportDimmer(t *testing.T) {
+  p, warnings, err := ImportFile("testdata/generic/desk-channel.json")
+
+  if err != nil {
+    t.Log("Error importing: ", err.Error())
+    t.FailNow()
+  }
+
+  if p.Manufacturer != "generic" || p.Model != "Desk Channel" || len(warnings) != 0 {
+    t.Log("Dimmer imported incorrectly: ", p, warnings)
+    t.Fail()
+  }
+
+  mode, _ := p.Mode("1-channel")
+  if len(mode.Attributes) != 1 || mode.Attributes[0].Type != "intensity" {
+    t.Log("Dimmer attribute imported incorrectly: ", mode.Attributes)
+    t.Fail()
+  }
+}
+
+func TestImportMovingHead(t *testing.T) {
+  p, warnings, err := ImportFile("testdata/robe/robin-spot.json")
+
+  if err != nil {
+    t.Log("Error importing: ", err.Error())
+    t.FailNow()
+  }
+
+  mode, err := p.Mode("Standard")
+  if err != nil {
+    t.Log(err.Error())
+    t.FailNow()
+  }
+
+  if mode.Footprint() != 13 {
+    t.Log("Footprint is ", mode.Footprint(), " expected 13")
+    t.Fail()
+  }
+
+  attrs := make(map[string] int)
+  for i, def := range mode.Attributes {
+    attrs[def.Name] = i
+  }
+
+  pan := mode.Attributes[attrs["Pan"]]
+  if pan.Type != "position" || len(pan.Channels) != 2 || pan.Channels[1] != 2 || pan.Default < 0.49 || pan.Default > 0.51 {
+    t.Log("Pan imported incorrectly: ", pan)
+    t.Fail()
+  }
+
+  dimmer := mode.Attributes[attrs["Dimmer"]]
+  if dimmer.Type != "intensity" || len(dimmer.Channels) != 2 || dimmer.Channels[0] != 8 {
+    t.Log("Dimmer imported incorrectly: ", dimmer)
+    t.Fail()
+  }
+
+  // Wheel slots are named from the wheel
+  gobo := mode.Attributes[attrs["Gobo Wheel"]]
+  if r, err := gobo.Range("Dots"); err != nil || r.From != 10 || r.To != 19 {
+    t.Log("Gobo wheel ranges imported incorrectly: ", gobo.Ranges)
+    t.Fail()
+  }
+
+  // Split colours are named after both slots and rotations after their direction
+  colour := mode.Attributes[attrs["Color Wheel"]]
+  if _, err := colour.Range("Red/Blue"); err != nil {
+    t.Log("Split colour imported incorrectly: ", colour.Ranges)
+    t.Fail()
+  }
+  if _, err := colour.Range("WheelRotation CW"); err != nil {
+    t.Log("Wheel rotation imported incorrectly: ", colour.Ranges)
+    t.Fail()
+  }
+
+  // A rotation from fast CCW to fast CW is split at the stop in the middle
+  ccw, ccwErr := colour.Range("WheelRotation CCW fast-slow")
+  stop, stopErr := colour.Range("WheelRotation Stop")
+  cw, _ := colour.Range("WheelRotation CW")
+  if ccwErr != nil || stopErr != nil || ccw.From != 30 || ccw.To != 141 || stop.From != 142 || cw.From != 143 || cw.To != 255 {
+    t.Log("Rotation through stop imported incorrectly: ", colour.Ranges)
+    t.Fail()
+  }
+
+  // Shakes are named after their slots, one range for each
+  dots, dotsErr := gobo.Range("Dots shake")
+  breakup, breakupErr := gobo.Range("Breakup shake")
+  if dotsErr != nil || breakupErr != nil || dots.From != 30 || dots.To != 142 || breakup.From != 143 || breakup.To != 255 {
+    t.Log("Gobo shake imported incorrectly: ", gobo.Ranges)
+    t.Fail()
+  }
+
+  shutter := mode.Attributes[attrs["Shutter"]]
+  if _, err := shutter.Range("ShutterStrobe Strobe"); err != nil || shutter.Type != "beam" {
+    t.Log("Shutter imported incorrectly: ", shutter)
+    t.Fail()
+  }
+
+  strobe, err := shutter.Converter()
+  // Only the strobe gives rates, not the pulse after it
+  if err != nil || shutter.Unit != "Hz" || strobe.DMX(1) != 64 || strobe.DMX(20) != 191 || len(shutter.Map) != 2 {
+    t.Log("Strobe rates imported incorrectly: ", shutter.Map, err)
+    t.Fail()
+  }
+
+  zoom := mode.Attributes[attrs["Zoom"]]
+  angles, err := zoom.Converter()
+  if err != nil || zoom.Unit != "degrees" || angles.Min() != 5 || angles.Max() != 38 {
+    t.Log("Zoom angles imported incorrectly: ", zoom.Map, err)
+    t.Fail()
+  }
+
+  if !hasWarning(warnings, "Laser Dazzle: Unsupported capability LaserDazzle") {
+    t.Log("Unsupported capability was not reported: ", warnings)
+    t.Fail()
+  }
+
+  // Tilt fine doesn't follow tilt in the reduced mode
+  reduced, _ := p.Mode("Reduced")
+  for _, def := range reduced.Attributes {
+    if def.Name == "Tilt" && len(def.Channels) != 1 {
+      t.Log("Non-consecutive fine channel was used")
+      t.Fail()
+    }
+  }
+
+  // Without a resolution values are 16 bit, even where the fine channel isn't used
+  for _, def := range append(reduced.Attributes, dimmer) {
+    if def.Name != "Dimmer" {
+      continue
+    }
+    if def.Default < 0.49 || def.Default > 0.51 || len(def.Ranges) != 1 || def.Ranges[0].To != 255 {
+      t.Log("Dimmer resolution imported incorrectly with ", len(def.Channels), " channels: ", def)
+      t.Fail()
+    }
+  }
+
+  if !hasWarning(warnings, "Tilt: Fine channels in mode Reduced are not consecutive") {
+    t.Log("Non-consecutive fine channel was not reported: ", warnings)
+    t.Fail()
+  }
+
+  // The imported profile builds fixtures like any other
+  f, err := profile.New(p, "Standard", 1)
+  if err != nil || len(f.Attributes()) != 9 {
+    t.Log("Imported profile did not build a fixture: ", err)
+    t.Fail()
+  }
+}
+
+func TestImportMatrixReportsSkippedChannels(t *testing.T) {
+  p, warnings, err := ImportFile("testdata/eurolite/led-bar.json")
+
+  if err != nil {
+    t.Log("Error importing: ", err.Error())
+    t.FailNow()
+  }
+
+  mode, _ := p.Mode("8-channel")
+  if len(mode.Attributes) != 2 {
+    t.Log("Expected only the master and program channels: ", mode.Attributes)
+    t.Fail()
+  }
+
+  if !hasWarning(warnings, "6 channels were left out") {
+    t.Log("Matrix channels were not reported: ", warnings)
+    t.Fail()
+  }
+}
+
+func TestImportErrors(t *testing.T) {
+  bad := []string{
+    `{"modes": []}`,
+    `{"name": "A", "availableChannels": {}, "modes": [{"name": "m", "channels": ["Missing"]}]}`,
+    `{"name": "A", "availableChannels": {}, "modes": [{"name": "m", "channels": [{"insert": "other"}]}]}`,
+  }
+
+  for _, text := range bad {
+    if _, _, err := Import(strings.NewReader(text), "test"); err == nil {
+      t.Log("Invalid fixture was imported: ", text)
+      t.Fail()
+    }
+  }
+}
@@ -0,0 +1,322 @@
+/*
+Fixture profiles
+
+A profile describes a fixture type in JSON so new fixtures don't need their own
+Go package. Each mode of the fixture lists its attributes with the channels
+they use, counted from 1 at the fixture's start address. An attribute with two
+channels is a 16 bit pair, coarse channel first.
+
+  {
+    "manufacturer": "Generic",
+    "model": "Spot",
+    "modes": [{
+      "name": "Standard",
+      "attributes": [
+        {"name": "intensity", "type": "intensity", "channels": [1, 2]},
+        {"name": "pan", "type": "position", "channels": [3, 4], "default": 0.5},
+        {"name": "gobo", "type": "beam", "channels": [5], "ranges": [
+          {"from": 0, "to": 9, "name": "Open"},
+          {"from": 10, "to": 19, "name": "Dots"}
+        ]}
+      ]
+    }]
+  }
+
+Attribute types are intensity, position, colour, beam and control. Defaults are
+levels from 0 to 1 and ranges are named spans of DMX values on the coarse
+channel.
+
+Attributes with physical values, such as zoom or strobe rate, give their unit
+and a map of points from which values in that unit are converted to DMX values
+of the coarse channel, e.g.
+
+  {"name": "zoom", "type": "beam", "channels": [6], "unit": "degrees", "map": [
+    {"dmx": 0, "value": 40}, {"dmx": 64, "value": 20}, {"dmx": 255, "value": 5}
+  ]}
+*/
+package profile
+
+import (
+  "encoding/json"
+  "errors"
+  "fmt"
+  "io"
+  "os"
+  "strings"
+  "golx/dmx"
+  "golx/fixture/converter"
+)
+
+type Profile struct {
+  Manufacturer string `json:"manufacturer"`
+  Model string `json:"model"`
+  Modes []Mode `json:"modes"`
+}
+
+type Mode struct {
+  Name string `json:"name"`
+  Attributes []AttributeDef `json:"attributes"`
+}
+
+type AttributeDef struct {
+  Name string `json:"name"`
+  Type string `json:"type"`
+
+  // Offsets from the start address, counting from 1, most significant first
+  Channels []int `json:"channels"`
+
+  Default float64 `json:"default"`
+  Ranges []Range `json:"ranges,omitempty"`
+
+  // The unit of the attribute's physical value, e.g. degrees, Hz or percent
+  Unit string `json:"unit,omitempty"`
+
+  // Physical values at DMX values of the coarse channel
+  Map []converter.Point `json:"map,omitempty"`
+}
+
+// A named span of DMX values, e.g. a gobo or a shutter effect
+type Range struct {
+  From int `json:"from"`
+  To int `json:"to"`
+  Name string `json:"name"`
+}
+
+var classes = map[string] dmx.ParamClass{
+  "intensity": dmx.IntensityClass,
+  "position": dmx.PositionClass,
+  "colour": dmx.ColourClass,
+  "color": dmx.ColourClass,
+  "beam": dmx.BeamClass,
+  "control": dmx.ControlClass,
+}
+
+func Parse(r io.Reader) (*Profile, error) {
+  p := new(Profile)
+
+  if err := json.NewDecoder(r).Decode(p); err != nil {
+    return nil, err
+  }
+
+  if err := p.Validate(); err != nil {
+    return nil, err
+  }
+
+  return p, nil
+}
+
+func LoadFile(path string) (*Profile, error) {
+  f, err := os.Open(path)
+
+  if err != nil {
+    return nil, err
+  }
+
+  defer f.Close()
+
+  p, err := Parse(f)
+
+  if err != nil {
+    return nil, errors.New(path + ": " + err.Error())
+  }
+
+  return p, nil
+}
+
+// Write the profile as indented JSON in the format read by Parse
+func (p *Profile) Save(w io.Writer) error {
+  data, err := json.MarshalIndent(p, "", "  ")
+
+  if err != nil {
+    return err
+  }
+
+  _, err = w.Write(append(data, '\n'))
+  return err
+}
+
+func (p *Profile) String() string {
+  return p.Manufacturer + " " + p.Model
+}
+
+func (p *Profile) Mode(name string) (*Mode, error) {
+  for i := range p.Modes {
+    if p.Modes[i].Name == name {
+      return &p.Modes[i], nil
+    }
+  }
+
+  return nil, errors.New("Profile " + p.String() + " has no mode " + name)
+}
+
+// Check the profile describes fixtures that can be built
+func (p *Profile) Validate() error {
+  if p.Model == "" {
+    return errors.New("Profile has no model")
+  }
+
+  if len(p.Modes) == 0 {
+    return errors.New("Profile " + p.String() + " has no modes")
+  }
+
+  for _, mode := range p.Modes {
+    if err := mode.validate(); err != nil {
+      return errors.New("Mode " + mode.Name + ": " + err.Error())
+    }
+  }
+
+  return nil
+}
+
+func (mode *Mode) validate() error {
+  names := make(map[string] bool)
+  used := make(map[int] string)
+
+  for _, def := range mode.Attributes {
+    if def.Name == "" {
+      return errors.New("Attribute has no name")
+    }
+
+    if names[def.Name] {
+      return errors.New("Attribute " + def.Name + " is defined twice")
+    }
+    names[def.Name] = true
+
+    if _, known := classes[def.Type]; !known && def.Type != "" {
+      return errors.New("Attribute " + def.Name + " has unknown type " + def.Type)
+    }
+
+    if len(def.Channels) < 1 || len(def.Channels) > dmx.MaxChannelWidth {
+      return errors.New("Attribute " + def.Name + " must use between 1 and 4 channels")
+    }
+
+    if _, _, err := def.layout(); err != nil {
+      return errors.New("Attribute " + def.Name + ": " + err.Error())
+    }
+
+    for _, channel := range def.Channels {
+      if channel < 1 || channel > dmx.UniverseSize {
+        return fmt.Errorf("Attribute %s uses channel %d outside the universe", def.Name, channel)
+      }
+
+      if other, exists := used[channel]; exists {
+        return fmt.Errorf("Channel %d is used by %s and %s", channel, other, def.Name)
+      }
+      used[channel] = def.Name
+    }
+
+    if def.Default < 0 || def.Default > 1 {
+      return errors.New("Attribute " + def.Name + " default must be between 0 and 1")
+    }
+
+    if len(def.Map) > 0 {
+      if _, err := def.Converter(); err != nil {
+        return errors.New("Attribute " + def.Name + ": " + err.Error())
+      }
+
+      for _, point := range def.Map {
+        if point.DMX < 0 || point.DMX > 255 {
+          return fmt.Errorf("Attribute %s maps DMX value %g outside the channel", def.Name, point.DMX)
+        }
+      }
+    }
+
+    for _, r := range def.Ranges {
+      if r.From > r.To {
+        return fmt.Errorf("Attribute %s range %s starts after it ends", def.Name, r.Name)
+      }
+
+      if r.From < 0 || r.To > 255 {
+        return fmt.Errorf("Attribute %s range %s is outside the channel", def.Name, r.Name)
+      }
+    }
+  }
+
+  return nil
+}
+
+// The number of channels the mode uses
+func (mode *Mode) Footprint() int {
+  footprint := 0
+
+  for _, def := range mode.Attributes {
+    for _, channel := range def.Channels {
+      if channel > footprint {
+        footprint = channel
+      }
+    }
+  }
+
+  return footprint
+}
+
+func (def *AttributeDef) Class() dmx.ParamClass {
+  return classes[def.Type]
+}
+
+/*
+The first channel of the attribute and the byte order of its channels. Multi
+channel attributes must use consecutive channels in either direction.
+*/
+func (def *AttributeDef) layout() (int, dmx.ByteOrder, error) {
+  first := def.Channels[0]
+
+  if len(def.Channels) == 1 {
+    return first, dmx.MSBFirst, nil
+  }
+
+  step := def.Channels[1] - def.Channels[0]
+  if step != 1 && step != -1 {
+    return 0, dmx.MSBFirst, errors.New("Channels must be consecutive")
+  }
+
+  for i := 1; i < len(def.Channels); i++ {
+    if def.Channels[i] - def.Channels[i - 1] != step {
+      return 0, dmx.MSBFirst, errors.New("Channels must be consecutive")
+    }
+  }
+
+  if step == -1 {
+    return def.Channels[len(def.Channels) - 1], dmx.LSBFirst, nil
+  }
+
+  return first, dmx.MSBFirst, nil
+}
+
+/*
+The conversion between the attribute's physical values and DMX values of its
+coarse channel, or an error if it doesn't have a map
+*/
+func (def *AttributeDef) Converter() (*converter.Piecewise, error) {
+  if len(def.Map) == 0 {
+    return nil, errors.New("Attribute " + def.Name + " has no map of physical values")
+  }
+
+  return converter.NewPiecewise(def.Map)
+}
+
+/*
+The lower case words of the range's name, split at spaces, underscores,
+brackets and commas, for finding ranges by what they do
+*/
+func (r Range) Words() map[string] bool {
+  found := make(map[string] bool)
+  fields := strings.FieldsFunc(strings.ToLower(r.Name), func(c rune) bool {
+    return c == ' ' || c == '_' || c == '(' || c == ')' || c == ','
+  })
+  for _, field := range fields {
+    found[field] = true
+  }
+  return found
+}
+
+// The named range, or an error if the attribute doesn't have it
+func (def *AttributeDef) Range(name string) (Range, error) {
+  for _, r := range def.Ranges {
+    if r.Name == name {
+      return r, nil
+    }
+  }
+
+  return Range{}, errors.New("Attribute " + def.Name + " has no range " + name)
+}
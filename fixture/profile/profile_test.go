@@ -0,0 +1,142 @@
+package profile
+
+import (
+  "bytes"
+  "strings"
+  "testing"
+  "golx/dmx"
+  "golx/dmx/dmxtest"
+)
+
+func TestLoadLibrary(t *testing.T) {
+  lib, err := LoadLibrary("testdata/library")
+
+  if err != nil {
+    t.Log("Error loading library: ", err.Error())
+    t.FailNow()
+  }
+
+  if len(lib.Profiles()) != 2 {
+    t.Log("Library has ", len(lib.Profiles()), " profiles, expected 2")
+    t.Fail()
+  }
+
+  spot, err := lib.Get("generic", "SPOT")
+
+  if err != nil {
+    t.Log("Profile lookup failed: ", err.Error())
+    t.FailNow()
+  }
+
+  mode, _ := spot.Mode("Standard")
+  if mode.Footprint() != 7 {
+    t.Log("Footprint is ", mode.Footprint(), " expected 7")
+    t.Fail()
+  }
+}
+
+func TestValidate(t *testing.T) {
+  invalid := []string{
+    `{"model": "A", "modes": []}`,
+    `{"model": "A", "modes": [{"name": "m", "attributes": [{"name": "x", "channels": [1]}, {"name": "y", "channels": [1]}]}]}`,
+    `{"model": "A", "modes": [{"name": "m", "attributes": [{"name": "x", "channels": [1, 3]}]}]}`,
+    `{"model": "A", "modes": [{"name": "m", "attributes": [{"name": "x", "type": "smell", "channels": [1]}]}]}`,
+    `{"model": "A", "modes": [{"name": "m", "attributes": [{"name": "x", "channels": [1], "default": 2}]}]}`,
+    `{"model": "A", "modes": [{"name": "m", "attributes": [{"name": "x", "channels": [1], "map": [{"dmx": 0, "value": 1}, {"dmx": 9, "value": 5}, {"dmx": 20, "value": 2}]}]}]}`,
+    `{"model": "A", "modes": [{"name": "m", "attributes": [{"name": "x", "channels": [1], "map": [{"dmx": 0, "value": 1}, {"dmx": 300, "value": 5}]}]}]}`,
+    `{"model": "A", "modes": [{"name": "m", "attributes": [{"name": "x", "channels": [1], "ranges": [{"from": 20, "to": 10, "name": "Back"}]}]}]}`,
+    `{"model": "A", "modes": [{"name": "m", "attributes": [{"name": "x", "channels": [1, 2], "ranges": [{"from": 0, "to": 300, "name": "Wide"}]}]}]}`,
+  }
+
+  for _, text := range invalid {
+    if _, err := Parse(strings.NewReader(text)); err == nil {
+      t.Log("Invalid profile was accepted: ", text)
+      t.Fail()
+    }
+  }
+}
+
+func TestPhysicalMap(t *testing.T) {
+  text := `{"model": "Zoom", "modes": [{"name": "m", "attributes": [
+    {"name": "zoom", "type": "beam", "channels": [1, 2], "unit": "degrees", "map": [{"dmx": 0, "value": 40}, {"dmx": 255, "value": 5}]}
+  ]}]}`
+
+  p, err := Parse(strings.NewReader(text))
+  if err != nil {
+    t.Log("Error parsing profile: ", err.Error())
+    t.FailNow()
+  }
+
+  def := p.Modes[0].Attributes[0]
+  conv, err := def.Converter()
+  if err != nil || def.Unit != "degrees" || conv.DMX(22.5) != 127.5 {
+    t.Log("Map was not read: ", def, err)
+    t.Fail()
+  }
+}
+
+func TestSaveRoundTrip(t *testing.T) {
+  p, _ := LoadFile("testdata/library/par.json")
+
+  buf := new(bytes.Buffer)
+  p.Save(buf)
+
+  read, err := Parse(buf)
+
+  if err != nil || read.Model != "RGB Par" || len(read.Modes[0].Attributes) != 3 {
+    t.Log("Profile did not survive saving: ", err)
+    t.Fail()
+  }
+}
+
+func TestFixtureFromProfile(t *testing.T) {
+  p, err := LoadFile("testdata/library/generic/spot.json")
+
+  if err != nil {
+    t.Log("Error loading profile: ", err.Error())
+    t.FailNow()
+  }
+
+  if _, err := New(p, "Standard", 510); err == nil {
+    t.Log("Fixture past the end of the universe was allowed")
+    t.Fail()
+  }
+
+  f, err := New(p, "Standard", 101)
+
+  if err != nil {
+    t.Log("Error building fixture: ", err.Error())
+    t.FailNow()
+  }
+
+  u := dmx.NewDMXUniverse()
+  watch := u.Watch()
+
+  if err := f.Patch(u); err != nil {
+    t.Log("Error patching fixture: ", err.Error())
+    t.FailNow()
+  }
+
+  f.Attribute("intensity").Input() <- 1
+  f.Attribute("gobo").SetRange("Dots")
+
+  // Pan and tilt start at their defaults, tilt with its fine channel first
+  frame := dmxtest.WaitFor(watch, func(f dmx.DMXFrame) bool {
+    return f[100] == 0xff && f[101] == 0xff && f[102] == 0x80 && f[105] == 0x80 && f[106] == 15
+  })
+
+  if frame == nil {
+    t.Log("Fixture did not output its attributes")
+    t.FailNow()
+  }
+
+  if u.Class(101) != dmx.IntensityClass || u.Class(103) != dmx.PositionClass || u.Class(107) != dmx.BeamClass {
+    t.Log("Channels were not tagged with their attribute types")
+    t.Fail()
+  }
+
+  if len(f.Attributes()) != 4 || f.Attribute("pan").Value() != 0.5 {
+    t.Log("Attributes are incorrect")
+    t.Fail()
+  }
+}
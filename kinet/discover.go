@@ -0,0 +1,62 @@
+package kinet
+
+import (
+  "net"
+  "time"
+)
+
+/*
+Broadcast a discovery request and collect the supplies that reply within
+timeout. Supplies are identified by the address they replied from if it differs
+from the one in the reply.
+*/
+func Discover(timeout time.Duration) ([]*Supply, error) {
+  return discover(&net.UDPAddr{IP: net.IPv4bcast, Port: KinetPort}, timeout)
+}
+
+func discover(target *net.UDPAddr, timeout time.Duration) ([]*Supply, error) {
+  conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+
+  if err != nil {
+    return nil, err
+  }
+
+  defer conn.Close()
+
+  if _, err := conn.WriteToUDP(buildDiscover(), target); err != nil {
+    return nil, err
+  }
+
+  supplies := make([]*Supply, 0)
+  seen := make(map[string] bool)
+  data := make([]byte, 1500)
+
+  conn.SetReadDeadline(time.Now().Add(timeout))
+
+  for {
+    n, source, err := conn.ReadFromUDP(data)
+
+    if err != nil {
+      // The deadline passing ends discovery
+      if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+        return supplies, nil
+      }
+      return supplies, err
+    }
+
+    supply, err := parseDiscoverReply(data[:n])
+
+    if err != nil {
+      continue
+    }
+
+    if !supply.IP.Equal(source.IP) {
+      supply.IP = source.IP
+    }
+
+    if !seen[supply.IP.String()] {
+      seen[supply.IP.String()] = true
+      supplies = append(supplies, supply)
+    }
+  }
+}
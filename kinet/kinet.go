@@ -0,0 +1,166 @@
+/*
+KiNet packet support
+
+KiNet is the UDP protocol used by Philips Color Kinetics power/data supplies.
+Every packet starts with the same 12 byte little endian header followed by a
+body that depends on the packet type. Version 1 supplies take a single universe
+in a DMXOUT packet, version 2 supplies with several outputs take a PORTOUT packet
+addressed to one of their ports.
+*/
+package kinet
+
+import (
+  "bytes"
+  "encoding/binary"
+  "errors"
+  "net"
+  "golx/dmx"
+)
+
+const (
+  KinetPort int = 6038
+
+  magic uint32 = 0x4adc0104
+
+  // Universe field value meaning "whatever the supply is set to"
+  anyUniverse uint32 = 0xffffffff
+)
+
+type Version uint16
+
+const (
+  V1 Version = 0x0001
+  V2 Version = 0x0002
+)
+
+type packetType uint16
+
+const (
+  typeDiscoverSupplies packetType = 0x0001
+  typeDiscoverSuppliesReply packetType = 0x0002
+  typeDMXOut packetType = 0x0101
+  typePortOut packetType = 0x0108
+)
+
+type header struct {
+  Magic uint32
+  Version uint16
+  Type uint16
+  Sequence uint32
+}
+
+func writeHeader(buf *bytes.Buffer, version Version, t packetType) {
+  binary.Write(buf, binary.LittleEndian, header{magic, uint16(version), uint16(t), 0})
+}
+
+func readHeader(r *bytes.Reader) (header, error) {
+  var h header
+
+  if err := binary.Read(r, binary.LittleEndian, &h); err != nil {
+    return h, errors.New("KiNet header is truncated")
+  }
+
+  if h.Magic != magic {
+    return h, errors.New("Not a KiNet packet")
+  }
+
+  return h, nil
+}
+
+// Copy at most a universe of levels after a NULL start code
+func writeLevels(buf *bytes.Buffer, frame dmx.DMXFrame, size int) {
+  buf.WriteByte(0)
+  for i := 0; i < size; i++ {
+    buf.WriteByte(byte(frame[i]))
+  }
+}
+
+func frameSize(frame dmx.DMXFrame) int {
+  if len(frame) > dmx.UniverseSize {
+    return dmx.UniverseSize
+  }
+  return len(frame)
+}
+
+// Build a version 1 DMXOUT packet
+func buildDMXOut(frame dmx.DMXFrame) []byte {
+  buf := bytes.NewBuffer(make([]byte, 0))
+  writeHeader(buf, V1, typeDMXOut)
+
+  // Port, flags, timer
+  buf.Write([]byte{0, 0, 0, 0})
+  binary.Write(buf, binary.LittleEndian, anyUniverse)
+
+  writeLevels(buf, frame, frameSize(frame))
+
+  return buf.Bytes()
+}
+
+// Build a version 2 PORTOUT packet for one output of a supply
+func buildPortOut(frame dmx.DMXFrame, port uint8) []byte {
+  size := frameSize(frame)
+
+  buf := bytes.NewBuffer(make([]byte, 0))
+  writeHeader(buf, V2, typePortOut)
+
+  binary.Write(buf, binary.LittleEndian, anyUniverse)
+  buf.WriteByte(port)
+  // Padding and flags
+  buf.Write([]byte{0, 0, 0})
+  // Length includes the start code
+  binary.Write(buf, binary.LittleEndian, uint16(size + 1))
+  // Second byte of the 16 bit start code field
+  buf.WriteByte(0)
+
+  writeLevels(buf, frame, size)
+
+  return buf.Bytes()
+}
+
+func buildDiscover() []byte {
+  buf := bytes.NewBuffer(make([]byte, 0))
+  writeHeader(buf, V1, typeDiscoverSupplies)
+  return buf.Bytes()
+}
+
+// A power/data supply that answered discovery
+type Supply struct {
+  IP net.IP
+  MAC net.HardwareAddr
+  Serial uint32
+  Description string
+}
+
+func parseDiscoverReply(packet []byte) (*Supply, error) {
+  r := bytes.NewReader(packet)
+  h, err := readHeader(r)
+
+  if err != nil {
+    return nil, err
+  }
+
+  if packetType(h.Type) != typeDiscoverSuppliesReply {
+    return nil, errors.New("Not a KiNet discovery reply")
+  }
+
+  body := make([]byte, r.Len())
+  r.Read(body)
+
+  if len(body) < 14 {
+    return nil, errors.New("KiNet discovery reply is truncated")
+  }
+
+  supply := new(Supply)
+  supply.IP = net.IPv4(body[0], body[1], body[2], body[3])
+  supply.MAC = net.HardwareAddr(append([]byte{}, body[4:10]...))
+  supply.Serial = binary.LittleEndian.Uint32(body[10:14])
+
+  // Description strings are NULL terminated
+  if end := bytes.IndexByte(body[14:], 0); end >= 0 {
+    supply.Description = string(body[14:14 + end])
+  } else {
+    supply.Description = string(body[14:])
+  }
+
+  return supply, nil
+}
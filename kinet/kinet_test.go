@@ -0,0 +1,117 @@
+package kinet
+
+import (
+  "bytes"
+  "encoding/binary"
+  "net"
+  "testing"
+  "time"
+  "golx/dmx"
+)
+
+func TestDMXOutLayout(t *testing.T) {
+  packet := buildDMXOut(dmx.DMXFrame{1, 2, 3})
+
+  expected := []byte{0x04, 0x01, 0xdc, 0x4a, 0x01, 0x00, 0x01, 0x01, 0, 0, 0, 0,
+    0, 0, 0, 0, 0xff, 0xff, 0xff, 0xff, 0, 1, 2, 3}
+
+  if !bytes.Equal(packet, expected) {
+    t.Log("DMXOUT packet is incorrect: ", packet)
+    t.Fail()
+  }
+}
+
+func TestPortOutLayout(t *testing.T) {
+  packet := buildPortOut(dmx.DMXFrame{9, 8}, 3)
+
+  expected := []byte{0x04, 0x01, 0xdc, 0x4a, 0x02, 0x00, 0x08, 0x01, 0, 0, 0, 0,
+    0xff, 0xff, 0xff, 0xff, 3, 0, 0, 0, 3, 0, 0, 0, 9, 8}
+
+  if !bytes.Equal(packet, expected) {
+    t.Log("PORTOUT packet is incorrect: ", packet)
+    t.Fail()
+  }
+}
+
+func listenLocal(t *testing.T) *net.UDPConn {
+  conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+
+  if err != nil {
+    t.Log("Error opening socket: ", err.Error())
+    t.FailNow()
+  }
+
+  conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+  return conn
+}
+
+func TestUniverseSendsFrames(t *testing.T) {
+  supply := listenLocal(t)
+  defer supply.Close()
+
+  universe, err := newKinetUniverse(supply.LocalAddr().(*net.UDPAddr), 2, V2)
+
+  if err != nil {
+    t.Log("Error building universe: ", err.Error())
+    t.FailNow()
+  }
+
+  defer universe.Close()
+
+  universe.Input() <- dmx.DMXFrame{10, 20, 30}
+
+  data := make([]byte, 1500)
+  n, err := supply.Read(data)
+
+  if err != nil {
+    t.Log("Supply did not recieve a packet: ", err.Error())
+    t.FailNow()
+  }
+
+  if !bytes.Equal(data[:n], buildPortOut(dmx.DMXFrame{10, 20, 30}, 2)) {
+    t.Log("Supply recieved the wrong packet: ", data[:n])
+    t.Fail()
+  }
+}
+
+func TestDiscover(t *testing.T) {
+  supply := listenLocal(t)
+  defer supply.Close()
+
+  go func() {
+    data := make([]byte, 1500)
+    n, source, err := supply.ReadFromUDP(data)
+
+    if err != nil || !bytes.Equal(data[:n], buildDiscover()) {
+      return
+    }
+
+    reply := bytes.NewBuffer(make([]byte, 0))
+    writeHeader(reply, V1, typeDiscoverSuppliesReply)
+    reply.Write([]byte{127, 0, 0, 1, 0x00, 0x0a, 0xc5, 1, 2, 3})
+    binary.Write(reply, binary.LittleEndian, uint32(1234))
+    reply.WriteString("PDS-480ca\x00")
+
+    supply.WriteToUDP(reply.Bytes(), source)
+  }()
+
+  supplies, err := discover(supply.LocalAddr().(*net.UDPAddr), 200 * time.Millisecond)
+
+  if err != nil {
+    t.Log("Error discovering supplies: ", err.Error())
+    t.FailNow()
+  }
+
+  if len(supplies) != 1 {
+    t.Log("Found ", len(supplies), " supplies, expected 1")
+    t.FailNow()
+  }
+
+  found := supplies[0]
+
+  if !found.IP.Equal(net.ParseIP("127.0.0.1")) || found.MAC.String() != "00:0a:c5:01:02:03" ||
+    found.Serial != 1234 || found.Description != "PDS-480ca" {
+    t.Log("Supply decoded incorrectly: ", found)
+    t.Fail()
+  }
+}
@@ -0,0 +1,126 @@
+package kinet
+
+import (
+  "fmt"
+  "net"
+  "time"
+  "golx/dmx"
+)
+
+/*
+Sends frames recieved on Input to one output of a KiNet supply. Frames are
+rate limited in the same way as an ArtnetUniverse and the last frame is repeated
+so the supply doesn't time out and go to its default state.
+*/
+type KinetUniverse struct {
+  supply *net.UDPAddr
+  port uint8
+  version Version
+
+  conn *net.UDPConn
+  input chan dmx.DMXFrame
+  stop chan bool
+
+  rateLimit time.Duration
+  keepAlive time.Duration
+}
+
+/*
+Build a universe for the supply at IP address supply. Version 1 supplies only
+have one output so port is ignored, version 2 outputs are numbered from 1.
+*/
+func NewKinetUniverse(supply net.IP, port uint8, version Version) (*KinetUniverse, error) {
+  return newKinetUniverse(&net.UDPAddr{IP: supply, Port: KinetPort}, port, version)
+}
+
+func newKinetUniverse(addr *net.UDPAddr, port uint8, version Version) (*KinetUniverse, error) {
+  conn, err := net.DialUDP("udp", nil, addr)
+
+  if err != nil {
+    return nil, err
+  }
+
+  universe := new(KinetUniverse)
+  universe.supply = addr
+  universe.port = port
+  universe.version = version
+  universe.conn = conn
+  universe.input = make(chan dmx.DMXFrame)
+  universe.stop = make(chan bool)
+
+  universe.rateLimit = 25 * time.Millisecond
+  universe.keepAlive = 1 * time.Second
+
+  go universe.netSend()
+
+  return universe, nil
+}
+
+func (u *KinetUniverse) String() string {
+  return fmt.Sprintf("[KiNet Universe @ %s port %d]", u.supply.IP, u.port)
+}
+
+func (u *KinetUniverse) Input() chan dmx.DMXFrame {
+  return u.input
+}
+
+func (u *KinetUniverse) Supply() net.IP {
+  return u.supply.IP
+}
+
+func (u *KinetUniverse) Port() uint8 {
+  return u.port
+}
+
+// Stop sending and close the connection to the supply
+func (u *KinetUniverse) Close() {
+  u.stop <- true
+}
+
+func (u *KinetUniverse) sendFrame(frame dmx.DMXFrame) {
+  if u.version == V1 {
+    u.conn.Write(buildDMXOut(frame))
+  } else {
+    u.conn.Write(buildPortOut(frame, u.port))
+  }
+}
+
+func (u *KinetUniverse) netSend() {
+  var frame dmx.DMXFrame = nil
+  newData := false
+
+  // Nothing to limit or keep alive until the first frame
+  var rateLimit <-chan time.Time
+  var keepAlive <-chan time.Time
+
+  for {
+    select {
+    case f := <-u.input:
+      // Keep a copy so the sender can reuse the frame
+      frame = make(dmx.DMXFrame, len(f))
+      copy(frame, f)
+
+      if rateLimit == nil {
+        u.sendFrame(frame)
+        rateLimit = time.After(u.rateLimit)
+        keepAlive = time.After(u.keepAlive)
+      } else {
+        newData = true
+      }
+    case _ = <-rateLimit:
+      rateLimit = nil
+      if newData {
+        newData = false
+        u.sendFrame(frame)
+        rateLimit = time.After(u.rateLimit)
+        keepAlive = time.After(u.keepAlive)
+      }
+    case _ = <-keepAlive:
+      u.sendFrame(frame)
+      keepAlive = time.After(u.keepAlive)
+    case _ = <-u.stop:
+      u.conn.Close()
+      return
+    }
+  }
+}
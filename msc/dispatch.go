@@ -0,0 +1,110 @@
+package msc
+
+import (
+  "io"
+  "sync"
+)
+
+// Something GoLX does in response to an MSC message
+type Action func(msg *Message)
+
+/*
+Calls the actions registered for each command addressed to this device.
+
+Messages are accepted if they are sent to the dispatcher's device ID, to the
+all call ID or to a group the device is in, and use one of the accepted command
+formats. The all types command format is always accepted.
+*/
+type Dispatcher struct {
+  lock sync.Mutex
+
+  device uint8
+  groups map[uint8] bool
+  formats map[CommandFormat] bool
+  actions map[Command] []Action
+}
+
+/*
+Build a dispatcher for device ID device that accepts the lighting and moving
+lights command formats
+*/
+func NewDispatcher(device uint8) *Dispatcher {
+  d := new(Dispatcher)
+  d.device = device
+  d.groups = make(map[uint8] bool)
+  d.formats = map[CommandFormat] bool{Lighting: true, MovingLights: true}
+  d.actions = make(map[Command] []Action)
+  return d
+}
+
+// Respond to messages sent to group ID group (0x70 to 0x7E)
+func (d *Dispatcher) JoinGroup(group uint8) {
+  d.lock.Lock()
+  defer d.lock.Unlock()
+  d.groups[group] = true
+}
+
+// Respond to messages with command format format
+func (d *Dispatcher) AcceptFormat(format CommandFormat) {
+  d.lock.Lock()
+  defer d.lock.Unlock()
+  d.formats[format] = true
+}
+
+// Call action for every accepted message with the command cmd
+func (d *Dispatcher) Register(cmd Command, action Action) {
+  d.lock.Lock()
+  defer d.lock.Unlock()
+  d.actions[cmd] = append(d.actions[cmd], action)
+}
+
+func (d *Dispatcher) accepts(msg *Message) bool {
+  deviceOK := msg.Device == d.device || msg.Device == AllCall || d.groups[msg.Device]
+  formatOK := msg.Format == AllTypes || d.formats[msg.Format]
+  return deviceOK && formatOK
+}
+
+/*
+Run the actions for a message. Returns true if the message was addressed to the
+dispatcher.
+*/
+func (d *Dispatcher) Dispatch(msg *Message) bool {
+  d.lock.Lock()
+  accepted := d.accepts(msg)
+  actions := d.actions[msg.Command]
+  d.lock.Unlock()
+
+  if !accepted {
+    return false
+  }
+
+  for _, action := range actions {
+    action(msg)
+  }
+
+  return true
+}
+
+/*
+Dispatch every message read from r until it ends. Malformed MSC messages are
+skipped. Returns nil when r reaches EOF, otherwise the error that stopped it.
+*/
+func (d *Dispatcher) Run(r io.Reader) error {
+  reader := NewReader(r)
+
+  for {
+    data, err := reader.readSysEx()
+
+    if err == io.EOF {
+      return nil
+    } else if err != nil {
+      return err
+    }
+
+    msg, err := Decode(data)
+
+    if err == nil {
+      d.Dispatch(msg)
+    }
+  }
+}
@@ -0,0 +1,269 @@
+/*
+MIDI Show Control
+
+Decodes and encodes MSC System Exclusive messages:
+
+  F0 7F <device> 02 <command format> <command> <data> F7
+
+Cue numbers, lists and paths are kept as the ASCII strings used on the wire
+(e.g. "12.5") so they can be matched against GoLX cues without loss.
+*/
+package msc
+
+import (
+  "bytes"
+  "errors"
+  "fmt"
+)
+
+type Command uint8
+
+const (
+  Go Command = 0x01
+  Stop Command = 0x02
+  Resume Command = 0x03
+  TimedGo Command = 0x04
+  Load Command = 0x05
+  Set Command = 0x06
+  Fire Command = 0x07
+  AllOff Command = 0x08
+  Restore Command = 0x09
+  Reset Command = 0x0A
+  GoOff Command = 0x0B
+)
+
+type CommandFormat uint8
+
+const (
+  Lighting CommandFormat = 0x01
+  MovingLights CommandFormat = 0x02
+  ColorChangers CommandFormat = 0x03
+  Strobes CommandFormat = 0x04
+  AllTypes CommandFormat = 0x7F
+)
+
+const (
+  // Device ID that every device responds to
+  AllCall uint8 = 0x7F
+
+  sysExStart byte = 0xF0
+  sysExEnd byte = 0xF7
+  universalRealTime byte = 0x7F
+  mscSubID byte = 0x02
+)
+
+var ErrNotMSC = errors.New("SysEx message is not MIDI Show Control")
+
+func (cmd Command) String() string {
+  switch cmd {
+  case Go:
+    return "GO"
+  case Stop:
+    return "STOP"
+  case Resume:
+    return "RESUME"
+  case TimedGo:
+    return "TIMED_GO"
+  case Load:
+    return "LOAD"
+  case Set:
+    return "SET"
+  case Fire:
+    return "FIRE"
+  case AllOff:
+    return "ALL_OFF"
+  case Restore:
+    return "RESTORE"
+  case Reset:
+    return "RESET"
+  case GoOff:
+    return "GO_OFF"
+  }
+  return fmt.Sprintf("0x%02X", uint8(cmd))
+}
+
+/*
+SMPTE style time used by TIMED_GO and SET. Rate holds the two frame rate bits
+from the hours byte (0 = 24fps, 1 = 25fps, 2 = 30fps drop frame, 3 = 30fps).
+*/
+type Timecode struct {
+  Rate uint8
+  Hours uint8
+  Minutes uint8
+  Seconds uint8
+  Frames uint8
+  Fraction uint8
+}
+
+type Message struct {
+  Device uint8
+  Format CommandFormat
+  Command Command
+
+  // Cue commands
+  Cue string
+  List string
+  Path string
+
+  // TIMED_GO and SET
+  Time *Timecode
+
+  // SET
+  Control uint16
+  Value uint16
+
+  // FIRE
+  Macro uint8
+}
+
+func (msg *Message) String() string {
+  return fmt.Sprintf("<MSC %s Q%s L%s P%s>", msg.Command, msg.Cue, msg.List, msg.Path)
+}
+
+/*
+Decode a complete SysEx message from F0 to F7 inclusive. Returns ErrNotMSC for
+valid SysEx messages that aren't MSC.
+*/
+func Decode(data []byte) (*Message, error) {
+  if len(data) < 2 || data[0] != sysExStart || data[len(data) - 1] != sysExEnd {
+    return nil, errors.New("Data is not a SysEx message")
+  }
+
+  if len(data) < 7 || data[1] != universalRealTime || data[3] != mscSubID {
+    return nil, ErrNotMSC
+  }
+
+  msg := new(Message)
+  msg.Device = data[2]
+  msg.Format = CommandFormat(data[4])
+  msg.Command = Command(data[5])
+
+  body := data[6:len(data) - 1]
+
+  switch msg.Command {
+  case Go, Stop, Resume, Load, GoOff:
+    msg.Cue, msg.List, msg.Path = decodeCue(body)
+  case TimedGo:
+    if len(body) < 5 {
+      return nil, errors.New("TIMED_GO is missing its time")
+    }
+    msg.Time = decodeTime(body[:5])
+    msg.Cue, msg.List, msg.Path = decodeCue(body[5:])
+  case Set:
+    if len(body) < 4 {
+      return nil, errors.New("SET is missing its control number or value")
+    }
+    msg.Control = uint16(body[0]) | uint16(body[1]) << 7
+    msg.Value = uint16(body[2]) | uint16(body[3]) << 7
+    if len(body) >= 9 {
+      msg.Time = decodeTime(body[4:9])
+    }
+  case Fire:
+    if len(body) < 1 {
+      return nil, errors.New("FIRE is missing its macro number")
+    }
+    msg.Macro = body[0]
+  }
+
+  return msg, nil
+}
+
+// Split cue data into the NULL separated number, list and path
+func decodeCue(data []byte) (string, string, string) {
+  fields := bytes.SplitN(data, []byte{0}, 3)
+  parts := make([]string, 3)
+
+  for i, field := range fields {
+    parts[i] = string(field)
+  }
+
+  return parts[0], parts[1], parts[2]
+}
+
+func decodeTime(data []byte) *Timecode {
+  tc := new(Timecode)
+  tc.Rate = (data[0] >> 5) & 0x03
+  tc.Hours = data[0] & 0x1F
+  tc.Minutes = data[1]
+  tc.Seconds = data[2]
+  tc.Frames = data[3] & 0x1F
+  tc.Fraction = data[4]
+  return tc
+}
+
+func (tc *Timecode) encode() []byte {
+  return []byte{(tc.Rate & 0x03) << 5 | (tc.Hours & 0x1F), tc.Minutes & 0x7F,
+    tc.Seconds & 0x7F, tc.Frames & 0x1F, tc.Fraction & 0x7F}
+}
+
+// Cue fields may only contain digits and a decimal point
+func validCueField(field string) bool {
+  for _, c := range field {
+    if (c < '0' || c > '9') && c != '.' {
+      return false
+    }
+  }
+  return true
+}
+
+func encodeCue(buf *bytes.Buffer, cue, list, path string) error {
+  if !validCueField(cue) || !validCueField(list) || !validCueField(path) {
+    return errors.New("Cue numbers, lists and paths may only contain digits and '.'")
+  }
+
+  if (list != "" || path != "") && cue == "" {
+    return errors.New("A cue list or path requires a cue number")
+  }
+
+  buf.WriteString(cue)
+
+  if list != "" || path != "" {
+    buf.WriteByte(0)
+    buf.WriteString(list)
+  }
+
+  if path != "" {
+    buf.WriteByte(0)
+    buf.WriteString(path)
+  }
+
+  return nil
+}
+
+// Encode the message as a complete SysEx message
+func (msg *Message) Encode() ([]byte, error) {
+  buf := bytes.NewBuffer(make([]byte, 0))
+
+  buf.Write([]byte{sysExStart, universalRealTime, msg.Device & 0x7F, mscSubID,
+    byte(msg.Format) & 0x7F, byte(msg.Command) & 0x7F})
+
+  switch msg.Command {
+  case Go, Stop, Resume, Load, GoOff:
+    if err := encodeCue(buf, msg.Cue, msg.List, msg.Path); err != nil {
+      return nil, err
+    }
+  case TimedGo:
+    if msg.Time == nil {
+      return nil, errors.New("TIMED_GO requires a time")
+    }
+    buf.Write(msg.Time.encode())
+    if err := encodeCue(buf, msg.Cue, msg.List, msg.Path); err != nil {
+      return nil, err
+    }
+  case Set:
+    if msg.Control > 0x3FFF || msg.Value > 0x3FFF {
+      return nil, errors.New("SET control numbers and values are 14 bit")
+    }
+    buf.Write([]byte{byte(msg.Control & 0x7F), byte(msg.Control >> 7),
+      byte(msg.Value & 0x7F), byte(msg.Value >> 7)})
+    if msg.Time != nil {
+      buf.Write(msg.Time.encode())
+    }
+  case Fire:
+    buf.WriteByte(msg.Macro & 0x7F)
+  }
+
+  buf.WriteByte(sysExEnd)
+
+  return buf.Bytes(), nil
+}
@@ -0,0 +1,158 @@
+package msc
+
+import (
+  "bytes"
+  "io"
+  "os"
+  "testing"
+)
+
+func readRecording(t *testing.T) []byte {
+  data, err := os.ReadFile("testdata/show.syx")
+
+  if err != nil {
+    t.Log("Error reading recording: ", err.Error())
+    t.FailNow()
+  }
+
+  return data
+}
+
+func TestReaderSkipsOtherMIDIData(t *testing.T) {
+  reader := NewReader(bytes.NewReader(readRecording(t)))
+
+  expected := []Command{Go, TimedGo, Set, Go, Fire, Resume, AllOff}
+
+  for i, cmd := range expected {
+    msg, err := reader.ReadMessage()
+
+    if err != nil {
+      t.Log("Error reading message ", i, ": ", err.Error())
+      t.FailNow()
+    }
+
+    if msg.Command != cmd {
+      t.Log("Message ", i, " was ", msg.Command, " expected ", cmd)
+      t.Fail()
+    }
+  }
+
+  if _, err := reader.ReadMessage(); err != io.EOF {
+    t.Log("Expected EOF at the end of the recording, got ", err)
+    t.Fail()
+  }
+}
+
+func TestDecodeRecordedMessages(t *testing.T) {
+  reader := NewReader(bytes.NewReader(readRecording(t)))
+
+  msg, _ := reader.ReadMessage()
+  if msg.Device != 1 || msg.Format != Lighting || msg.Cue != "1" || msg.List != "2" || msg.Path != "" {
+    t.Log("GO decoded incorrectly: ", msg)
+    t.Fail()
+  }
+
+  msg, _ = reader.ReadMessage()
+  tc := msg.Time
+  if msg.Device != AllCall || msg.Cue != "10.5" || tc == nil || tc.Rate != 1 ||
+    tc.Hours != 1 || tc.Minutes != 2 || tc.Seconds != 3 || tc.Frames != 4 {
+    t.Log("TIMED_GO decoded incorrectly: ", msg, tc)
+    t.Fail()
+  }
+
+  msg, _ = reader.ReadMessage()
+  if msg.Control != 133 || msg.Value != 127 {
+    t.Log("SET decoded incorrectly: ", msg.Control, msg.Value)
+    t.Fail()
+  }
+
+  reader.ReadMessage()
+
+  msg, _ = reader.ReadMessage()
+  if msg.Macro != 12 {
+    t.Log("FIRE decoded incorrectly: ", msg.Macro)
+    t.Fail()
+  }
+}
+
+func TestDispatcher(t *testing.T) {
+  d := NewDispatcher(1)
+  d.JoinGroup(0x70)
+
+  cues := make([]string, 0)
+  offs := 0
+
+  d.Register(Go, func(msg *Message) { cues = append(cues, msg.Cue) })
+  d.Register(TimedGo, func(msg *Message) { cues = append(cues, msg.Cue) })
+  d.Register(AllOff, func(msg *Message) { offs++ })
+
+  err := d.Run(bytes.NewReader(readRecording(t)))
+
+  if err != nil {
+    t.Log("Error running dispatcher: ", err.Error())
+    t.FailNow()
+  }
+
+  // The GO for device 5 is ignored
+  if len(cues) != 2 || cues[0] != "1" || cues[1] != "10.5" {
+    t.Log("Dispatched the wrong cues: ", cues)
+    t.Fail()
+  }
+
+  if offs != 1 {
+    t.Log("ALL_OFF for the group was not dispatched")
+    t.Fail()
+  }
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+  buf := bytes.NewBuffer(make([]byte, 0))
+  writer := NewWriter(buf, 0x10, Lighting)
+
+  writer.Go("42", "3", "")
+  writer.TimedGo(Timecode{Rate: 3, Hours: 2, Seconds: 30}, "7.25", "", "")
+  writer.Set(1000, 0x3FFF)
+  writer.Fire(100)
+  writer.AllOff()
+
+  if err := writer.Go("1a", "", ""); err == nil {
+    t.Log("Invalid cue number was encoded")
+    t.Fail()
+  }
+
+  reader := NewReader(buf)
+  messages := make([]*Message, 0)
+
+  for msg, err := reader.ReadMessage(); err == nil; msg, err = reader.ReadMessage() {
+    if msg.Device != 0x10 || msg.Format != Lighting {
+      t.Log("Message has the wrong device or format: ", msg)
+      t.Fail()
+    }
+    messages = append(messages, msg)
+  }
+
+  if len(messages) != 5 {
+    t.Log("Read ", len(messages), " messages, expected 5")
+    t.FailNow()
+  }
+
+  if messages[0].Cue != "42" || messages[0].List != "3" {
+    t.Log("GO round trip failed: ", messages[0])
+    t.Fail()
+  }
+
+  if messages[1].Cue != "7.25" || messages[1].Time.Rate != 3 || messages[1].Time.Seconds != 30 {
+    t.Log("TIMED_GO round trip failed: ", messages[1])
+    t.Fail()
+  }
+
+  if messages[2].Control != 1000 || messages[2].Value != 0x3FFF {
+    t.Log("SET round trip failed: ", messages[2])
+    t.Fail()
+  }
+
+  if messages[3].Macro != 100 || messages[4].Command != AllOff {
+    t.Log("FIRE or ALL_OFF round trip failed")
+    t.Fail()
+  }
+}
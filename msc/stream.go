@@ -0,0 +1,143 @@
+/*
+Reading and writing MSC on byte streams
+
+The transport is left to the caller so the same code handles RTP-MIDI sessions,
+serial MIDI interfaces and recorded files.
+*/
+package msc
+
+import (
+  "bufio"
+  "io"
+)
+
+type Reader struct {
+  r *bufio.Reader
+}
+
+func NewReader(r io.Reader) *Reader {
+  reader := new(Reader)
+  reader.r = bufio.NewReader(r)
+  return reader
+}
+
+/*
+Read the next MSC message from the stream. Other MIDI data, including SysEx
+messages that aren't MSC, is skipped. Real time messages (clock etc.) may be
+interleaved with the SysEx data as allowed by the MIDI specification.
+
+Malformed MSC messages are returned as errors without losing the rest of the
+stream so the caller can log them and carry on reading.
+*/
+func (reader *Reader) ReadMessage() (*Message, error) {
+  for {
+    data, err := reader.readSysEx()
+
+    if err != nil {
+      return nil, err
+    }
+
+    msg, err := Decode(data)
+
+    if err == ErrNotMSC {
+      continue
+    }
+
+    return msg, err
+  }
+}
+
+// Read the next complete SysEx message
+func (reader *Reader) readSysEx() ([]byte, error) {
+  var data []byte = nil
+
+  for {
+    b, err := reader.r.ReadByte()
+
+    if err != nil {
+      if err == io.EOF && data != nil {
+        err = io.ErrUnexpectedEOF
+      }
+      return nil, err
+    }
+
+    switch {
+    case b == sysExStart:
+      // Also restarts if the previous message was never terminated
+      data = []byte{b}
+    case data == nil:
+      // Outside SysEx
+    case b >= 0xF8:
+      // Real time messages can appear anywhere
+    case b == sysExEnd:
+      return append(data, b), nil
+    case b >= 0x80:
+      // Any other status byte aborts the SysEx
+      data = nil
+    default:
+      data = append(data, b)
+    }
+  }
+}
+
+/*
+Sends MSC messages to a byte stream so that GoLX can act as the show control
+master.
+*/
+type Writer struct {
+  w io.Writer
+  device uint8
+  format CommandFormat
+}
+
+func NewWriter(w io.Writer, device uint8, format CommandFormat) *Writer {
+  writer := new(Writer)
+  writer.w = w
+  writer.device = device
+  writer.format = format
+  return writer
+}
+
+// Send a message, filling in the writer's device and command format
+func (writer *Writer) WriteMessage(msg *Message) error {
+  msg.Device = writer.device
+  msg.Format = writer.format
+
+  data, err := msg.Encode()
+
+  if err != nil {
+    return err
+  }
+
+  _, err = writer.w.Write(data)
+
+  return err
+}
+
+func (writer *Writer) Go(cue, list, path string) error {
+  return writer.WriteMessage(&Message{Command: Go, Cue: cue, List: list, Path: path})
+}
+
+func (writer *Writer) Stop(cue, list, path string) error {
+  return writer.WriteMessage(&Message{Command: Stop, Cue: cue, List: list, Path: path})
+}
+
+func (writer *Writer) Resume(cue, list, path string) error {
+  return writer.WriteMessage(&Message{Command: Resume, Cue: cue, List: list, Path: path})
+}
+
+func (writer *Writer) TimedGo(time Timecode, cue, list, path string) error {
+  return writer.WriteMessage(&Message{Command: TimedGo, Time: &time, Cue: cue, List: list, Path: path})
+}
+
+func (writer *Writer) Set(control, value uint16) error {
+  return writer.WriteMessage(&Message{Command: Set, Control: control, Value: value})
+}
+
+func (writer *Writer) Fire(macro uint8) error {
+  return writer.WriteMessage(&Message{Command: Fire, Macro: macro})
+}
+
+func (writer *Writer) AllOff() error {
+  return writer.WriteMessage(&Message{Command: AllOff})
+}
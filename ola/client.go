@@ -0,0 +1,303 @@
+/*
+Open Lighting Architecture client
+
+Sends and recieves DMX through olad so that GoLX can use any of the devices OLA
+supports. Each OLA universe is represented by an OlaUniverse which can be
+patched to a dmx.DMXUniverse in the same way as an ArtnetUniverse. The client
+reconnects automatically if olad is restarted and resends the last frame of
+each universe when it does.
+*/
+package ola
+
+import (
+  "fmt"
+  "net"
+  "time"
+  "golx/dmx"
+  "golx/patch/chanutil"
+)
+
+// Time to wait between connection attempts
+var reconnectDelay time.Duration = 1 * time.Second
+
+type Client struct {
+  address string
+
+  sends chan outFrame
+  requests chan universeRequest
+  done chan bool
+}
+
+type OlaUniverse struct {
+  client *Client
+  universe int
+
+  input chan dmx.DMXFrame
+  output chan dmx.DMXFrame
+  recv chan dmx.DMXFrame
+}
+
+type outFrame struct {
+  universe int
+  frame dmx.DMXFrame
+}
+
+type universeRequest struct {
+  universe int
+  reply chan *OlaUniverse
+}
+
+// A single connection to olad
+type connection struct {
+  conn net.Conn
+  incoming chan *rpcMessage
+  closed chan bool
+}
+
+/*
+Build a client for the olad RPC server at address, normally DefaultAddress.
+The client connects in the background and keeps trying until it is closed.
+*/
+func NewClient(address string) *Client {
+  c := new(Client)
+  c.address = address
+  c.sends = make(chan outFrame)
+  c.requests = make(chan universeRequest)
+  c.done = make(chan bool)
+
+  go c.run()
+
+  return c
+}
+
+/*
+Get the OlaUniverse for an OLA universe number. The client registers to
+recieve DMX for every universe it is asked for.
+*/
+func (c *Client) Universe(universe int) *OlaUniverse {
+  req := universeRequest{universe, make(chan *OlaUniverse)}
+
+  select {
+  case c.requests <- req:
+    return <-req.reply
+  case _ = <-c.done:
+    return nil
+  }
+}
+
+// Disconnect from olad and stop reconnecting
+func (c *Client) Close() {
+  close(c.done)
+}
+
+func newOlaUniverse(client *Client, universe int) *OlaUniverse {
+  u := new(OlaUniverse)
+  u.client = client
+  u.universe = universe
+  u.input = make(chan dmx.DMXFrame)
+  u.output = make(chan dmx.DMXFrame)
+  u.recv = make(chan dmx.DMXFrame)
+
+  chanutil.DeliverWhenPossible(u.recv, u.output)
+
+  go u.send()
+
+  return u
+}
+
+func (u *OlaUniverse) String() string {
+  return fmt.Sprintf("[OLA Universe %d]", u.universe)
+}
+
+// The OLA universe number
+func (u *OlaUniverse) Universe() int {
+  return u.universe
+}
+
+// Frames sent here are sent to the OLA universe
+func (u *OlaUniverse) Input() chan dmx.DMXFrame {
+  return u.input
+}
+
+// Frames OLA recieves on the universe
+func (u *OlaUniverse) Output() chan dmx.DMXFrame {
+  return u.output
+}
+
+func (u *OlaUniverse) send() {
+  for frame := range u.input {
+    // The frame may be changed by the sender once it has been sent
+    out := make(dmx.DMXFrame, len(frame))
+    copy(out, frame)
+
+    select {
+    case u.client.sends <- outFrame{u.universe, out}:
+    case _ = <-u.client.done:
+      return
+    }
+  }
+}
+
+// Map a frame onto the OLA DMX buffer, which holds at most one universe
+func frameToBuffer(frame dmx.DMXFrame) []byte {
+  size := len(frame)
+  if size > dmx.UniverseSize {
+    size = dmx.UniverseSize
+  }
+
+  buf := make([]byte, size)
+  for i := 0; i < size; i++ {
+    buf[i] = byte(frame[i])
+  }
+
+  return buf
+}
+
+func bufferToFrame(buf []byte) dmx.DMXFrame {
+  frame := make(dmx.DMXFrame, len(buf))
+  for i, b := range buf {
+    frame[i] = dmx.DMXValue(b)
+  }
+  return frame
+}
+
+func (c *Client) connect() *connection {
+  conn, err := net.DialTimeout("tcp", c.address, reconnectDelay)
+
+  if err != nil {
+    return nil
+  }
+
+  cn := &connection{conn, make(chan *rpcMessage), make(chan bool)}
+
+  go func() {
+    for {
+      msg, err := readMessage(conn)
+
+      if err != nil {
+        close(cn.incoming)
+        return
+      }
+
+      select {
+      case cn.incoming <- msg:
+      case _ = <-cn.closed:
+        return
+      }
+    }
+  }()
+
+  return cn
+}
+
+func (cn *connection) close() {
+  close(cn.closed)
+  cn.conn.Close()
+}
+
+// Owns the connection and the universes
+func (c *Client) run() {
+  universes := make(map[int] *OlaUniverse)
+  last := make(map[int] dmx.DMXFrame)
+
+  var cn *connection = nil
+  var incoming chan *rpcMessage = nil
+  retry := time.After(0)
+  nextID := uint32(1)
+
+  disconnect := func() {
+    cn.close()
+    cn = nil
+    incoming = nil
+    retry = time.After(reconnectDelay)
+  }
+
+  write := func(msg *rpcMessage) {
+    if cn == nil {
+      return
+    }
+    if writeMessage(cn.conn, msg) != nil {
+      disconnect()
+    }
+  }
+
+  request := func(msgType rpcType, name string, buffer []byte) {
+    write(&rpcMessage{msgType, nextID, name, buffer})
+    nextID++
+  }
+
+  stream := func(universe int, frame dmx.DMXFrame) {
+    data := dmxData{universe: universe, data: frameToBuffer(frame)}
+    request(rpcStreamRequest, methodStreamDmxData, data.encode())
+  }
+
+  for {
+    select {
+    case _ = <-retry:
+      retry = nil
+      cn = c.connect()
+
+      if cn == nil {
+        retry = time.After(reconnectDelay)
+        continue
+      }
+
+      incoming = cn.incoming
+
+      for universe := range universes {
+        request(rpcRequest, methodRegisterForDmx, encodeRegister(universe, true))
+      }
+
+      for universe, frame := range last {
+        stream(universe, frame)
+      }
+    case out := <-c.sends:
+      last[out.universe] = out.frame
+      stream(out.universe, out.frame)
+    case req := <-c.requests:
+      u, exists := universes[req.universe]
+
+      if !exists {
+        u = newOlaUniverse(c, req.universe)
+        universes[req.universe] = u
+        request(rpcRequest, methodRegisterForDmx, encodeRegister(req.universe, true))
+      }
+
+      req.reply <- u
+    case msg, ok := <-incoming:
+      if !ok {
+        disconnect()
+        continue
+      }
+
+      // Responses to our requests carry nothing we need
+      if msg.msgType != rpcRequest {
+        continue
+      }
+
+      if msg.name != methodUpdateDmxData {
+        write(&rpcMessage{msgType: rpcResponseNotImplemented, id: msg.id})
+        continue
+      }
+
+      data, err := decodeDmxData(msg.buffer)
+
+      if err != nil {
+        write(&rpcMessage{msgType: rpcResponseFailed, id: msg.id})
+        continue
+      }
+
+      if u, exists := universes[data.universe]; exists {
+        u.recv <- bufferToFrame(data.data)
+      }
+
+      // Acknowledge with an empty Ack message
+      write(&rpcMessage{msgType: rpcResponse, id: msg.id, buffer: []byte{}})
+    case _ = <-c.done:
+      if cn != nil {
+        cn.close()
+      }
+      return
+    }
+  }
+}
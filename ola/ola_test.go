@@ -0,0 +1,176 @@
+package ola
+
+import (
+  "net"
+  "testing"
+  "time"
+  "golx/dmx"
+)
+
+// Stands in for olad, passing every message it recieves to the test
+type fakeServer struct {
+  listener net.Listener
+  conns chan net.Conn
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+  listener, err := net.Listen("tcp", "127.0.0.1:0")
+
+  if err != nil {
+    t.Log("Error starting fake OLA server: ", err.Error())
+    t.FailNow()
+  }
+
+  server := &fakeServer{listener, make(chan net.Conn)}
+
+  go func() {
+    for {
+      conn, err := listener.Accept()
+      if err != nil {
+        return
+      }
+      server.conns <- conn
+    }
+  }()
+
+  return server
+}
+
+func (server *fakeServer) accept(t *testing.T) net.Conn {
+  select {
+  case conn := <-server.conns:
+    conn.SetDeadline(time.Now().Add(2 * time.Second))
+    return conn
+  case _ = <-time.After(2 * time.Second):
+    t.Log("Client did not connect")
+    t.FailNow()
+  }
+  return nil
+}
+
+// Read messages until one calls method, answering requests as olad would
+func expectCall(t *testing.T, conn net.Conn, method string) *rpcMessage {
+  for {
+    msg, err := readMessage(conn)
+
+    if err != nil {
+      t.Log("Error waiting for ", method, ": ", err.Error())
+      t.FailNow()
+    }
+
+    if msg.msgType == rpcRequest {
+      writeMessage(conn, &rpcMessage{msgType: rpcResponse, id: msg.id, buffer: []byte{}})
+    }
+
+    if msg.name == method {
+      return msg
+    }
+  }
+}
+
+func TestProtobufRoundTrip(t *testing.T) {
+  data := dmxData{universe: 3, data: []byte{1, 2, 255}, priority: 150}
+  msg := rpcMessage{rpcStreamRequest, 300, methodStreamDmxData, data.encode()}
+
+  decoded, err := decodeRpcMessage(msg.encode())
+
+  if err != nil {
+    t.Log("Error decoding RpcMessage: ", err.Error())
+    t.FailNow()
+  }
+
+  if decoded.msgType != rpcStreamRequest || decoded.id != 300 || decoded.name != methodStreamDmxData {
+    t.Log("RpcMessage decoded incorrectly: ", decoded)
+    t.FailNow()
+  }
+
+  decodedData, err := decodeDmxData(decoded.buffer)
+
+  if err != nil || decodedData.universe != 3 || decodedData.priority != 150 ||
+    len(decodedData.data) != 3 || decodedData.data[2] != 255 {
+    t.Log("DmxData decoded incorrectly: ", decodedData, err)
+    t.Fail()
+  }
+}
+
+func TestClientSendsAndRecieves(t *testing.T) {
+  server := newFakeServer(t)
+  defer server.listener.Close()
+
+  client := NewClient(server.listener.Addr().String())
+  defer client.Close()
+
+  conn := server.accept(t)
+  defer conn.Close()
+
+  universe := client.Universe(2)
+
+  register := expectCall(t, conn, methodRegisterForDmx)
+  if register.msgType != rpcRequest {
+    t.Log("RegisterForDmx was not sent as a request")
+    t.Fail()
+  }
+
+  universe.Input() <- dmx.DMXFrame{10, 20, 30}
+
+  msg := expectCall(t, conn, methodStreamDmxData)
+  data, _ := decodeDmxData(msg.buffer)
+
+  if data.universe != 2 || len(data.data) != 3 || data.data[1] != 20 {
+    t.Log("Frame was sent incorrectly: ", data)
+    t.Fail()
+  }
+
+  // olad pushes DMX recieved on the universe to the client
+  update := dmxData{universe: 2, data: []byte{7, 8}}
+  writeMessage(conn, &rpcMessage{rpcRequest, 9, methodUpdateDmxData, update.encode()})
+
+  select {
+  case frame := <-universe.Output():
+    if len(frame) != 2 || frame[0] != 7 || frame[1] != 8 {
+      t.Log("Recieved frame is incorrect: ", frame)
+      t.Fail()
+    }
+  case _ = <-time.After(2 * time.Second):
+    t.Log("Recieved frame was not delivered")
+    t.FailNow()
+  }
+
+  response, err := readMessage(conn)
+
+  if err != nil || response.msgType != rpcResponse || response.id != 9 {
+    t.Log("UpdateDmxData was not acknowledged: ", response, err)
+    t.Fail()
+  }
+}
+
+func TestClientReconnects(t *testing.T) {
+  reconnectDelay = 10 * time.Millisecond
+
+  server := newFakeServer(t)
+  defer server.listener.Close()
+
+  client := NewClient(server.listener.Addr().String())
+  defer client.Close()
+
+  conn := server.accept(t)
+  universe := client.Universe(1)
+  universe.Input() <- dmx.DMXFrame{42}
+  expectCall(t, conn, methodStreamDmxData)
+
+  // Simulate olad restarting
+  conn.Close()
+
+  conn = server.accept(t)
+  defer conn.Close()
+
+  // The client registers again and resends the last frame
+  expectCall(t, conn, methodRegisterForDmx)
+  msg := expectCall(t, conn, methodStreamDmxData)
+  data, _ := decodeDmxData(msg.buffer)
+
+  if data.universe != 1 || len(data.data) != 1 || data.data[0] != 42 {
+    t.Log("Last frame was not resent after reconnecting: ", data)
+    t.Fail()
+  }
+}
@@ -0,0 +1,276 @@
+/*
+OLA RPC protocol
+
+olad talks to its clients over TCP using protocol buffers. Each message is an
+RpcMessage preceded by a 4 byte little endian header holding the protocol
+version in the top 4 bits and the message size in the rest. Only the handful
+of messages GoLX needs are implemented, so the protobuf encoding is done by hand
+rather than depending on generated code.
+*/
+package ola
+
+import (
+  "encoding/binary"
+  "errors"
+  "io"
+)
+
+const (
+  DefaultAddress string = "localhost:9010"
+
+  protocolVersion uint32 = 1
+  versionMask uint32 = 0xF0000000
+  sizeMask uint32 = 0x0FFFFFFF
+
+  // Larger than any message olad sends, but stops a corrupt header eating memory
+  maxMessageSize uint32 = 1 << 20
+)
+
+type rpcType uint64
+
+const (
+  rpcRequest rpcType = 1
+  rpcResponse rpcType = 2
+  rpcResponseCancel rpcType = 3
+  rpcResponseFailed rpcType = 4
+  rpcResponseNotImplemented rpcType = 5
+  rpcDisconnect rpcType = 6
+  rpcStreamRequest rpcType = 10
+)
+
+// RPC method names
+const (
+  methodUpdateDmxData string = "UpdateDmxData"
+  methodStreamDmxData string = "StreamDmxData"
+  methodRegisterForDmx string = "RegisterForDmx"
+)
+
+const (
+  registerAction uint64 = 1
+  unregisterAction uint64 = 2
+)
+
+type rpcMessage struct {
+  msgType rpcType
+  id uint32
+  name string
+  buffer []byte
+}
+
+type dmxData struct {
+  universe int
+  data []byte
+  priority int
+}
+
+// Protobuf wire types
+const (
+  wireVarint uint64 = 0
+  wireFixed64 uint64 = 1
+  wireBytes uint64 = 2
+  wireFixed32 uint64 = 5
+)
+
+func appendVarint(buf []byte, v uint64) []byte {
+  for v >= 0x80 {
+    buf = append(buf, byte(v) | 0x80)
+    v >>= 7
+  }
+  return append(buf, byte(v))
+}
+
+func appendVarintField(buf []byte, field int, v uint64) []byte {
+  buf = appendVarint(buf, uint64(field) << 3 | wireVarint)
+  return appendVarint(buf, v)
+}
+
+func appendBytesField(buf []byte, field int, b []byte) []byte {
+  buf = appendVarint(buf, uint64(field) << 3 | wireBytes)
+  buf = appendVarint(buf, uint64(len(b)))
+  return append(buf, b...)
+}
+
+// Protobuf int32 fields are sign extended to 64 bits
+func int32Varint(v int) uint64 {
+  return uint64(int64(int32(v)))
+}
+
+func readVarint(data []byte) (uint64, []byte, error) {
+  var v uint64
+
+  for shift := uint(0); shift < 64; shift += 7 {
+    if len(data) == 0 {
+      return 0, nil, errors.New("Protobuf varint is truncated")
+    }
+
+    b := data[0]
+    data = data[1:]
+    v |= uint64(b & 0x7F) << shift
+
+    if b < 0x80 {
+      return v, data, nil
+    }
+  }
+
+  return 0, nil, errors.New("Protobuf varint is too long")
+}
+
+/*
+Call handle for each field in a protobuf message. Varint fields are passed as
+v, length delimited fields as b. Fixed size fields are skipped.
+*/
+func readFields(data []byte, handle func(field int, v uint64, b []byte)) error {
+  for len(data) > 0 {
+    key, rest, err := readVarint(data)
+
+    if err != nil {
+      return err
+    }
+
+    data = rest
+    field := int(key >> 3)
+
+    switch key & 0x7 {
+    case wireVarint:
+      v, rest, err := readVarint(data)
+      if err != nil {
+        return err
+      }
+      data = rest
+      handle(field, v, nil)
+    case wireBytes:
+      size, rest, err := readVarint(data)
+      if err != nil {
+        return err
+      }
+      if uint64(len(rest)) < size {
+        return errors.New("Protobuf field is truncated")
+      }
+      handle(field, 0, rest[:size])
+      data = rest[size:]
+    case wireFixed64:
+      if len(data) < 8 {
+        return errors.New("Protobuf field is truncated")
+      }
+      data = data[8:]
+    case wireFixed32:
+      if len(data) < 4 {
+        return errors.New("Protobuf field is truncated")
+      }
+      data = data[4:]
+    default:
+      return errors.New("Unsupported protobuf wire type")
+    }
+  }
+
+  return nil
+}
+
+func (msg *rpcMessage) encode() []byte {
+  buf := make([]byte, 0, len(msg.buffer) + len(msg.name) + 16)
+  buf = appendVarintField(buf, 1, uint64(msg.msgType))
+  buf = appendVarintField(buf, 2, uint64(msg.id))
+  if msg.name != "" {
+    buf = appendBytesField(buf, 3, []byte(msg.name))
+  }
+  if msg.buffer != nil {
+    buf = appendBytesField(buf, 4, msg.buffer)
+  }
+  return buf
+}
+
+func decodeRpcMessage(data []byte) (*rpcMessage, error) {
+  msg := new(rpcMessage)
+
+  err := readFields(data, func(field int, v uint64, b []byte) {
+    switch field {
+    case 1:
+      msg.msgType = rpcType(v)
+    case 2:
+      msg.id = uint32(v)
+    case 3:
+      msg.name = string(b)
+    case 4:
+      msg.buffer = b
+    }
+  })
+
+  return msg, err
+}
+
+func (d *dmxData) encode() []byte {
+  buf := make([]byte, 0, len(d.data) + 16)
+  buf = appendVarintField(buf, 1, int32Varint(d.universe))
+  buf = appendBytesField(buf, 2, d.data)
+  if d.priority != 0 {
+    buf = appendVarintField(buf, 3, int32Varint(d.priority))
+  }
+  return buf
+}
+
+func decodeDmxData(data []byte) (*dmxData, error) {
+  d := new(dmxData)
+  d.data = []byte{}
+
+  err := readFields(data, func(field int, v uint64, b []byte) {
+    switch field {
+    case 1:
+      d.universe = int(int32(v))
+    case 2:
+      d.data = b
+    case 3:
+      d.priority = int(int32(v))
+    }
+  })
+
+  return d, err
+}
+
+func encodeRegister(universe int, register bool) []byte {
+  action := unregisterAction
+  if register {
+    action = registerAction
+  }
+
+  buf := appendVarintField(make([]byte, 0), 1, int32Varint(universe))
+  return appendVarintField(buf, 2, action)
+}
+
+// Write a message with its header
+func writeMessage(w io.Writer, msg *rpcMessage) error {
+  payload := msg.encode()
+  header := make([]byte, 4)
+  binary.LittleEndian.PutUint32(header, protocolVersion << 28 | uint32(len(payload)) & sizeMask)
+
+  _, err := w.Write(append(header, payload...))
+  return err
+}
+
+// Read the next message and its header
+func readMessage(r io.Reader) (*rpcMessage, error) {
+  header := make([]byte, 4)
+
+  if _, err := io.ReadFull(r, header); err != nil {
+    return nil, err
+  }
+
+  h := binary.LittleEndian.Uint32(header)
+
+  if (h & versionMask) >> 28 != protocolVersion {
+    return nil, errors.New("Unsupported OLA RPC protocol version")
+  }
+
+  size := h & sizeMask
+
+  if size > maxMessageSize {
+    return nil, errors.New("OLA RPC message is too large")
+  }
+
+  payload := make([]byte, size)
+
+  if _, err := io.ReadFull(r, payload); err != nil {
+    return nil, err
+  }
+
+  return decodeRpcMessage(payload)
+}
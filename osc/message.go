@@ -0,0 +1,180 @@
+/*
+Open Sound Control messages
+
+Encodes and decodes the subset of OSC 1.0 used by GoLX: single messages (not
+bundles) with int32, float32, string, blob and boolean arguments.
+*/
+package osc
+
+import (
+  "bytes"
+  "encoding/binary"
+  "errors"
+  "fmt"
+  "math"
+)
+
+type Message struct {
+  Address string
+  Args []interface {}
+}
+
+func NewMessage(address string, args ...interface {}) *Message {
+  msg := new(Message)
+  msg.Address = address
+  msg.Args = args
+  return msg
+}
+
+func (msg *Message) String() string {
+  return fmt.Sprintf("<OSC %s %v>", msg.Address, msg.Args)
+}
+
+// Write a string followed by NULL padding to a multiple of 4 bytes
+func writeString(buf *bytes.Buffer, s string) {
+  buf.WriteString(s)
+  buf.Write(make([]byte, 4 - len(s) % 4))
+}
+
+// Write a blob's size and data padded to a multiple of 4 bytes
+func writeBlob(buf *bytes.Buffer, b []byte) {
+  binary.Write(buf, binary.BigEndian, int32(len(b)))
+  buf.Write(b)
+  buf.Write(make([]byte, (4 - len(b) % 4) % 4))
+}
+
+/*
+Encode the message as an OSC packet. Go integer and float types are sent as
+int32 and float32 arguments.
+*/
+func (msg *Message) Encode() ([]byte, error) {
+  tags := ","
+  args := bytes.NewBuffer(make([]byte, 0))
+
+  for _, arg := range msg.Args {
+    switch v := arg.(type) {
+    case int32:
+      tags += "i"
+      binary.Write(args, binary.BigEndian, v)
+    case int:
+      tags += "i"
+      binary.Write(args, binary.BigEndian, int32(v))
+    case float32:
+      tags += "f"
+      binary.Write(args, binary.BigEndian, math.Float32bits(v))
+    case float64:
+      tags += "f"
+      binary.Write(args, binary.BigEndian, math.Float32bits(float32(v)))
+    case string:
+      tags += "s"
+      writeString(args, v)
+    case []byte:
+      tags += "b"
+      writeBlob(args, v)
+    case bool:
+      if v {
+        tags += "T"
+      } else {
+        tags += "F"
+      }
+    default:
+      return nil, fmt.Errorf("Unsupported OSC argument type %T", arg)
+    }
+  }
+
+  buf := bytes.NewBuffer(make([]byte, 0))
+  writeString(buf, msg.Address)
+  writeString(buf, tags)
+  buf.Write(args.Bytes())
+
+  return buf.Bytes(), nil
+}
+
+// Read a NULL terminated string and skip its padding
+func readString(buf *bytes.Buffer) (string, error) {
+  s, err := buf.ReadString(0)
+
+  if err != nil {
+    return "", errors.New("Unterminated OSC string")
+  }
+
+  // ReadString consumed the first NULL
+  buf.Next((4 - len(s) % 4) % 4)
+
+  return s[:len(s) - 1], nil
+}
+
+/*
+Decode an OSC packet. Bundles are not supported.
+*/
+func DecodeMessage(data []byte) (*Message, error) {
+  buf := bytes.NewBuffer(data)
+
+  address, err := readString(buf)
+
+  if err != nil {
+    return nil, err
+  }
+
+  if len(address) == 0 || address[0] != '/' {
+    return nil, errors.New("OSC address must start with /")
+  }
+
+  msg := NewMessage(address)
+
+  // Messages from old implementations may have no type tags at all
+  if buf.Len() == 0 {
+    return msg, nil
+  }
+
+  tags, err := readString(buf)
+
+  if err != nil {
+    return nil, err
+  }
+
+  if len(tags) == 0 || tags[0] != ',' {
+    return nil, errors.New("OSC type tags must start with ,")
+  }
+
+  for _, tag := range tags[1:] {
+    switch tag {
+    case 'i':
+      var v int32
+      err = binary.Read(buf, binary.BigEndian, &v)
+      msg.Args = append(msg.Args, v)
+    case 'f':
+      var bits uint32
+      err = binary.Read(buf, binary.BigEndian, &bits)
+      msg.Args = append(msg.Args, math.Float32frombits(bits))
+    case 's':
+      var s string
+      s, err = readString(buf)
+      msg.Args = append(msg.Args, s)
+    case 'b':
+      var size int32
+      err = binary.Read(buf, binary.BigEndian, &size)
+      if err == nil {
+        if size < 0 || int(size) > buf.Len() {
+          return nil, errors.New("OSC blob is truncated")
+        }
+        blob := make([]byte, size)
+        buf.Read(blob)
+        buf.Next((4 - int(size) % 4) % 4)
+        msg.Args = append(msg.Args, blob)
+      }
+    case 'T':
+      msg.Args = append(msg.Args, true)
+    case 'F':
+      msg.Args = append(msg.Args, false)
+    default:
+      return nil, fmt.Errorf("Unsupported OSC type tag %c", tag)
+    }
+
+    if err != nil {
+      return nil, errors.New("OSC message is truncated")
+    }
+  }
+
+  return msg, nil
+}
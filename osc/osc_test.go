@@ -0,0 +1,191 @@
+package osc
+
+import (
+  "net"
+  "testing"
+  "time"
+)
+
+func TestMessageRoundTrip(t *testing.T) {
+  msg := NewMessage("/golx/test", int32(12), float32(0.5), "abc", []byte{1, 2, 3, 4, 5}, true)
+
+  data, err := msg.Encode()
+
+  if err != nil {
+    t.Log("Error encoding message: ", err.Error())
+    t.FailNow()
+  }
+
+  if len(data) % 4 != 0 {
+    t.Log("Encoded message is not a multiple of 4 bytes")
+    t.Fail()
+  }
+
+  decoded, err := DecodeMessage(data)
+
+  if err != nil {
+    t.Log("Error decoding message: ", err.Error())
+    t.FailNow()
+  }
+
+  if decoded.Address != "/golx/test" || len(decoded.Args) != 5 {
+    t.Log("Decoded message does not match: ", decoded)
+    t.FailNow()
+  }
+
+  blob := decoded.Args[3].([]byte)
+
+  if decoded.Args[0] != int32(12) || decoded.Args[1] != float32(0.5) ||
+    decoded.Args[2] != "abc" || len(blob) != 5 || blob[4] != 5 || decoded.Args[4] != true {
+    t.Log("Decoded arguments do not match: ", decoded)
+    t.Fail()
+  }
+}
+
+func TestDecodeTruncatedMessage(t *testing.T) {
+  data, _ := NewMessage("/a", int32(1)).Encode()
+
+  _, err := DecodeMessage(data[:len(data) - 2])
+
+  if err == nil {
+    t.Log("Truncated message decoded without error")
+    t.Fail()
+  }
+}
+
+// Read the next message from conn or nil if nothing arrives in time
+func readMessage(t *testing.T, conn *net.UDPConn, timeout time.Duration) *Message {
+  data := make([]byte, 1024)
+  conn.SetReadDeadline(time.Now().Add(timeout))
+  n, _, err := conn.ReadFromUDP(data)
+
+  if err != nil {
+    return nil
+  }
+
+  msg, err := DecodeMessage(data[:n])
+
+  if err != nil {
+    t.Log("Publisher sent an invalid message: ", err.Error())
+    t.FailNow()
+  }
+
+  return msg
+}
+
+func TestPublisherSendsChangesOnly(t *testing.T) {
+  pub, err := NewPublisher("127.0.0.1:0")
+
+  if err != nil {
+    t.Log("Error starting publisher: ", err.Error())
+    t.FailNow()
+  }
+
+  defer pub.Close()
+
+  client, _ := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+  defer client.Close()
+
+  pub.SetRateLimit(5 * time.Millisecond)
+  pub.AddDestination(client.LocalAddr().(*net.UDPAddr))
+
+  values := make(chan float64)
+  pub.Publish("/fader/1", values)
+
+  values <- 0.5
+  msg := readMessage(t, client, time.Second)
+
+  if msg == nil || msg.Address != "/fader/1" || msg.Args[0] != float32(0.5) {
+    t.Log("Did not recieve published value: ", msg)
+    t.FailNow()
+  }
+
+  values <- 0.5
+
+  if msg = readMessage(t, client, 50 * time.Millisecond); msg != nil {
+    t.Log("Unchanged value was sent again")
+    t.Fail()
+  }
+
+  values <- 0.25
+  msg = readMessage(t, client, time.Second)
+
+  if msg == nil || msg.Args[0] != float32(0.25) {
+    t.Log("Did not recieve changed value: ", msg)
+    t.Fail()
+  }
+
+  close(values)
+}
+
+func TestPublisherWithoutRateLimit(t *testing.T) {
+  pub, err := NewPublisher("127.0.0.1:0")
+
+  if err != nil {
+    t.Log("Error starting publisher: ", err.Error())
+    t.FailNow()
+  }
+
+  defer pub.Close()
+
+  client, _ := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+  defer client.Close()
+
+  pub.SetRateLimit(0)
+  pub.AddDestination(client.LocalAddr().(*net.UDPAddr))
+
+  values := make(chan float64)
+  pub.Publish("/fader/1", values)
+
+  // Every change is sent, however close together
+  for _, value := range []float64{0.5, 0.25} {
+    values <- value
+    msg := readMessage(t, client, time.Second)
+
+    if msg == nil || msg.Args[0] != float32(value) {
+      t.Log("Did not recieve ", value, " without a rate limit: ", msg)
+      t.Fail()
+    }
+  }
+
+  close(values)
+}
+
+func TestPublisherSubscribe(t *testing.T) {
+  pub, err := NewPublisher("127.0.0.1:0")
+
+  if err != nil {
+    t.Log("Error starting publisher: ", err.Error())
+    t.FailNow()
+  }
+
+  defer pub.Close()
+
+  pub.SetRateLimit(5 * time.Millisecond)
+
+  frames := make(chan []uint8)
+  pub.Publish("/universe/1", frames)
+  frames <- []uint8{0, 255}
+
+  // Let the frame be flushed before anyone is listening
+  time.Sleep(20 * time.Millisecond)
+
+  client, _ := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+  defer client.Close()
+
+  sub, _ := NewMessage(SubscribeAddress).Encode()
+  client.WriteToUDP(sub, pub.LocalAddr().(*net.UDPAddr))
+
+  // The new subscriber is sent the current state of every address
+  seen := make(map[string]interface {})
+  for msg := readMessage(t, client, time.Second); msg != nil; msg = readMessage(t, client, 50 * time.Millisecond) {
+    seen[msg.Address] = msg.Args[0]
+  }
+
+  if seen["/universe/1/1"] != int32(0) || seen["/universe/1/2"] != int32(255) {
+    t.Log("Subscriber was not sent current values: ", seen)
+    t.Fail()
+  }
+
+  close(frames)
+}
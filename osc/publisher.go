@@ -0,0 +1,319 @@
+/*
+OSC feedback
+
+Sends values from GoLX channels to OSC clients so that motorised faders and
+tablet interfaces stay in sync with the system. Values are only sent when they
+change and each OSC address is sent at most once per rate limit period.
+
+Clients can be configured with AddDestination or can register themselves by
+sending /golx/subscribe to the publisher, optionally with the port (int) and
+host (string) to send to. Without arguments feedback is sent back to the address
+the subscribe message came from. New subscribers are sent the most recent value
+of every address.
+*/
+package osc
+
+import (
+  "bytes"
+  "errors"
+  "fmt"
+  "net"
+  "reflect"
+  "time"
+)
+
+const (
+  SubscribeAddress string = "/golx/subscribe"
+  UnsubscribeAddress string = "/golx/unsubscribe"
+
+  defaultRateLimit time.Duration = 40 * time.Millisecond
+)
+
+type Publisher struct {
+  conn *net.UDPConn
+
+  updates chan *Message
+  destinations chan destRequest
+  rateLimit chan time.Duration
+  done chan bool
+}
+
+type destRequest struct {
+  addr *net.UDPAddr
+  add bool
+}
+
+/*
+Build a publisher that sends and listens for subscriptions on the UDP address
+listen, e.g. ":9000"
+*/
+func NewPublisher(listen string) (*Publisher, error) {
+  addr, err := net.ResolveUDPAddr("udp", listen)
+
+  if err != nil {
+    return nil, err
+  }
+
+  conn, err := net.ListenUDP("udp", addr)
+
+  if err != nil {
+    return nil, err
+  }
+
+  p := new(Publisher)
+  p.conn = conn
+  p.updates = make(chan *Message)
+  p.destinations = make(chan destRequest)
+  p.rateLimit = make(chan time.Duration)
+  p.done = make(chan bool)
+
+  go p.manage()
+  go p.listen()
+
+  return p, nil
+}
+
+// The address the publisher sends from and recieves subscriptions on
+func (p *Publisher) LocalAddr() net.Addr {
+  return p.conn.LocalAddr()
+}
+
+func (p *Publisher) AddDestination(addr *net.UDPAddr) {
+  select {
+  case p.destinations <- destRequest{addr, true}:
+  case _ = <-p.done:
+  }
+}
+
+func (p *Publisher) RemoveDestination(addr *net.UDPAddr) {
+  select {
+  case p.destinations <- destRequest{addr, false}:
+  case _ = <-p.done:
+  }
+}
+
+/*
+Set the minimum time between messages to the same OSC address. A limit of zero
+or less sends every change as soon as it is made.
+*/
+func (p *Publisher) SetRateLimit(limit time.Duration) {
+  select {
+  case p.rateLimit <- limit:
+  case _ = <-p.done:
+  }
+}
+
+/*
+Send every value recieved on values to address. values must be a channel of
+numbers, strings or bools, or a channel of slices of them such as dmx.DMXFrame.
+Slices are sent one element per message to address/n where n counts from 1 so
+that only the elements that change are sent.
+
+Publishing stops when values is closed.
+*/
+func (p *Publisher) Publish(address string, values interface {}) error {
+  valuesVal := reflect.ValueOf(values)
+
+  if valuesVal.Kind() != reflect.Chan {
+    return errors.New("Values must be a channel")
+  }
+
+  elemType := valuesVal.Type().Elem()
+  isSlice := elemType.Kind() == reflect.Slice
+
+  if isSlice {
+    elemType = elemType.Elem()
+  }
+
+  if _, err := oscArg(reflect.Zero(elemType)); err != nil {
+    return err
+  }
+
+  go func() {
+    for {
+      recv, ok := valuesVal.Recv()
+
+      if !ok {
+        return
+      }
+
+      if isSlice {
+        for i := 0; i < recv.Len(); i++ {
+          arg, _ := oscArg(recv.Index(i))
+          if !p.send(NewMessage(fmt.Sprintf("%s/%d", address, i + 1), arg)) {
+            return
+          }
+        }
+      } else {
+        arg, _ := oscArg(recv)
+        if !p.send(NewMessage(address, arg)) {
+          return
+        }
+      }
+    }
+  }()
+
+  return nil
+}
+
+// Stop sending feedback and close the connection
+func (p *Publisher) Close() {
+  close(p.done)
+}
+
+// Queue a message for sending. Returns false if the publisher has closed
+func (p *Publisher) send(msg *Message) bool {
+  select {
+  case p.updates <- msg:
+    return true
+  case _ = <-p.done:
+    return false
+  }
+}
+
+// Convert a value to a type Message can encode
+func oscArg(val reflect.Value) (interface {}, error) {
+  switch val.Kind() {
+  case reflect.Float32, reflect.Float64:
+    return float32(val.Float()), nil
+  case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+    return int32(val.Int()), nil
+  case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+    return int32(val.Uint()), nil
+  case reflect.String:
+    return val.String(), nil
+  case reflect.Bool:
+    return val.Bool(), nil
+  }
+
+  return nil, errors.New("Values of type " + val.Type().String() + " can't be sent over OSC")
+}
+
+// Owns the destinations and filtering state and sends the packets
+func (p *Publisher) manage() {
+  dests := make(map[string]*net.UDPAddr)
+
+  // Most recently sent packet for each OSC address
+  last := make(map[string][]byte)
+  // Packets waiting for the next rate limit tick
+  pending := make(map[string][]byte)
+
+  tick := time.NewTicker(defaultRateLimit)
+  ticks := tick.C
+
+  flush := func() {
+    for address, data := range pending {
+      for _, dest := range dests {
+        p.conn.WriteToUDP(data, dest)
+      }
+      last[address] = data
+    }
+    pending = make(map[string][]byte)
+  }
+
+  for {
+    select {
+    case msg := <-p.updates:
+      data, err := msg.Encode()
+
+      if err != nil {
+        continue
+      }
+
+      if bytes.Equal(last[msg.Address], data) {
+        // The value changed back before it was sent
+        delete(pending, msg.Address)
+      } else {
+        pending[msg.Address] = data
+      }
+
+      // Without a rate limit changes are sent straight away
+      if tick == nil {
+        flush()
+      }
+    case _ = <-ticks:
+      flush()
+    case req := <-p.destinations:
+      if req.add {
+        dests[req.addr.String()] = req.addr
+
+        // Bring the new destination up to date
+        for _, data := range last {
+          p.conn.WriteToUDP(data, req.addr)
+        }
+      } else {
+        delete(dests, req.addr.String())
+      }
+    case limit := <-p.rateLimit:
+      if tick != nil {
+        tick.Stop()
+      }
+
+      if limit > 0 {
+        tick = time.NewTicker(limit)
+        ticks = tick.C
+      } else {
+        tick, ticks = nil, nil
+        flush()
+      }
+    case _ = <-p.done:
+      if tick != nil {
+        tick.Stop()
+      }
+      p.conn.Close()
+      return
+    }
+  }
+}
+
+// Handle subscription requests from clients
+func (p *Publisher) listen() {
+  data := make([]byte, 4096)
+
+  for {
+    n, source, err := p.conn.ReadFromUDP(data)
+
+    if err != nil {
+      // The connection is closed when the publisher stops
+      select {
+      case _ = <-p.done:
+        return
+      default:
+        continue
+      }
+    }
+
+    msg, err := DecodeMessage(data[:n])
+
+    if err != nil {
+      continue
+    }
+
+    if msg.Address != SubscribeAddress && msg.Address != UnsubscribeAddress {
+      continue
+    }
+
+    dest := &net.UDPAddr{IP: source.IP, Port: source.Port, Zone: source.Zone}
+
+    if len(msg.Args) > 0 {
+      if port, ok := msg.Args[0].(int32); ok {
+        dest.Port = int(port)
+      }
+    }
+
+    if len(msg.Args) > 1 {
+      if host, ok := msg.Args[1].(string); ok {
+        ip := net.ParseIP(host)
+        if ip != nil {
+          dest.IP = ip
+        }
+      }
+    }
+
+    if msg.Address == SubscribeAddress {
+      p.AddDestination(dest)
+    } else {
+      p.RemoveDestination(dest)
+    }
+  }
+}
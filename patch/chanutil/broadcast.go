@@ -0,0 +1,152 @@
+package chanutil
+
+import (
+  "reflect"
+  "errors"
+)
+
+/*
+Copies every value recieved on a channel to any number of subscribers.
+
+Each subscriber is fed like DeliverWhenPossible so a slow subscriber only
+misses intermediate values rather than blocking the sender or the other
+subscribers. A subscriber's channel is closed when it unsubscribes or once the
+input has been closed.
+*/
+type Broadcaster struct {
+  elemType reflect.Type
+  subscribe chan subscription
+  unsubscribe chan interface {}
+}
+
+/*
+The channel fed by the broadcaster, the channel returned to the subscriber and
+a channel closed to unsubscribe
+*/
+type subscription struct {
+  internal reflect.Value
+  public interface {}
+  quit chan bool
+}
+
+func NewBroadcaster(input interface {}) (*Broadcaster, error) {
+  inputVal := reflect.ValueOf(input)
+
+  if inputVal.Kind() != reflect.Chan {
+    return nil, errors.New("Input must be a channel")
+  }
+
+  b := new(Broadcaster)
+  b.elemType = inputVal.Type().Elem()
+  b.subscribe = make(chan subscription)
+  b.unsubscribe = make(chan interface {})
+
+  go func() {
+    // Subscriptions by the public channel given to the subscriber
+    subscribers := make(map[interface {}] subscription)
+
+    recvCase := reflect.SelectCase{Dir: reflect.SelectRecv, Chan: inputVal}
+    subCase := reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(b.subscribe)}
+    unsubCase := reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(b.unsubscribe)}
+    selCases := []reflect.SelectCase{recvCase, subCase, unsubCase}
+
+    for {
+      chosen, recv, recvOK := reflect.Select(selCases)
+
+      switch chosen {
+      case 0:
+        if recvOK {
+          // Subscribers are always fed promptly
+          for _, sub := range subscribers {
+            sub.internal.Send(recv)
+          }
+        } else {
+          for _, sub := range subscribers {
+            sub.internal.Close()
+          }
+          return
+        }
+      case 1:
+        sub := recv.Interface().(subscription)
+        subscribers[sub.public] = sub
+      case 2:
+        if sub, exists := subscribers[recv.Interface()]; exists {
+          close(sub.quit)
+          delete(subscribers, recv.Interface())
+        }
+      }
+    }
+  }()
+
+  return b, nil
+}
+
+/*
+Get a new channel that recieves values sent to the broadcaster from now on. The
+returned value is a channel with the same element type as the input.
+*/
+func (b *Broadcaster) Subscribe() interface {} {
+  chanType := reflect.ChanOf(reflect.BothDir, b.elemType)
+  internal := reflect.MakeChan(chanType, 0)
+  public := reflect.MakeChan(chanType, 0)
+
+  quit := make(chan bool)
+
+  go feed(internal, public, quit)
+
+  b.subscribe <- subscription{internal, public.Interface(), quit}
+
+  return public.Interface()
+}
+
+/*
+Stop sending values to a channel returned by Subscribe and close it, ending any
+range over it. Any value waiting to be read from it is discarded.
+*/
+func (b *Broadcaster) Unsubscribe(sub interface {}) {
+  b.unsubscribe <- sub
+}
+
+/*
+Deliver values from internal to public like DeliverWhenPossible. Once internal
+is closed the value waiting, if any, is delivered and public is closed. Closing
+quit closes public straight away.
+*/
+func feed(internal, public reflect.Value, quit chan bool) {
+  recvCase := reflect.SelectCase{Dir: reflect.SelectRecv, Chan: internal}
+  quitCase := reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(quit)}
+
+  var data reflect.Value
+  dataAvailable := false
+
+  defer public.Close()
+
+  for {
+    selCases := []reflect.SelectCase{quitCase, recvCase}
+    if dataAvailable {
+      selCases = append(selCases, reflect.SelectCase{Dir: reflect.SelectSend, Chan: public, Send: data})
+    }
+
+    chosen, recv, recvOK := reflect.Select(selCases)
+
+    switch chosen {
+    case 0:
+      return
+    case 1:
+      if recvOK {
+        data = recv
+        dataAvailable = true
+        continue
+      }
+
+      // Drain the last value before closing
+      if dataAvailable {
+        sendCase := reflect.SelectCase{Dir: reflect.SelectSend, Chan: public, Send: data}
+        reflect.Select([]reflect.SelectCase{quitCase, sendCase})
+      }
+      return
+    case 2:
+      dataAvailable = false
+    }
+  }
+}
@@ -0,0 +1,95 @@
+package chanutil
+
+import (
+  "testing"
+  "time"
+)
+
+func TestBroadcaster(t *testing.T) {
+  input := make(chan int)
+
+  b, err := NewBroadcaster(input)
+
+  if err != nil {
+    t.Log("Error initializing Broadcaster: ", err.Error())
+    t.FailNow()
+  }
+
+  a := b.Subscribe().(chan int)
+  c := b.Subscribe().(chan int)
+
+  // Every subscriber gets the value
+  input <- 123
+
+  if <-a != 123 || <-c != 123 {
+    t.Log("Subscribers did not recieve value sent on input")
+    t.Fail()
+  }
+
+  // Subscribers that don't read don't hold up the input
+  input <- 1
+  input <- 2
+  input <- 3
+
+  // Older values may be skipped but the most recent one arrives
+  timeout := time.After(1 * time.Second)
+  for val := 0; val != 3; {
+    select {
+    case val = <-a:
+    case _ = <-timeout:
+      t.Log("Did not recieve most recent value sent on input")
+      t.FailNow()
+    }
+  }
+
+  close(input)
+}
+
+func TestBroadcasterCloses(t *testing.T) {
+  input := make(chan int)
+  b, _ := NewBroadcaster(input)
+
+  a := b.Subscribe().(chan int)
+  c := b.Subscribe().(chan int)
+
+  // Unsubscribing ends a range over the channel
+  done := make(chan bool)
+  go func() {
+    for _ = range a {
+    }
+    done <- true
+  }()
+
+  b.Unsubscribe(a)
+
+  select {
+  case _ = <-done:
+  case _ = <-time.After(time.Second):
+    t.Log("Unsubscribed channel was not closed")
+    t.Fail()
+  }
+
+  // Closing the input delivers the last value and then closes the subscribers
+  input <- 5
+  close(input)
+
+  timeout := time.After(time.Second)
+  last := 0
+  for closed := false; !closed; {
+    select {
+    case val, ok := <-c:
+      if ok {
+        last = val
+      }
+      closed = !ok
+    case _ = <-timeout:
+      t.Log("Subscriber was not closed with the input")
+      t.FailNow()
+    }
+  }
+
+  if last != 5 {
+    t.Log("Last value was not delivered before closing, got ", last)
+    t.Fail()
+  }
+}
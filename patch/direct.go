@@ -23,6 +23,9 @@ type patchData struct {
   commonChan reflect.Value
 
   stop chan bool
+
+  // Properties copied from the output to the input, cleared when unpatched
+  copied []string
 }
 
 // Request structures to send data over a channel
@@ -394,7 +397,7 @@ func addPatch(req patchRequest) error {
 
   var inputDelegatorPatchList map[*patchData] bool
   var inputDelegatorPatchListExists bool
-  if !patch.inputDelegator.IsNil() {
+  if patch.inputDelegator.IsValid() {
     inputDelegatorPatchList, inputDelegatorPatchListExists = inputPatches[patch.inputDelegator]
 
     if !inputDelegatorPatchListExists {
@@ -412,7 +415,7 @@ func addPatch(req patchRequest) error {
 
   var outputDelegatorPatchList map[*patchData] bool
   var outputDelegatorPatchListExists bool
-  if !patch.outputDelegator.IsNil() {
+  if patch.outputDelegator.IsValid() {
     outputDelegatorPatchList, outputDelegatorPatchListExists = outputPatches[patch.outputDelegator]
 
     if !outputDelegatorPatchListExists {
@@ -477,23 +480,25 @@ func addPatch(req patchRequest) error {
   // channel for the proxy and unset the common channel
   if !(setOutput || setInput) {
     patch.stop = make(chan bool)
-    proxy(patch.outputChan, patch.inputChan, patch.stop)
+    go proxy(patch.outputChan, patch.inputChan, patch.stop)
   }
 
   fmt.Println("Storing changes to data")
   outputPatches[patch.output] = outputPatchList
-  if !patch.outputDelegator.IsNil() {
+  if patch.outputDelegator.IsValid() {
     outputPatches[patch.outputDelegator] = outputDelegatorPatchList
   }
 
   inputPatches[patch.input] = inputPatchList
-  if !patch.inputDelegator.IsNil() {
+  if patch.inputDelegator.IsValid() {
     inputPatches[patch.inputDelegator] = inputDelegatorPatchList
   }
 
   outputChanPatches[patch.outputChan] = patch
   inputChanPatches[patch.inputChan] = patch
 
+  patch.copied = copyProperties(patch.output, patch.input)
+
   return nil
 }
 
@@ -530,14 +535,16 @@ func removePatch(req unpatchRequest) error {
   setInputChan, setInputChanErr := setChanMethod(patch.input, patchInputDir)
 
   // Set the channels to nil if nescessary
-  if setOutputChanErr != nil {
-    setOutputChan.Call([]reflect.Value{reflect.ValueOf(nil)})
+  if setOutputChanErr == nil {
+    setOutputChan.Call([]reflect.Value{reflect.Zero(setOutputChan.Type().In(0))})
   }
 
-  if setInputChanErr != nil {
-    setInputChan.Call([]reflect.Value{reflect.ValueOf(nil)})
+  if setInputChanErr == nil {
+    setInputChan.Call([]reflect.Value{reflect.Zero(setInputChan.Type().In(0))})
   }
 
+  clearProperties(patch.output, patch.input, patch.copied)
+
   // Remove the record of the patch
   delete(outputChanPatches, patch.outputChan)
   delete(inputChanPatches, patch.inputChan)
@@ -545,11 +552,11 @@ func removePatch(req unpatchRequest) error {
   delete(outputPatches[patch.output], patch)
   delete(inputPatches[patch.input], patch)
 
-  if !patch.outputDelegator.IsNil() {
+  if patch.outputDelegator.IsValid() {
     delete(outputPatches[patch.outputDelegator], patch)
   }
 
-  if !patch.inputDelegator.IsNil() {
+  if patch.inputDelegator.IsValid() {
     delete(inputPatches[patch.inputDelegator], patch)
   }
 
@@ -0,0 +1,73 @@
+package patch
+
+// Copying classes and curves from outputs to inputs as they are patched
+
+import (
+  "reflect"
+)
+
+/*
+Properties an output can describe to the input it is patched to. For each
+name, if the output has a method of that name and the input accepts the value
+with the same name prefixed by Set, the input is given the output's value.
+Universes use this to find intensity channels and apply parameter curves
+without depending on any fixture packages.
+
+Only values that are set, i.e. not the zero value such as a nil curve, are
+copied, so a property given to the input directly is kept when the output
+doesn't describe one. Unpatching clears only the properties the patch copied.
+*/
+var copiedProperties = []string{"Class", "Curve"}
+
+// Give the input each property the output describes, returning the names copied
+func copyProperties(output, input reflect.Value) []string {
+  var copied []string
+
+  for _, name := range copiedProperties {
+    get, set, ok := propertyMethods(output, input, name)
+
+    if !ok {
+      continue
+    }
+
+    val := get.Call([]reflect.Value{})[0]
+    if val.IsZero() {
+      continue
+    }
+
+    set.Call([]reflect.Value{val})
+    copied = append(copied, name)
+  }
+
+  return copied
+}
+
+// Reset the copied properties of the input to their zero values when unpatched
+func clearProperties(output, input reflect.Value, copied []string) {
+  for _, name := range copied {
+    _, set, ok := propertyMethods(output, input, name)
+
+    if ok {
+      set.Call([]reflect.Value{reflect.Zero(set.Type().In(0))})
+    }
+  }
+}
+
+func propertyMethods(output, input reflect.Value, name string) (reflect.Value, reflect.Value, bool) {
+  get := output.MethodByName(name)
+  set := input.MethodByName("Set" + name)
+
+  if !get.IsValid() || !set.IsValid() {
+    return get, set, false
+  }
+
+  if get.Type().NumIn() != 0 || get.Type().NumOut() != 1 || set.Type().NumIn() != 1 {
+    return get, set, false
+  }
+
+  if !get.Type().Out(0).AssignableTo(set.Type().In(0)) {
+    return get, set, false
+  }
+
+  return get, set, true
+}
@@ -0,0 +1,177 @@
+package softpatch
+
+import (
+  "bufio"
+  "fmt"
+  "io"
+  "os"
+  "strconv"
+  "strings"
+)
+
+/*
+Patch files have one channel per line: the channel number followed by the
+addresses it drives as universe/address, optionally with a proportion as a
+percentage after an @. Blank lines and lines starting with # are ignored. An
+address listed twice for a channel takes the last proportion given, and a
+channel with no addresses is left unpatched.
+
+  # channel  addresses
+  1   1/1 1/2@80
+  2   2/10
+*/
+
+// Replace the whole patch with the one read from r
+func (p *SoftPatch) Load(r io.Reader) error {
+  table, err := parse(r)
+
+  if err != nil {
+    return err
+  }
+
+  p.lock.Lock()
+  defer p.lock.Unlock()
+
+  for id := range p.table {
+    p.clear(id)
+  }
+
+  for id, targets := range table {
+    p.table[id] = targets
+  }
+
+  for _, targets := range table {
+    for _, target := range targets {
+      p.update(target.Address)
+      p.updateCurve(target.Address)
+    }
+  }
+
+  return nil
+}
+
+func (p *SoftPatch) LoadFile(path string) error {
+  f, err := os.Open(path)
+
+  if err != nil {
+    return err
+  }
+
+  defer f.Close()
+
+  return p.Load(f)
+}
+
+// Write the patch in the format read by Load
+func (p *SoftPatch) Save(w io.Writer) error {
+  buf := bufio.NewWriter(w)
+  fmt.Fprintln(buf, "# channel  addresses")
+
+  for _, id := range p.PatchedChannels() {
+    fmt.Fprintf(buf, "%d", id)
+
+    for _, target := range p.Targets(id) {
+      fmt.Fprintf(buf, " %s", target.Address)
+      if target.Proportion != 1 {
+        fmt.Fprintf(buf, "@%s", strconv.FormatFloat(target.Proportion * 100, 'f', -1, 64))
+      }
+    }
+
+    fmt.Fprintln(buf)
+  }
+
+  return buf.Flush()
+}
+
+func (p *SoftPatch) SaveFile(path string) error {
+  f, err := os.Create(path)
+
+  if err != nil {
+    return err
+  }
+
+  if err = p.Save(f); err != nil {
+    f.Close()
+    return err
+  }
+
+  return f.Close()
+}
+
+func parse(r io.Reader) (map[int] []Target, error) {
+  table := make(map[int] []Target)
+  seen := make(map[int] bool)
+  scanner := bufio.NewScanner(r)
+  line := 0
+
+  for scanner.Scan() {
+    line++
+    fields := strings.Fields(scanner.Text())
+
+    if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+      continue
+    }
+
+    id, err := strconv.Atoi(fields[0])
+
+    if err != nil {
+      return nil, fmt.Errorf("Line %d: channel %q is not a number", line, fields[0])
+    }
+
+    if seen[id] {
+      return nil, fmt.Errorf("Line %d: channel %d is listed twice", line, id)
+    }
+    seen[id] = true
+
+    targets := make([]Target, 0, len(fields) - 1)
+
+    for _, field := range fields[1:] {
+      target, err := parseTarget(field)
+
+      if err != nil {
+        return nil, fmt.Errorf("Line %d: %s", line, err.Error())
+      }
+
+      targets = withTarget(targets, target)
+    }
+
+    if len(targets) > 0 {
+      table[id] = targets
+    }
+  }
+
+  return table, scanner.Err()
+}
+
+func parseTarget(field string) (Target, error) {
+  target := Target{Proportion: 1}
+  addr := field
+
+  if at := strings.Index(field, "@"); at >= 0 {
+    addr = field[:at]
+    percent, err := strconv.ParseFloat(field[at + 1:], 64)
+
+    if err != nil {
+      return target, fmt.Errorf("proportion in %q is not a number", field)
+    }
+
+    target.Proportion = percent / 100
+  }
+
+  parts := strings.Split(addr, "/")
+
+  if len(parts) != 2 {
+    return target, fmt.Errorf("%q is not a universe/address pair", field)
+  }
+
+  universe, uErr := strconv.Atoi(parts[0])
+  address, aErr := strconv.Atoi(parts[1])
+
+  if uErr != nil || aErr != nil {
+    return target, fmt.Errorf("%q is not a universe/address pair", field)
+  }
+
+  target.Address = Address{universe, address}
+
+  return target, validTarget(target)
+}
@@ -0,0 +1,451 @@
+/*
+Soft patch
+
+Maps console channels (fixture IDs), numbered independently of DMX addresses,
+to any number of addresses across universes. Each address can be given a
+proportion of the channel's level, e.g. to balance a pair of lamps. The table
+can be edited while the show is running and the current level of a channel is
+reapplied to its new addresses straight away.
+
+A channel's class and curve, copied from the parameter patched to it, are
+passed on to each of its addresses so the universes can tell intensity
+addresses apart and apply the curve after their master.
+
+An address may be patched to more than one channel. It is then driven highest
+takes precedence, as on a console, and is tagged as intensity if any of its
+channels is intensity, or otherwise with the class of its lowest numbered
+channel. It takes the curve of its lowest numbered channel with one. Removing
+one of its channels leaves it driven by the others.
+*/
+package softpatch
+
+import (
+  "errors"
+  "fmt"
+  "math"
+  "sort"
+  "sync"
+  "golx/dmx"
+  "golx/dmx/curve"
+)
+
+// A DMX address in a numbered universe
+type Address struct {
+  Universe int
+  Address int
+}
+
+func (addr Address) String() string {
+  return fmt.Sprintf("%d/%d", addr.Universe, addr.Address)
+}
+
+// An address a channel drives and the proportion of its level that is sent
+type Target struct {
+  Address
+  Proportion float64
+}
+
+type SoftPatch struct {
+  lock sync.Mutex
+
+  universes map[int] *dmx.DMXUniverse
+  table map[int] []Target
+  levels map[int] dmx.DMXValue
+  classes map[int] dmx.ParamClass
+  curves map[int] curve.Curve
+  channels map[int] *Channel
+
+  // Addresses the patch has set a curve on
+  curved map[Address] bool
+}
+
+/*
+A console channel. Patch a parameter's output to it in the same way as a
+dmx.DMXChannel.
+*/
+type Channel struct {
+  patch *SoftPatch
+  id int
+  input chan dmx.DMXValue
+}
+
+func NewSoftPatch() *SoftPatch {
+  p := new(SoftPatch)
+  p.universes = make(map[int] *dmx.DMXUniverse)
+  p.table = make(map[int] []Target)
+  p.levels = make(map[int] dmx.DMXValue)
+  p.classes = make(map[int] dmx.ParamClass)
+  p.curves = make(map[int] curve.Curve)
+  p.channels = make(map[int] *Channel)
+  p.curved = make(map[Address] bool)
+  return p
+}
+
+// Make a universe available to the patch under the given number
+func (p *SoftPatch) AddUniverse(number int, universe *dmx.DMXUniverse) {
+  p.lock.Lock()
+  defer p.lock.Unlock()
+
+  p.universes[number] = universe
+
+  // Bring the new universe up to date with any channels already patched to it
+  for _, targets := range p.table {
+    for _, target := range targets {
+      if target.Universe == number {
+        p.update(target.Address)
+        p.updateCurve(target.Address)
+      }
+    }
+  }
+}
+
+// Get the console channel with the given ID
+func (p *SoftPatch) Channel(id int) *Channel {
+  p.lock.Lock()
+  defer p.lock.Unlock()
+
+  channel, exists := p.channels[id]
+
+  if !exists {
+    channel = &Channel{p, id, make(chan dmx.DMXValue)}
+    p.channels[id] = channel
+
+    go func() {
+      for val := range channel.input {
+        p.setLevel(id, val)
+      }
+    }()
+  }
+
+  return channel
+}
+
+func (channel *Channel) String() string {
+  return fmt.Sprintf("[Channel %d]", channel.id)
+}
+
+func (channel *Channel) ID() int {
+  return channel.id
+}
+
+func (channel *Channel) Input() chan dmx.DMXValue {
+  return channel.input
+}
+
+func (channel *Channel) Value() dmx.DMXValue {
+  channel.patch.lock.Lock()
+  defer channel.patch.lock.Unlock()
+  return channel.patch.levels[channel.id]
+}
+
+// Tag every address the channel is patched to with a class of parameter
+func (channel *Channel) SetClass(class dmx.ParamClass) {
+  p := channel.patch
+  p.lock.Lock()
+  defer p.lock.Unlock()
+
+  p.classes[channel.id] = class
+
+  for _, target := range p.table[channel.id] {
+    p.update(target.Address)
+  }
+}
+
+func (channel *Channel) Class() dmx.ParamClass {
+  channel.patch.lock.Lock()
+  defer channel.patch.lock.Unlock()
+  return channel.patch.classes[channel.id]
+}
+
+/*
+Apply a curve to every address the channel is patched to, or remove it if c is
+nil. The curve is applied by the universes, after their master.
+*/
+func (channel *Channel) SetCurve(c curve.Curve) {
+  p := channel.patch
+  p.lock.Lock()
+  defer p.lock.Unlock()
+
+  if c == nil {
+    delete(p.curves, channel.id)
+  } else {
+    p.curves[channel.id] = c
+  }
+
+  for _, target := range p.table[channel.id] {
+    p.updateCurve(target.Address)
+  }
+}
+
+func (channel *Channel) Curve() curve.Curve {
+  channel.patch.lock.Lock()
+  defer channel.patch.lock.Unlock()
+  return channel.patch.curves[channel.id]
+}
+
+func validTarget(target Target) error {
+  if target.Address.Address < 1 || target.Address.Address > dmx.UniverseSize {
+    return errors.New("Address " + target.Address.String() + " is not in a universe")
+  }
+
+  if target.Proportion < 0 || target.Proportion > 1 {
+    return errors.New("Proportion for " + target.Address.String() + " must be between 0 and 1")
+  }
+
+  return nil
+}
+
+/*
+Add addresses to a channel. An address that is already patched to the channel
+has its proportion updated.
+*/
+func (p *SoftPatch) Patch(id int, targets ...Target) error {
+  for _, target := range targets {
+    if err := validTarget(target); err != nil {
+      return err
+    }
+  }
+
+  p.lock.Lock()
+  defer p.lock.Unlock()
+
+  for _, target := range targets {
+    p.table[id] = withTarget(p.table[id], target)
+
+    p.update(target.Address)
+    p.updateCurve(target.Address)
+  }
+
+  return nil
+}
+
+// Add target to targets, replacing any target with the same address
+func withTarget(targets []Target, target Target) []Target {
+  for i, existing := range targets {
+    if existing.Address == target.Address {
+      targets[i] = target
+      return targets
+    }
+  }
+
+  return append(targets, target)
+}
+
+/*
+Remove an address from a channel. The address is set to zero unless other
+channels are still patched to it.
+*/
+func (p *SoftPatch) Unpatch(id int, addr Address) error {
+  p.lock.Lock()
+  defer p.lock.Unlock()
+
+  targets := p.table[id]
+
+  for i, target := range targets {
+    if target.Address == addr {
+      p.table[id] = append(targets[:i:i], targets[i + 1:]...)
+      if len(p.table[id]) == 0 {
+        delete(p.table, id)
+      }
+
+      p.update(addr)
+      p.updateCurve(addr)
+      return nil
+    }
+  }
+
+  return errors.New("Address " + addr.String() + " is not patched to the channel")
+}
+
+// Remove every address from a channel
+func (p *SoftPatch) Clear(id int) {
+  p.lock.Lock()
+  defer p.lock.Unlock()
+  p.clear(id)
+}
+
+func (p *SoftPatch) clear(id int) {
+  targets := p.table[id]
+  delete(p.table, id)
+
+  for _, target := range targets {
+    p.update(target.Address)
+    p.updateCurve(target.Address)
+  }
+}
+
+// The addresses patched to a channel
+func (p *SoftPatch) Targets(id int) []Target {
+  p.lock.Lock()
+  defer p.lock.Unlock()
+  return append([]Target{}, p.table[id]...)
+}
+
+// Every channel with at least one address, in order
+func (p *SoftPatch) PatchedChannels() []int {
+  p.lock.Lock()
+  defer p.lock.Unlock()
+
+  ids := make([]int, 0, len(p.table))
+  for id := range p.table {
+    ids = append(ids, id)
+  }
+  sort.Ints(ids)
+
+  return ids
+}
+
+// Addresses in a universe that no channel is patched to
+func (p *SoftPatch) Unpatched(universe int) []int {
+  p.lock.Lock()
+  defer p.lock.Unlock()
+
+  used := make(map[int] bool)
+  for _, targets := range p.table {
+    for _, target := range targets {
+      if target.Universe == universe {
+        used[target.Address.Address] = true
+      }
+    }
+  }
+
+  unpatched := make([]int, 0)
+  for addr := 1; addr <= dmx.UniverseSize; addr++ {
+    if !used[addr] {
+      unpatched = append(unpatched, addr)
+    }
+  }
+
+  return unpatched
+}
+
+// Addresses that more than one channel is patched to, and those channels
+func (p *SoftPatch) DoublePatched() map[Address] []int {
+  p.lock.Lock()
+  defer p.lock.Unlock()
+
+  users := make(map[Address] []int)
+  for id, targets := range p.table {
+    for _, target := range targets {
+      users[target.Address] = append(users[target.Address], id)
+    }
+  }
+
+  double := make(map[Address] []int)
+  for addr, ids := range users {
+    if len(ids) > 1 {
+      sort.Ints(ids)
+      double[addr] = ids
+    }
+  }
+
+  return double
+}
+
+func (p *SoftPatch) setLevel(id int, level dmx.DMXValue) {
+  p.lock.Lock()
+  defer p.lock.Unlock()
+
+  p.levels[id] = level
+
+  for _, target := range p.table[id] {
+    p.update(target.Address)
+  }
+}
+
+/*
+Send an address the highest level of the channels patched to it and tag it
+with their class, or zero it and remove its tag if none are. Must be called
+with the lock held.
+*/
+func (p *SoftPatch) update(addr Address) {
+  universe, exists := p.universes[addr.Universe]
+
+  if !exists {
+    return
+  }
+
+  ids, targets := p.patchedTo(addr)
+  level := 0.0
+
+  for i, id := range ids {
+    level = math.Max(level, float64(p.levels[id]) * targets[i].Proportion)
+  }
+
+  class := dmx.UnknownClass
+  for _, id := range ids {
+    if p.classes[id] == dmx.IntensityClass {
+      class = dmx.IntensityClass
+      break
+    }
+    if class == dmx.UnknownClass {
+      class = p.classes[id]
+    }
+  }
+
+  channel := universe.GetChannel(addr.Address)
+  channel.SetClass(class)
+  channel.Input() <- dmx.DMXValue(math.Floor(level + 0.5))
+}
+
+/*
+Set the curve of the lowest numbered channel patched to an address that has
+one on the address. An address the patch has never set a curve on is left
+alone so curves set directly on the universe are kept. Must be called with the
+lock held.
+*/
+func (p *SoftPatch) updateCurve(addr Address) {
+  universe, exists := p.universes[addr.Universe]
+
+  if !exists {
+    return
+  }
+
+  ids, _ := p.patchedTo(addr)
+
+  var c curve.Curve
+  for _, id := range ids {
+    if p.curves[id] != nil {
+      c = p.curves[id]
+      break
+    }
+  }
+
+  if c == nil && !p.curved[addr] {
+    return
+  }
+
+  if c == nil {
+    delete(p.curved, addr)
+  } else {
+    p.curved[addr] = true
+  }
+
+  universe.SetCurve(addr.Address, c)
+}
+
+// The channels patched to an address in order, with their targets for it
+func (p *SoftPatch) patchedTo(addr Address) ([]int, []Target) {
+  ids := make([]int, 0, 1)
+
+  for id, targets := range p.table {
+    for _, target := range targets {
+      if target.Address == addr {
+        ids = append(ids, id)
+      }
+    }
+  }
+
+  sort.Ints(ids)
+
+  targets := make([]Target, len(ids))
+  for i, id := range ids {
+    for _, target := range p.table[id] {
+      if target.Address == addr {
+        targets[i] = target
+      }
+    }
+  }
+
+  return ids, targets
+}
@@ -0,0 +1,227 @@
+package softpatch
+
+import (
+  "bytes"
+  "strings"
+  "testing"
+  "golx/dmx"
+  "golx/dmx/curve"
+  "golx/dmx/dmxtest"
+)
+
+func TestChannelDrivesProportionalAddresses(t *testing.T) {
+  u1 := dmx.NewDMXUniverse()
+  u2 := dmx.NewDMXUniverse()
+  w1 := u1.Watch()
+  w2 := u2.Watch()
+
+  p := NewSoftPatch()
+  p.AddUniverse(1, u1)
+  p.AddUniverse(2, u2)
+
+  p.Patch(5, Target{Address{1, 1}, 1}, Target{Address{1, 2}, 0.5}, Target{Address{2, 100}, 1})
+  p.Channel(5).Input() <- 200
+
+  frame := dmxtest.WaitFor(w1, func(f dmx.DMXFrame) bool { return f[0] == 200 })
+
+  if frame == nil || frame[1] != 100 {
+    t.Log("Universe 1 was not set proportionally: ", frame)
+    t.Fail()
+  }
+
+  if dmxtest.WaitFor(w2, func(f dmx.DMXFrame) bool { return f[99] == 200 }) == nil {
+    t.Log("Universe 2 was not set")
+    t.Fail()
+  }
+
+  // Editing the patch applies the current level straight away
+  p.Patch(5, Target{Address{1, 3}, 1})
+  if dmxtest.WaitFor(w1, func(f dmx.DMXFrame) bool { return f[2] == 200 }) == nil {
+    t.Log("Newly patched address did not get the channel level")
+    t.Fail()
+  }
+
+  p.Unpatch(5, Address{1, 1})
+  if dmxtest.WaitFor(w1, func(f dmx.DMXFrame) bool { return f[0] == 0 }) == nil {
+    t.Log("Unpatched address was not released")
+    t.Fail()
+  }
+}
+
+func TestClassPassedToAddresses(t *testing.T) {
+  u := dmx.NewDMXUniverse()
+
+  p := NewSoftPatch()
+  p.AddUniverse(1, u)
+
+  p.Patch(1, Target{Address{1, 10}, 1})
+  p.Channel(1).SetClass(dmx.IntensityClass)
+  p.Patch(1, Target{Address{1, 11}, 1})
+
+  if u.GetChannel(10).Class() != dmx.IntensityClass || u.GetChannel(11).Class() != dmx.IntensityClass {
+    t.Log("Addresses were not tagged with the channel's class")
+    t.Fail()
+  }
+
+  p.Unpatch(1, Address{1, 10})
+
+  if u.GetChannel(10).Class() != dmx.UnknownClass {
+    t.Log("Unpatched address kept its class")
+    t.Fail()
+  }
+}
+
+func TestDoublePatchedHighestTakesPrecedence(t *testing.T) {
+  u := dmx.NewDMXUniverse()
+  w := u.Watch()
+
+  p := NewSoftPatch()
+  p.AddUniverse(1, u)
+
+  p.Patch(1, Target{Address{1, 1}, 1})
+  p.Patch(2, Target{Address{1, 1}, 1})
+  p.Channel(1).SetClass(dmx.IntensityClass)
+
+  p.Channel(1).Input() <- 100
+  p.Channel(2).Input() <- 200
+
+  if dmxtest.WaitFor(w, func(f dmx.DMXFrame) bool { return f[0] == 200 }) == nil {
+    t.Log("Highest level did not take precedence")
+    t.Fail()
+  }
+
+  p.Channel(2).Input() <- 50
+  if dmxtest.WaitFor(w, func(f dmx.DMXFrame) bool { return f[0] == 100 }) == nil {
+    t.Log("Lowering one channel did not hand over to the other")
+    t.Fail()
+  }
+
+  // Removing a channel leaves the address driven by the other
+  p.Channel(2).Input() <- 250
+  dmxtest.WaitFor(w, func(f dmx.DMXFrame) bool { return f[0] == 250 })
+  p.Unpatch(2, Address{1, 1})
+
+  if dmxtest.WaitFor(w, func(f dmx.DMXFrame) bool { return f[0] == 100 }) == nil {
+    t.Log("Address did not return to the remaining channel's level")
+    t.Fail()
+  }
+
+  if u.GetChannel(1).Class() != dmx.IntensityClass {
+    t.Log("Address lost the remaining channel's class")
+    t.Fail()
+  }
+
+  p.Clear(1)
+  if dmxtest.WaitFor(w, func(f dmx.DMXFrame) bool { return f[0] == 0 }) == nil || u.GetChannel(1).Class() != dmx.UnknownClass {
+    t.Log("Address was not released once no channels were patched to it")
+    t.Fail()
+  }
+}
+
+func TestCurvePassedToAddresses(t *testing.T) {
+  u := dmx.NewDMXUniverse()
+  w := u.Watch()
+
+  p := NewSoftPatch()
+  p.AddUniverse(1, u)
+
+  p.Channel(1).SetCurve(curve.SquareLaw{})
+  p.Patch(1, Target{Address{1, 1}, 1})
+  p.Channel(1).Input() <- 128
+
+  if dmxtest.WaitFor(w, func(f dmx.DMXFrame) bool { return f[0] == 64 }) == nil {
+    t.Log("Channel curve was not applied to its address")
+    t.Fail()
+  }
+
+  p.Unpatch(1, Address{1, 1})
+  u.GetChannel(1).Input() <- 128
+
+  if dmxtest.WaitFor(w, func(f dmx.DMXFrame) bool { return f[0] == 128 }) == nil {
+    t.Log("Unpatched address kept the channel curve")
+    t.Fail()
+  }
+}
+
+func TestReports(t *testing.T) {
+  p := NewSoftPatch()
+  p.Patch(1, Target{Address{1, 1}, 1}, Target{Address{1, 2}, 1})
+  p.Patch(2, Target{Address{1, 2}, 1})
+
+  double := p.DoublePatched()
+
+  if len(double) != 1 || len(double[Address{1, 2}]) != 2 {
+    t.Log("Double patched addresses reported incorrectly: ", double)
+    t.Fail()
+  }
+
+  unpatched := p.Unpatched(1)
+
+  if len(unpatched) != dmx.UniverseSize - 2 || unpatched[0] != 3 {
+    t.Log("Unpatched addresses reported incorrectly")
+    t.Fail()
+  }
+
+  if err := p.Patch(3, Target{Address{1, 513}, 1}); err == nil {
+    t.Log("Address outside the universe was allowed")
+    t.Fail()
+  }
+}
+
+func TestLoadAndSave(t *testing.T) {
+  file := "# test patch\n1  1/1 1/2@80\n\n12 2/10\n"
+
+  p := NewSoftPatch()
+  p.Patch(99, Target{Address{3, 3}, 1})
+
+  if err := p.Load(strings.NewReader(file)); err != nil {
+    t.Log("Error loading patch: ", err.Error())
+    t.FailNow()
+  }
+
+  targets := p.Targets(1)
+
+  if len(targets) != 2 || targets[1].Address != (Address{1, 2}) || targets[1].Proportion != 0.8 {
+    t.Log("Patch loaded incorrectly: ", targets)
+    t.Fail()
+  }
+
+  if len(p.Targets(99)) != 0 {
+    t.Log("Loading did not replace the existing patch")
+    t.Fail()
+  }
+
+  buf := bytes.NewBuffer(make([]byte, 0))
+  p.Save(buf)
+
+  if buf.String() != "# channel  addresses\n1 1/1 1/2@80\n12 2/10\n" {
+    t.Log("Patch saved incorrectly: ", buf.String())
+    t.Fail()
+  }
+
+  // Channels without addresses aren't patched and repeated addresses are kept once
+  if err := p.Load(strings.NewReader("1 1/1 1/1@50\n2\n")); err != nil {
+    t.Log("Error loading patch: ", err.Error())
+    t.FailNow()
+  }
+
+  if channels := p.PatchedChannels(); len(channels) != 1 || channels[0] != 1 {
+    t.Log("Patched channels loaded as ", channels)
+    t.Fail()
+  }
+
+  if targets := p.Targets(1); len(targets) != 1 || targets[0].Proportion != 0.5 {
+    t.Log("Repeated address loaded as ", targets)
+    t.Fail()
+  }
+
+  if err := p.Load(strings.NewReader("2\n2 1/3\n")); err == nil {
+    t.Log("Channel listed twice was not reported")
+    t.Fail()
+  }
+
+  if err := p.Load(strings.NewReader("1 1-1\n")); err == nil || !strings.HasPrefix(err.Error(), "Line 1") {
+    t.Log("Bad address was not reported: ", err)
+    t.Fail()
+  }
+}
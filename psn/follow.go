@@ -0,0 +1,192 @@
+/*
+Follow spots
+
+Points a moving light at a PSN tracker. The pan and tilt are sent into a
+channel, normally an Input() of the fixture's position attribute, so the follow
+spot is an ordinary mixer source that can be overridden or released.
+*/
+package psn
+
+import (
+  "math"
+  "golx/data/position"
+)
+
+/*
+Where a moving light is rigged. With no rotation the fixture stands upright on
+the floor with the beam pointing up (+y) at tilt 0 and pan 0 facing +z. Yaw,
+Pitch and Roll rotate the fixture around the y, x and z axes in degrees and are
+applied in that order, so a fixture hung from a bar has a Roll of 180.
+*/
+type Placement struct {
+  Position Vec3
+
+  Yaw float64
+  Pitch float64
+  Roll float64
+}
+
+func radians(deg float64) float64 {
+  return deg * math.Pi / 180
+}
+
+func degrees(rad float64) float64 {
+  return rad * 180 / math.Pi
+}
+
+// Rotate v into the fixture's frame of reference
+func (p Placement) toLocal(v Vec3) Vec3 {
+  // Undo the rotations in the reverse order: yaw, pitch then roll
+  sin, cos := math.Sincos(radians(-p.Yaw))
+  v = Vec3{v.X * cos + v.Z * sin, v.Y, -v.X * sin + v.Z * cos}
+
+  sin, cos = math.Sincos(radians(-p.Pitch))
+  v = Vec3{v.X, v.Y * cos - v.Z * sin, v.Y * sin + v.Z * cos}
+
+  sin, cos = math.Sincos(radians(-p.Roll))
+  v = Vec3{v.X * cos - v.Y * sin, v.X * sin + v.Y * cos, v.Z}
+
+  return v
+}
+
+/*
+Find the pan and tilt that point the fixture at target. Tilt is always
+positive and pan is between -180 and 180 degrees.
+*/
+func (p Placement) Solve(target Vec3) position.Position {
+  d := p.toLocal(target.Sub(p.Position))
+  length := d.Length()
+
+  if length == 0 {
+    return position.Position{}
+  }
+
+  pan := 0.0
+
+  // Pan is meaningless when pointing straight along the yoke axis
+  if math.Hypot(d.X, d.Z) > length * 1e-9 {
+    pan = degrees(math.Atan2(d.X, d.Z))
+  }
+
+  tilt := degrees(math.Acos(math.Max(-1, math.Min(1, d.Y / length))))
+
+  return position.Position{Pan: pan, Tilt: tilt}
+}
+
+type FollowSpot struct {
+  placement Placement
+  output chan position.Position
+
+  assign chan int
+  smoothing chan float64
+  offset chan Vec3
+  stop chan bool
+}
+
+// Value for assign that stops following any tracker
+const unassigned int = -1
+
+/*
+Build a follow spot that recieves tracker data from receiver and sends positions
+to output. It doesn't move until a tracker is assigned. Stopping the follow spot
+closes output.
+*/
+func NewFollowSpot(receiver *Receiver, placement Placement, output chan position.Position) *FollowSpot {
+  spot := new(FollowSpot)
+  spot.placement = placement
+  spot.output = output
+
+  spot.assign = make(chan int)
+  spot.smoothing = make(chan float64)
+  spot.offset = make(chan Vec3)
+  spot.stop = make(chan bool)
+
+  go spot.follow(receiver.Watch())
+
+  return spot
+}
+
+// Follow the tracker with the given ID
+func (spot *FollowSpot) Assign(tracker uint16) {
+  spot.assign <- int(tracker)
+}
+
+// Stop following any tracker, leaving the fixture where it is
+func (spot *FollowSpot) Unassign() {
+  spot.assign <- unassigned
+}
+
+/*
+Set how much the target's movement is smoothed, from 0 (none) up to but not
+including 1. Each frame the target moves this fraction less of the way to the
+tracker's new position.
+*/
+func (spot *FollowSpot) SetSmoothing(smoothing float64) {
+  spot.smoothing <- math.Max(0, math.Min(smoothing, 0.999))
+}
+
+// Aim this far from the tracker, e.g. above a performer's belt pack
+func (spot *FollowSpot) SetOffset(offset Vec3) {
+  spot.offset <- offset
+}
+
+// Stop following and release the output
+func (spot *FollowSpot) Stop() {
+  spot.stop <- true
+}
+
+func (spot *FollowSpot) follow(frames chan *Frame) {
+  tracker := unassigned
+  smoothing := 0.0
+  offset := Vec3{}
+
+  // Smoothed target, reset when the tracker changes
+  var target Vec3
+  hasTarget := false
+
+  // Only offer data to the output when there is a new position for it
+  var output chan position.Position = nil
+  var pending position.Position
+
+  for {
+    select {
+    case frame, ok := <-frames:
+      if !ok {
+        frames = nil
+        continue
+      }
+
+      if tracker == unassigned {
+        continue
+      }
+
+      for _, t := range frame.Trackers {
+        if int(t.ID) != tracker || !t.HasPosition {
+          continue
+        }
+
+        aim := t.Position.Add(offset)
+
+        if hasTarget {
+          target = target.Add(aim.Sub(target).Scale(1 - smoothing))
+        } else {
+          target = aim
+          hasTarget = true
+        }
+
+        pending = spot.placement.Solve(target)
+        output = spot.output
+      }
+    case output <- pending:
+      output = nil
+    case id := <-spot.assign:
+      tracker = id
+      hasTarget = false
+    case smoothing = <-spot.smoothing:
+    case offset = <-spot.offset:
+    case _ = <-spot.stop:
+      close(spot.output)
+      return
+    }
+  }
+}
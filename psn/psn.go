@@ -0,0 +1,291 @@
+/*
+PosiStageNet tracking input
+
+Decodes PSN v2 data and info packets. PSN packets are a tree of chunks, each
+with a 32 bit little endian header holding the chunk ID, the length of its data
+and a flag for whether the data is made of sub-chunks.
+
+Positions are in metres using PSN's axes: x and z are horizontal and y is up.
+*/
+package psn
+
+import (
+  "bytes"
+  "encoding/binary"
+  "errors"
+  "math"
+)
+
+const (
+  dataPacket uint16 = 0x6755
+  infoPacket uint16 = 0x6756
+
+  // Shared by data and info packets
+  packetHeader uint16 = 0x0000
+
+  dataTrackerList uint16 = 0x0001
+  infoSystemName uint16 = 0x0001
+  infoTrackerList uint16 = 0x0002
+
+  trackerPos uint16 = 0x0000
+  trackerSpeed uint16 = 0x0001
+  trackerOri uint16 = 0x0002
+  trackerStatus uint16 = 0x0003
+  trackerAccel uint16 = 0x0004
+  trackerTarget uint16 = 0x0005
+  trackerTimestamp uint16 = 0x0006
+
+  infoTrackerName uint16 = 0x0000
+)
+
+type Vec3 struct {
+  X float64
+  Y float64
+  Z float64
+}
+
+func (a Vec3) Add(b Vec3) Vec3 {
+  return Vec3{a.X + b.X, a.Y + b.Y, a.Z + b.Z}
+}
+
+func (a Vec3) Sub(b Vec3) Vec3 {
+  return Vec3{a.X - b.X, a.Y - b.Y, a.Z - b.Z}
+}
+
+func (a Vec3) Scale(s float64) Vec3 {
+  return Vec3{a.X * s, a.Y * s, a.Z * s}
+}
+
+func (a Vec3) Length() float64 {
+  return math.Sqrt(a.X * a.X + a.Y * a.Y + a.Z * a.Z)
+}
+
+/*
+The most recent data for a tracker. Only Position is sent by every server, the
+Has fields indicate which of the optional fields were in the packet.
+*/
+type Tracker struct {
+  ID uint16
+  Name string
+
+  Position Vec3
+  Speed Vec3
+  Orientation Vec3
+  Acceleration Vec3
+  Target Vec3
+  Validity float32
+  Timestamp uint64
+
+  HasPosition bool
+  HasSpeed bool
+  HasOrientation bool
+  HasAcceleration bool
+  HasTarget bool
+  HasValidity bool
+  HasTimestamp bool
+}
+
+// A frame of tracker data from a PSN data packet
+type Frame struct {
+  Timestamp uint64
+  FrameID uint8
+  Trackers []Tracker
+}
+
+// System and tracker names from a PSN info packet
+type Info struct {
+  SystemName string
+  TrackerNames map[uint16] string
+}
+
+type chunk struct {
+  id uint16
+  hasSubChunks bool
+  data []byte
+}
+
+// Split data into the chunks it contains
+func readChunks(data []byte) ([]chunk, error) {
+  chunks := make([]chunk, 0)
+
+  for len(data) > 0 {
+    if len(data) < 4 {
+      return nil, errors.New("PSN chunk header is truncated")
+    }
+
+    header := binary.LittleEndian.Uint32(data)
+    length := int((header >> 16) & 0x7FFF)
+
+    if len(data) < 4 + length {
+      return nil, errors.New("PSN chunk is truncated")
+    }
+
+    c := chunk{id: uint16(header & 0xFFFF), hasSubChunks: header & 0x80000000 != 0}
+    c.data = data[4:4 + length]
+    chunks = append(chunks, c)
+
+    data = data[4 + length:]
+  }
+
+  return chunks, nil
+}
+
+func (c chunk) subChunks() ([]chunk, error) {
+  if !c.hasSubChunks {
+    return []chunk{}, nil
+  }
+  return readChunks(c.data)
+}
+
+func (c chunk) vec3() (Vec3, error) {
+  var v [3]float32
+  err := binary.Read(bytes.NewReader(c.data), binary.LittleEndian, &v)
+  return Vec3{float64(v[0]), float64(v[1]), float64(v[2])}, err
+}
+
+/*
+Decode a UDP payload. Returns a *Frame for data packets and an *Info for info
+packets.
+*/
+func Decode(packet []byte) (interface {}, error) {
+  chunks, err := readChunks(packet)
+
+  if err != nil {
+    return nil, err
+  }
+
+  if len(chunks) != 1 {
+    return nil, errors.New("PSN packets must contain a single root chunk")
+  }
+
+  switch chunks[0].id {
+  case dataPacket:
+    return decodeData(chunks[0])
+  case infoPacket:
+    return decodeInfo(chunks[0])
+  }
+
+  return nil, errors.New("Not a PSN packet")
+}
+
+func decodeData(root chunk) (*Frame, error) {
+  frame := new(Frame)
+  frame.Trackers = make([]Tracker, 0)
+
+  sections, err := root.subChunks()
+
+  if err != nil {
+    return nil, err
+  }
+
+  for _, section := range sections {
+    switch section.id {
+    case packetHeader:
+      if len(section.data) < 12 {
+        return nil, errors.New("PSN packet header is truncated")
+      }
+      frame.Timestamp = binary.LittleEndian.Uint64(section.data)
+      frame.FrameID = section.data[10]
+    case dataTrackerList:
+      trackers, err := section.subChunks()
+
+      if err != nil {
+        return nil, err
+      }
+
+      for _, t := range trackers {
+        tracker, err := decodeTracker(t)
+
+        if err != nil {
+          return nil, err
+        }
+
+        frame.Trackers = append(frame.Trackers, tracker)
+      }
+    }
+  }
+
+  return frame, nil
+}
+
+func decodeTracker(c chunk) (Tracker, error) {
+  tracker := Tracker{ID: c.id}
+
+  fields, err := c.subChunks()
+
+  if err != nil {
+    return tracker, err
+  }
+
+  for _, field := range fields {
+    switch field.id {
+    case trackerPos:
+      tracker.Position, err = field.vec3()
+      tracker.HasPosition = err == nil
+    case trackerSpeed:
+      tracker.Speed, err = field.vec3()
+      tracker.HasSpeed = err == nil
+    case trackerOri:
+      tracker.Orientation, err = field.vec3()
+      tracker.HasOrientation = err == nil
+    case trackerAccel:
+      tracker.Acceleration, err = field.vec3()
+      tracker.HasAcceleration = err == nil
+    case trackerTarget:
+      tracker.Target, err = field.vec3()
+      tracker.HasTarget = err == nil
+    case trackerStatus:
+      err = binary.Read(bytes.NewReader(field.data), binary.LittleEndian, &tracker.Validity)
+      tracker.HasValidity = err == nil
+    case trackerTimestamp:
+      err = binary.Read(bytes.NewReader(field.data), binary.LittleEndian, &tracker.Timestamp)
+      tracker.HasTimestamp = err == nil
+    }
+
+    if err != nil {
+      return tracker, errors.New("PSN tracker field is truncated")
+    }
+  }
+
+  return tracker, nil
+}
+
+func decodeInfo(root chunk) (*Info, error) {
+  info := new(Info)
+  info.TrackerNames = make(map[uint16] string)
+
+  sections, err := root.subChunks()
+
+  if err != nil {
+    return nil, err
+  }
+
+  for _, section := range sections {
+    switch section.id {
+    case infoSystemName:
+      info.SystemName = string(section.data)
+    case infoTrackerList:
+      trackers, err := section.subChunks()
+
+      if err != nil {
+        return nil, err
+      }
+
+      for _, t := range trackers {
+        fields, err := t.subChunks()
+
+        if err != nil {
+          return nil, err
+        }
+
+        for _, field := range fields {
+          if field.id == infoTrackerName {
+            info.TrackerNames[t.id] = string(field.data)
+          }
+        }
+      }
+    }
+  }
+
+  return info, nil
+}
@@ -0,0 +1,185 @@
+package psn
+
+import (
+  "bytes"
+  "encoding/binary"
+  "math"
+  "net"
+  "testing"
+  "time"
+  "golx/data/position"
+)
+
+// Build a chunk with a header for data
+func testChunk(id uint16, hasSubChunks bool, data []byte) []byte {
+  header := uint32(id) | uint32(len(data)) << 16
+  if hasSubChunks {
+    header |= 0x80000000
+  }
+
+  buf := bytes.NewBuffer(make([]byte, 0))
+  binary.Write(buf, binary.LittleEndian, header)
+  buf.Write(data)
+  return buf.Bytes()
+}
+
+func testVec3(x, y, z float32) []byte {
+  buf := bytes.NewBuffer(make([]byte, 0))
+  binary.Write(buf, binary.LittleEndian, [3]float32{x, y, z})
+  return buf.Bytes()
+}
+
+func testDataPacket(id uint16, pos Vec3) []byte {
+  header := make([]byte, 12)
+  binary.LittleEndian.PutUint64(header, 1234)
+  header[8] = 2
+  header[10] = 7
+  header[11] = 1
+
+  fields := append(testChunk(trackerPos, false, testVec3(float32(pos.X), float32(pos.Y), float32(pos.Z))),
+    testChunk(trackerStatus, false, []byte{0, 0, 0x80, 0x3F})...)
+
+  tracker := testChunk(id, true, fields)
+  list := testChunk(dataTrackerList, true, tracker)
+
+  return testChunk(dataPacket, true, append(testChunk(packetHeader, false, header), list...))
+}
+
+func testInfoPacket(id uint16, name string) []byte {
+  tracker := testChunk(id, true, testChunk(infoTrackerName, false, []byte(name)))
+  list := testChunk(infoTrackerList, true, tracker)
+  system := testChunk(infoSystemName, false, []byte("Tracking"))
+
+  return testChunk(infoPacket, true, append(system, list...))
+}
+
+func TestDecodeDataPacket(t *testing.T) {
+  packet, err := Decode(testDataPacket(3, Vec3{1, 2, 3}))
+
+  if err != nil {
+    t.Log("Error decoding packet: ", err.Error())
+    t.FailNow()
+  }
+
+  frame, ok := packet.(*Frame)
+
+  if !ok || frame.Timestamp != 1234 || frame.FrameID != 7 || len(frame.Trackers) != 1 {
+    t.Log("Packet decoded incorrectly: ", packet)
+    t.FailNow()
+  }
+
+  tracker := frame.Trackers[0]
+
+  if tracker.ID != 3 || !tracker.HasPosition || tracker.Position != (Vec3{1, 2, 3}) ||
+    !tracker.HasValidity || tracker.Validity != 1 || tracker.HasSpeed {
+    t.Log("Tracker decoded incorrectly: ", tracker)
+    t.Fail()
+  }
+}
+
+func TestDecodeInfoPacket(t *testing.T) {
+  packet, err := Decode(testInfoPacket(3, "Lead"))
+
+  if err != nil {
+    t.Log("Error decoding packet: ", err.Error())
+    t.FailNow()
+  }
+
+  info := packet.(*Info)
+
+  if info.SystemName != "Tracking" || info.TrackerNames[3] != "Lead" {
+    t.Log("Info decoded incorrectly: ", info)
+    t.Fail()
+  }
+}
+
+func TestDecodeTruncatedPacket(t *testing.T) {
+  data := testDataPacket(1, Vec3{})
+
+  if _, err := Decode(data[:len(data) - 3]); err == nil {
+    t.Log("Truncated packet decoded without error")
+    t.Fail()
+  }
+}
+
+func closeTo(a, b float64) bool {
+  return math.Abs(a - b) < 0.001
+}
+
+func TestSolve(t *testing.T) {
+  floor := Placement{}
+  hung := Placement{Position: Vec3{0, 5, 0}, Roll: 180}
+
+  cases := []struct {
+    placement Placement
+    target Vec3
+    pan float64
+    tilt float64
+  }{
+    {floor, Vec3{0, 10, 0}, 0, 0},
+    {floor, Vec3{0, 0, 4}, 0, 90},
+    {floor, Vec3{3, 0, 0}, 90, 90},
+    {floor, Vec3{0, 1, -1}, 180, 45},
+    {hung, Vec3{0, 0, 0}, 0, 0},
+    {hung, Vec3{0, 5, 2}, 0, 90},
+    {hung, Vec3{2, 5, 0}, -90, 90},
+    {Placement{Yaw: 90}, Vec3{1, 0, 0}, 0, 90},
+  }
+
+  for i, c := range cases {
+    pos := c.placement.Solve(c.target)
+
+    if !closeTo(pos.Pan, c.pan) || !closeTo(pos.Tilt, c.tilt) {
+      t.Log("Case ", i, " solved to ", pos, " expected pan ", c.pan, " tilt ", c.tilt)
+      t.Fail()
+    }
+  }
+}
+
+func TestFollowSpot(t *testing.T) {
+  conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+
+  if err != nil {
+    t.Log("Error opening socket: ", err.Error())
+    t.FailNow()
+  }
+
+  receiver := NewReceiver(conn)
+  defer receiver.Close()
+
+  output := make(chan position.Position)
+  spot := NewFollowSpot(receiver, Placement{Position: Vec3{0, 5, 0}, Roll: 180}, output)
+  spot.Assign(2)
+
+  sender, _ := net.DialUDP("udp", nil, conn.LocalAddr().(*net.UDPAddr))
+  defer sender.Close()
+
+  // Keep sending until the receiver is ready, as a tracking server would
+  timeout := time.After(time.Second)
+  tick := time.Tick(5 * time.Millisecond)
+
+  for {
+    select {
+    case _ = <-tick:
+      sender.Write(testDataPacket(1, Vec3{0, 5, 10}))
+      sender.Write(testDataPacket(2, Vec3{0, 0, 0}))
+    case pos := <-output:
+      // Only the assigned tracker is followed
+      if !closeTo(pos.Tilt, 0) {
+        t.Log("Follow spot pointed at ", pos)
+        t.Fail()
+      }
+
+      spot.Stop()
+
+      if _, ok := <-output; ok {
+        t.Log("Output was not closed when the follow spot stopped")
+        t.Fail()
+      }
+      return
+    case _ = <-timeout:
+      t.Log("Follow spot did not send a position")
+      t.FailNow()
+    }
+  }
+}
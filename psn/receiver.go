@@ -0,0 +1,96 @@
+package psn
+
+import (
+  "net"
+  "golx/patch/chanutil"
+)
+
+const (
+  MulticastGroup string = "236.10.10.10"
+  Port int = 56565
+)
+
+/*
+Listens for PSN packets and passes on each frame of tracker data. Tracker names
+from info packets are filled in to the frames as they become known.
+*/
+type Receiver struct {
+  conn net.PacketConn
+  frames chan *Frame
+  watchers *chanutil.Broadcaster
+}
+
+/*
+Join the PSN multicast group on the network interface ifi. If ifi is nil the
+system chooses the interface.
+*/
+func Listen(ifi *net.Interface) (*Receiver, error) {
+  group := &net.UDPAddr{IP: net.ParseIP(MulticastGroup), Port: Port}
+  conn, err := net.ListenMulticastUDP("udp4", ifi, group)
+
+  if err != nil {
+    return nil, err
+  }
+
+  return NewReceiver(conn), nil
+}
+
+// Read PSN packets from an existing connection, e.g. for unicast PSN
+func NewReceiver(conn net.PacketConn) *Receiver {
+  r := new(Receiver)
+  r.conn = conn
+  r.frames = make(chan *Frame)
+  r.watchers, _ = chanutil.NewBroadcaster(r.frames)
+
+  go r.listen()
+
+  return r
+}
+
+/*
+Get a channel that recieves every frame of tracker data. Frames are skipped if
+the channel isn't read promptly.
+*/
+func (r *Receiver) Watch() chan *Frame {
+  return r.watchers.Subscribe().(chan *Frame)
+}
+
+func (r *Receiver) Close() error {
+  return r.conn.Close()
+}
+
+func (r *Receiver) listen() {
+  names := make(map[uint16] string)
+  data := make([]byte, 1500)
+
+  defer close(r.frames)
+
+  for {
+    n, _, err := r.conn.ReadFrom(data)
+
+    if err != nil {
+      if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+        continue
+      }
+      return
+    }
+
+    packet, err := Decode(data[:n])
+
+    if err != nil {
+      continue
+    }
+
+    switch p := packet.(type) {
+    case *Info:
+      for id, name := range p.TrackerNames {
+        names[id] = name
+      }
+    case *Frame:
+      for i := range p.Trackers {
+        p.Trackers[i].Name = names[p.Trackers[i].ID]
+      }
+      r.frames <- p
+    }
+  }
+}